@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/kubearmor/kubearmor-client/policy"
+	"github.com/spf13/cobra"
+)
+
+var k8sPolicyOptions policy.Options
+
+// k8sPolicyCmd represents the policy command for the KubeArmor policies of the cluster
+var k8sPolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage the KubeArmor policies of the cluster",
+	Long: `Apply, delete, get and list the KubeArmorPolicies, KubeArmorHostPolicies and
+KubeArmorClusterPolicies of the cluster.
+
+Kinds can be given as ksp, hsp and csp.`,
+}
+
+// k8sPolicyApplyCmd represents the policy apply command
+var k8sPolicyApplyCmd = &cobra.Command{
+	Use:   "apply -f FILE|DIR",
+	Short: "Create or update the KubeArmor policies of files or directories",
+	Long: `Create or update the KubeArmor policies of files or directories.
+
+Files can hold several yaml documents, directories are walked for .yaml, .yml
+and .json files and - reads from stdin. Other resources are skipped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(k8sPolicyOptions.Files) == 0 {
+			return errors.New("requires --file")
+		}
+		return policy.Apply(client, k8sPolicyOptions)
+	},
+}
+
+// k8sPolicyDeleteCmd represents the policy delete command
+var k8sPolicyDeleteCmd = &cobra.Command{
+	Use:   "delete [NAME...]",
+	Short: "Delete KubeArmor policies by name or by file",
+	Long: `Delete the KubeArmor policies of files or directories, or the named policies
+of --kind (ksp by default).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return policy.Delete(client, args, k8sPolicyOptions)
+	},
+}
+
+// k8sPolicyGetCmd represents the policy get command
+var k8sPolicyGetCmd = &cobra.Command{
+	Use:   "get NAME...",
+	Short: "Show the named KubeArmor policies",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := policy.ValidateOutput(k8sPolicyOptions.Output); err != nil {
+			return err
+		}
+		policies, err := policy.Get(client, args, k8sPolicyOptions)
+		if len(policies) > 0 {
+			if err := policy.Print(os.Stdout, policies, k8sPolicyOptions.Output); err != nil {
+				return err
+			}
+		}
+		return err
+	},
+}
+
+// k8sPolicyListCmd represents the policy list command
+var k8sPolicyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the KubeArmor policies with the number of pods they select",
+	Long: `List the KubeArmor policies with the number of pods they select.
+
+With --namespace, only the cluster policies selecting the namespace are listed
+and host policies are left out unless asked for with --kind.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := policy.ValidateOutput(k8sPolicyOptions.Output); err != nil {
+			return err
+		}
+		policies, err := policy.List(client, k8sPolicyOptions)
+		if err != nil {
+			return err
+		}
+		return policy.Print(os.Stdout, policies, k8sPolicyOptions.Output)
+	},
+}
+
+// ========== //
+// == Init == //
+// ========== //
+
+func init() {
+	rootCmd.AddCommand(k8sPolicyCmd)
+
+	k8sPolicyCmd.AddCommand(k8sPolicyApplyCmd)
+	k8sPolicyCmd.AddCommand(k8sPolicyDeleteCmd)
+	k8sPolicyCmd.AddCommand(k8sPolicyGetCmd)
+	k8sPolicyCmd.AddCommand(k8sPolicyListCmd)
+
+	k8sPolicyCmd.PersistentFlags().StringVarP(&k8sPolicyOptions.Namespace, "namespace", "n", "", "Namespace of the policies, all namespaces when listing and default when applying if empty")
+
+	for _, c := range []*cobra.Command{k8sPolicyApplyCmd, k8sPolicyDeleteCmd} {
+		c.Flags().StringSliceVarP(&k8sPolicyOptions.Files, "file", "f", nil, "Policy file or directory, - for stdin")
+		c.Flags().BoolVar(&k8sPolicyOptions.DryRun, "dry-run", false, "Validate the changes with a server-side dry run without persisting them")
+	}
+	k8sPolicyDeleteCmd.Flags().StringSliceVar(&k8sPolicyOptions.Kinds, "kind", nil, "Policy kind of the named policies: ksp, hsp or csp")
+	for _, c := range []*cobra.Command{k8sPolicyGetCmd, k8sPolicyListCmd} {
+		c.Flags().StringSliceVar(&k8sPolicyOptions.Kinds, "kind", nil, "Policy kinds: ksp, hsp or csp, all by default")
+		c.Flags().StringVarP(&k8sPolicyOptions.Labels, "selector", "l", "", "Label selector of the policies, e.g. app=nginx")
+		c.Flags().StringVarP(&k8sPolicyOptions.Output, "output", "o", policy.OutputTable, "Output format: table, yaml or json")
+	}
+}
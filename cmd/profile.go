@@ -16,8 +16,7 @@ var profilecmd = &cobra.Command{
 	Short: "Profiling of logs",
 	Long:  `Profiling of logs`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		profileclient.Start(profileOptions)
-		return nil
+		return profileclient.Start(profileOptions)
 	},
 }
 
@@ -28,4 +27,8 @@ func init() {
 	profilecmd.Flags().StringVar(&profileOptions.Pod, "pod", "", "Filter using Pod name")
 	profilecmd.Flags().StringVarP(&profileOptions.Container, "container", "c", "", "name of the container ")
 	profilecmd.Flags().BoolVar(&profileOptions.Save, "save", false, "Save Profile data in json format")
+	profilecmd.Flags().StringVar(&profileOptions.SaveBaseline, "save-baseline", "", "Record the observed behavior per container to a baseline file on exit")
+	profilecmd.Flags().StringVar(&profileOptions.Baseline, "baseline", "", "Highlight behavior not present in the given baseline file")
+	profilecmd.Flags().StringVar(&profileOptions.DriftReport, "drift-report", "", "Write the behavior not present in the --baseline to this file in json format on exit")
+	profilecmd.Flags().DurationVar(&profileOptions.Duration, "duration", 0, "Profile without the TUI for the given duration (e.g. 5m) then exit, failing if behavior not present in the --baseline is observed")
 }
@@ -4,9 +4,18 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kubearmor/kubearmor-client/k8s"
 	"github.com/kubearmor/kubearmor-client/recommend"
 	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/engines"
 	genericpolicies "github.com/kubearmor/kubearmor-client/recommend/engines/generic_policies"
+	"github.com/kubearmor/kubearmor-client/recommend/engines/telemetry"
+	"github.com/kubearmor/kubearmor-client/recommend/registry"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -18,16 +27,73 @@ var recommendCmd = &cobra.Command{
 	Use:   "recommend",
 	Short: "Recommend Policies",
 	Long:  `Recommend policies based on container image, k8s manifest or the actual runtime env`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		client, err = k8s.ConnectK8sClient()
+		if err != nil {
+			if len(recommendOptions.Images) > 0 || len(recommendOptions.Rootfs) > 0 || len(recommendOptions.SBOMs) > 0 || recommendOptions.File != "" || cmd.Name() != "recommend" {
+				// cluster access is not needed to recommend for images or local manifests
+				log.WithError(err).Debug("unable to create Kubernetes clients")
+				client = nil
+				return nil
+			}
+			return err
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		err := recommend.Recommend(client, recommendOptions, genericpolicies.GenericPolicy{})
+		var engine engines.Engine
+		switch recommendOptions.Engine {
+		case "generic":
+			engine = genericpolicies.GenericPolicy{
+				Templates:        recommendOptions.Templates,
+				TemplatesVersion: recommendOptions.TemplatesVersion,
+				Rules:            recommendOptions.Rules,
+				HostPolicy:       recommendOptions.HostPolicy(),
+			}
+		case "telemetry":
+			engine = telemetry.New(recommendOptions.Events)
+		default:
+			return fmt.Errorf("unknown engine %q, supported engines are generic and telemetry", recommendOptions.Engine)
+		}
+		err := recommend.Recommend(client, recommendOptions, engine)
 		return err
 	},
 }
+var updateOptions struct {
+	list bool
+	use  string
+}
+
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Updates policy-template cache",
-	Long:  "Updates the local cache of policy-templates ($HOME/.cache/karmor)",
+	Long:  "Updates the local cache of policy-templates ($HOME/.cache/karmor), lists the cached versions or selects the one used by default",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if updateOptions.list {
+			versions, err := genericpolicies.CachedVersions()
+			if err != nil {
+				return err
+			}
+			current := genericpolicies.CurrentRelease()
+			for _, v := range versions {
+				if v == current {
+					fmt.Printf("* %s\n", v)
+				} else {
+					fmt.Printf("  %s\n", v)
+				}
+			}
+			return nil
+		}
+		if updateOptions.use != "" {
+			if err := genericpolicies.UseVersion(updateOptions.use); err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"Current Version": genericpolicies.CurrentVersion,
+			}).Info("policy-templates version selected")
+			return nil
+		}
 
 		if _, err := genericpolicies.DownloadAndUnzipRelease(); err != nil {
 			return err
@@ -39,15 +105,86 @@ var updateCmd = &cobra.Command{
 	},
 }
 
+var pruneOptions registry.PruneOptions
+var pruneMaxSize int64
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the image analysis cache",
+	Long:  "Manage the local cache of image analysis results ($HOME/.cache/karmor/images)",
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove image analysis cache entries",
+	Long:  "Remove image analysis cache entries, all of them or the ones exceeding the age and size limits",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !pruneOptions.All && pruneOptions.MaxAge == 0 && pruneMaxSize == 0 {
+			return errors.New("nothing to prune, use --all, --max-age or --max-size")
+		}
+		pruneOptions.MaxSize = pruneMaxSize * 1024 * 1024
+		cache := registry.NewCache(registry.DefaultCacheDir(), 0)
+		removed, freed, err := cache.Prune(pruneOptions)
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"entries": removed,
+			"freed":   fmt.Sprintf("%.1fMB", float64(freed)/(1024*1024)),
+		}).Info("image analysis cache pruned")
+		return nil
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(recommendCmd)
 	recommendCmd.AddCommand(updateCmd)
+	recommendCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(pruneCmd)
+
+	updateCmd.Flags().BoolVar(&updateOptions.list, "list", false, "list the cached policy-templates versions, * marks the one in use")
+	updateCmd.Flags().StringVar(&updateOptions.use, "use", "", "use the given policy-templates version by default, downloading it if needed")
+
+	pruneCmd.Flags().BoolVar(&pruneOptions.All, "all", false, "remove all entries")
+	pruneCmd.Flags().DurationVar(&pruneOptions.MaxAge, "max-age", 0, "remove entries not used for longer than this, e.g. 720h")
+	pruneCmd.Flags().Int64Var(&pruneMaxSize, "max-size", 0, "remove the least recently used entries beyond this size in MB")
 
 	recommendCmd.Flags().StringSliceVarP(&recommendOptions.Images, "image", "i", []string{}, "Container image list (comma separated)")
+	recommendCmd.Flags().StringSliceVar(&recommendOptions.SBOMs, "sbom", []string{}, "SPDX or CycloneDX JSON SBOM of an image, analyzed instead of pulling the image")
 	recommendCmd.Flags().StringSliceVarP(&recommendOptions.Labels, "labels", "l", []string{}, "User defined labels for policy (comma separated)")
 	recommendCmd.Flags().StringVarP(&recommendOptions.Namespace, "namespace", "n", "", "User defined namespace value for policies")
 	recommendCmd.Flags().StringVarP(&recommendOptions.OutDir, "outdir", "o", "out", "output folder to write policies")
 	recommendCmd.Flags().StringVarP(&recommendOptions.ReportFile, "report", "r", "report.txt", "report file")
+	recommendCmd.Flags().StringVar(&recommendOptions.ReportFormat, "report-format", "", fmt.Sprintf("report format {%s}, derived from the report file extension by default", strings.Join(report.Formats(), "|")))
+	recommendCmd.Flags().StringVar(&recommendOptions.CoverageReport, "coverage-report", "", "compliance coverage matrix of the workloads (.html, .csv or .json file)")
+	recommendCmd.Flags().BoolVar(&recommendOptions.Check, "check", false, "fail if a workload lacks existing policies for the --require-tags, printing the gaps")
+	recommendCmd.Flags().StringSliceVar(&recommendOptions.RequireTags, "require-tags", []string{}, "tags (comma-separated) every workload must have policies for in --check mode. Eg. MITRE,NIST")
+	recommendCmd.Flags().StringVar(&recommendOptions.PolicyDir, "policy-dir", "", "existing policies (yaml file or directory) to --check, the cluster policies by default")
 	recommendCmd.Flags().StringSliceVarP(&recommendOptions.Tags, "tag", "t", []string{}, "tags (comma-separated) to apply. Eg. PCI-DSS, MITRE")
 	recommendCmd.Flags().StringVarP(&recommendOptions.Config, "config", "c", common.UserHome()+"/.docker/config.json", "absolute path to image registry configuration file")
+	recommendCmd.Flags().StringVar(&recommendOptions.Backend, "backend", registry.BackendAuto, "image analysis backend {auto|docker|registry}, oci-layout:<dir> and docker-archive:<file> images never need the docker daemon")
+	recommendCmd.Flags().BoolVar(&recommendOptions.Consolidate, "consolidate", false, "merge the rules recommended for a workload into a single policy per action")
+	recommendCmd.Flags().BoolVar(&recommendOptions.Interactive, "interactive", false, "review the recommended policies, accepting, rejecting or editing each of them before they are kept")
+	recommendCmd.Flags().BoolVar(&recommendOptions.Diff, "diff", false, "compare the generated policies with the live policies of the same name, live policies of other names selecting the workload are not compared")
+	recommendCmd.Flags().BoolVar(&recommendOptions.Apply, "apply", false, "create or update the generated policies in the cluster using server-side apply")
+	recommendCmd.Flags().BoolVar(&recommendOptions.DryRun, "dry-run", false, "with --apply, only validate the policies with a server-side dry-run")
+	recommendCmd.Flags().IntVar(&recommendOptions.Workers, "workers", registry.DefaultWorkers, "number of images pulled and analyzed in parallel")
+	recommendCmd.Flags().BoolVar(&recommendOptions.NoCache, "no-cache", false, "do not use the image analysis cache ($HOME/.cache/karmor/images)")
+	recommendCmd.Flags().Int64Var(&recommendOptions.CacheMaxSize, "cache-max-size", registry.DefaultCacheMaxSize, "size limit of the image analysis cache in MB, 0 for unlimited")
+	recommendCmd.Flags().StringVar(&recommendOptions.Templates, "templates", "", "local policy-templates directory, zip or tar.gz, no download is attempted")
+	recommendCmd.Flags().StringVar(&recommendOptions.TemplatesVersion, "templates-version", "", "policy-templates release to use, downloaded and cached if needed")
+	recommendCmd.Flags().StringVar(&recommendOptions.Rules, "rules", "", "user defined rules (yaml file or directory) merged with policy-templates, overriding rules of the same name")
+	recommendCmd.Flags().StringVar(&recommendOptions.Action, "action", common.ActionTemplate, "action of the recommended policies {Audit|Block|template}, template keeps the action of each rule")
+	recommendCmd.Flags().IntVar(&recommendOptions.MinSeverity, "min-severity", 0, "audit instead of block the rules with a severity below this value (1 to 10, 0 disables it)")
+	recommendCmd.Flags().StringSliceVar(&recommendOptions.Overrides, "override", []string{}, "per tag action taking precedence over --action and --min-severity, e.g. MITRE=Block")
+	recommendCmd.Flags().StringSliceVar(&recommendOptions.Policy, "kind", []string{common.KindKubeArmorPolicy}, "kind of the recommended policies {KubeArmorPolicy|KubeArmorHostPolicy}")
+	recommendCmd.Flags().StringSliceVar(&recommendOptions.NodeSelector, "node-selector", []string{}, "node labels selected by host policies (key=value)")
+	recommendCmd.Flags().StringSliceVar(&recommendOptions.Rootfs, "rootfs", []string{}, "node root filesystem (directory or tarball) to analyze for host policies")
+	recommendCmd.Flags().StringVar(&recommendOptions.Platform, "platform", "", "platform of multi-platform images to analyze, e.g. linux/arm64 (default linux on the local arch)")
+	recommendCmd.Flags().BoolVar(&recommendOptions.AllPlatforms, "all-platforms", false, "analyze every linux platform of multi-platform images and recommend policies valid for all of them")
+	recommendCmd.Flags().BoolVar(&recommendOptions.NetworkBaseline, "network-baseline", false, "recommend a KubeArmor allow list of the protocols of the workload ports (and udp for DNS), blocking other protocols such as icmp and raw sockets")
+	recommendCmd.Flags().StringVar(&recommendOptions.NetworkPolicy, "network-policy", "", "also generate a network policy per workload allowing ingress only on its ports, egress is not restricted (supported: cilium)")
+	recommendCmd.Flags().StringVar(&recommendOptions.Engine, "engine", "generic", "policy generation engine {generic|telemetry}")
+	recommendCmd.Flags().StringVar(&recommendOptions.Events, "events", "", "recorded KubeArmor logs (ndjson file or directory) used by the telemetry engine")
+	recommendCmd.Flags().StringVarP(&recommendOptions.File, "file", "f", "", "k8s manifests to recommend for offline: yaml file, directory, kustomization directory or - for stdin (e.g. helm template output)")
 }
@@ -17,6 +17,7 @@ replace (
 )
 
 require (
+	github.com/atotto/clipboard v0.1.4
 	github.com/blang/semver v3.5.1+incompatible
 	github.com/cilium/cilium v1.13.2
 	github.com/clarketm/json v1.17.1
@@ -48,6 +49,7 @@ require (
 	github.com/deckarep/golang-set/v2 v2.3.0
 	github.com/evertras/bubble-table v0.15.2
 	github.com/google/go-cmp v0.6.0
+	github.com/google/go-containerregistry v0.14.0
 	github.com/google/go-github v17.0.0+incompatible
 	github.com/kubearmor/KVMService/src/types v0.0.0-20220714130113-b0eba8c9ff34
 	github.com/kubearmor/KubeArmor/KubeArmor v0.0.0-20240110164432-c2c1b121cd94
@@ -60,6 +62,8 @@ require (
 	k8s.io/apimachinery v0.29.0
 	k8s.io/cli-runtime v0.27.1
 	k8s.io/client-go v0.27.2
+	sigs.k8s.io/kustomize/api v0.13.2
+	sigs.k8s.io/kustomize/kyaml v0.14.1
 )
 
 require (
@@ -99,7 +103,6 @@ require (
 	github.com/aliyun/credentials-go v1.2.7 // indirect
 	github.com/andybalholm/brotli v1.0.5 // indirect
 	github.com/asaskevich/govalidator v0.0.0-20230301143203-a9d515a09cc2 // indirect
-	github.com/atotto/clipboard v0.1.4 // indirect
 	github.com/aws/aws-sdk-go-v2 v1.18.0 // indirect
 	github.com/aws/aws-sdk-go-v2/config v1.18.22 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v1.13.21 // indirect
@@ -175,7 +178,6 @@ require (
 	github.com/google/btree v1.1.2 // indirect
 	github.com/google/certificate-transparency-go v1.1.5 // indirect
 	github.com/google/gnostic v0.6.9 // indirect
-	github.com/google/go-containerregistry v0.14.0 // indirect
 	github.com/google/go-github/v30 v30.1.0 // indirect
 	github.com/google/go-github/v45 v45.2.0 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
@@ -334,8 +336,6 @@ require (
 	k8s.io/utils v0.0.0-20240102154912-e7106e64919e // indirect
 	sigs.k8s.io/controller-runtime v0.15.0 // indirect
 	sigs.k8s.io/json v0.0.0-20221116044647-bc3834ca7abd // indirect
-	sigs.k8s.io/kustomize/api v0.13.2 // indirect
-	sigs.k8s.io/kustomize/kyaml v0.14.1 // indirect
 	sigs.k8s.io/release-utils v0.7.3 // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.4.1 // indirect
 )
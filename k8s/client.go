@@ -8,6 +8,7 @@ import (
 	"github.com/rs/zerolog/log"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -23,8 +24,10 @@ type Client struct {
 	K8sClientset    kubernetes.Interface
 	KSPClientset    ksp.SecurityV1Interface
 	APIextClientset apiextensionsclientset.Interface
-	RawConfig       clientcmdapi.Config
-	Config          *rest.Config
+	// DynamicClientset for the KubeArmor resources without a typed client
+	DynamicClientset dynamic.Interface
+	RawConfig        clientcmdapi.Config
+	Config           *rest.Config
 }
 
 var (
@@ -72,11 +75,18 @@ func ConnectK8sClient() (*Client, error) {
 		return nil, err
 	}
 
+	dynamicClientset, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		return nil, err
+	}
+
 	return &Client{
-		K8sClientset:    clientset,
-		KSPClientset:    kspClientset,
-		APIextClientset: extClientset,
-		RawConfig:       rawConfig,
-		Config:          config,
+		K8sClientset:     clientset,
+		KSPClientset:     kspClientset,
+		APIextClientset:  extClientset,
+		DynamicClientset: dynamicClientset,
+		RawConfig:        rawConfig,
+		Config:           config,
 	}, nil
 }
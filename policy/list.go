@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kubearmor/kubearmor-client/k8s"
+	"github.com/olekukonko/tablewriter"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats of get and list
+const (
+	OutputTable = "table"
+	OutputYAML  = "yaml"
+	OutputJSON  = "json"
+)
+
+// ValidateOutput checks the output format
+func ValidateOutput(output string) error {
+	switch output {
+	case OutputTable, OutputYAML, OutputJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s, use table, yaml or json", output)
+}
+
+// Policy KubeArmor policy of any kind with a summary of what it selects
+type Policy struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Action    string
+	Selector  string
+	Status    string
+	// Object policy as in the cluster, printed as yaml or json
+	Object interface{}
+}
+
+// clusterSelector namespaces selected by a cluster policy, with matchExpressions
+// on the namespace key
+type clusterSelector struct {
+	in    []string
+	notIn []string
+}
+
+func parseClusterSelector(p *unstructured.Unstructured) clusterSelector {
+	var sel clusterSelector
+	exprs, _, _ := unstructured.NestedSlice(p.Object, "spec", "selector", "matchExpressions")
+	for _, e := range exprs {
+		expr, ok := e.(map[string]interface{})
+		if !ok || expr["key"] != "namespace" {
+			continue
+		}
+		values, _, _ := unstructured.NestedStringSlice(expr, "values")
+		switch expr["operator"] {
+		case "In":
+			sel.in = append(sel.in, values...)
+		case "NotIn":
+			sel.notIn = append(sel.notIn, values...)
+		}
+	}
+	return sel
+}
+
+func (s clusterSelector) selects(namespace string) bool {
+	for _, ns := range s.notIn {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(s.in) == 0 {
+		return true
+	}
+	for _, ns := range s.in {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (s clusterSelector) String() string {
+	var parts []string
+	if len(s.in) > 0 {
+		parts = append(parts, fmt.Sprintf("namespace in (%s)", strings.Join(s.in, ",")))
+	}
+	if len(s.notIn) > 0 {
+		parts = append(parts, fmt.Sprintf("namespace notin (%s)", strings.Join(s.notIn, ",")))
+	}
+	if len(parts) == 0 {
+		return "all namespaces"
+	}
+	return strings.Join(parts, ",")
+}
+
+// selectorString labels of a selector, empty selectors select everything
+func selectorString(matchLabels map[string]string) string {
+	if len(matchLabels) == 0 {
+		return "*"
+	}
+	return labels.Set(matchLabels).String()
+}
+
+// podStatus summarizes the pods selected by a policy
+func podStatus(pods []corev1.Pod) string {
+	if len(pods) == 0 {
+		return "no pods selected"
+	}
+	running := 0
+	for _, p := range pods {
+		if p.Status.Phase == corev1.PodRunning {
+			running++
+		}
+	}
+	return fmt.Sprintf("%s, %d running", plural(len(pods), "pod"), running)
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// listPolicies lists the KubeArmorPolicies of the namespace, all namespaces if empty
+func listPolicies(c *k8s.Client, o Options) ([]Policy, error) {
+	ctx := context.Background()
+	list, err := c.KSPClientset.KubeArmorPolicies(o.Namespace).List(ctx, v1.ListOptions{LabelSelector: o.Labels})
+	if err != nil {
+		return nil, err
+	}
+	var policies []Policy
+	for i := range list.Items {
+		p := list.Items[i]
+		p.APIVersion = APIVersion
+		p.Kind = KindPolicy
+		p.ManagedFields = nil
+		pods, err := c.K8sClientset.CoreV1().Pods(p.Namespace).List(ctx, v1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(p.Spec.Selector.MatchLabels).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, Policy{
+			Kind:      KindPolicy,
+			Namespace: p.Namespace,
+			Name:      p.Name,
+			Action:    orDash(string(p.Spec.Action)),
+			Selector:  selectorString(p.Spec.Selector.MatchLabels),
+			Status:    podStatus(pods.Items),
+			Object:    &p,
+		})
+	}
+	return policies, nil
+}
+
+// listHostPolicies lists the KubeArmorHostPolicies, they select nodes rather than pods
+func listHostPolicies(c *k8s.Client, o Options) ([]Policy, error) {
+	ctx := context.Background()
+	list, err := c.KSPClientset.KubeArmorHostPolicies().List(ctx, v1.ListOptions{LabelSelector: o.Labels})
+	if err != nil {
+		return nil, err
+	}
+	var policies []Policy
+	for i := range list.Items {
+		p := list.Items[i]
+		p.APIVersion = APIVersion
+		p.Kind = KindHostPolicy
+		p.ManagedFields = nil
+		nodes, err := c.K8sClientset.CoreV1().Nodes().List(ctx, v1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(p.Spec.NodeSelector.MatchLabels).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		status := "no nodes selected"
+		if len(nodes.Items) > 0 {
+			status = plural(len(nodes.Items), "node")
+		}
+		policies = append(policies, Policy{
+			Kind:     KindHostPolicy,
+			Name:     p.Name,
+			Action:   orDash(string(p.Spec.Action)),
+			Selector: selectorString(p.Spec.NodeSelector.MatchLabels),
+			Status:   status,
+			Object:   &p,
+		})
+	}
+	return policies, nil
+}
+
+// listClusterPolicies lists the cluster policies selecting the namespace, all
+// of them if empty
+func listClusterPolicies(c *k8s.Client, o Options) ([]Policy, error) {
+	ctx := context.Background()
+	list, err := c.DynamicClientset.Resource(ClusterPolicyResource).List(ctx, v1.ListOptions{LabelSelector: o.Labels})
+	if err != nil {
+		return nil, err
+	}
+	var policies []Policy
+	var pods []corev1.Pod
+	listed := false
+	for i := range list.Items {
+		p := list.Items[i]
+		sel := parseClusterSelector(&p)
+		if o.Namespace != "" && !sel.selects(o.Namespace) {
+			continue
+		}
+		unstructured.RemoveNestedField(p.Object, "metadata", "managedFields")
+		if !listed {
+			all, err := c.K8sClientset.CoreV1().Pods(o.Namespace).List(ctx, v1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			pods = all.Items
+			listed = true
+		}
+		var selected []corev1.Pod
+		for _, pod := range pods {
+			if sel.selects(pod.Namespace) {
+				selected = append(selected, pod)
+			}
+		}
+		action, _, _ := unstructured.NestedString(p.Object, "spec", "action")
+		policies = append(policies, Policy{
+			Kind:     KindClusterPolicy,
+			Name:     p.GetName(),
+			Action:   orDash(action),
+			Selector: sel.String(),
+			Status:   podStatus(selected),
+			Object:   p.Object,
+		})
+	}
+	return policies, nil
+}
+
+// List lists the policies of the requested kinds. With a namespace, only the
+// cluster policies selecting it are listed and host policies are left out
+// unless asked for.
+func List(c *k8s.Client, o Options) ([]Policy, error) {
+	kinds, err := o.kinds()
+	if err != nil {
+		return nil, err
+	}
+	var policies []Policy
+	for _, kind := range kinds {
+		var list []Policy
+		switch kind {
+		case KindPolicy:
+			list, err = listPolicies(c, o)
+		case KindHostPolicy:
+			if o.Namespace != "" && len(o.Kinds) == 0 {
+				continue
+			}
+			list, err = listHostPolicies(c, o)
+		case KindClusterPolicy:
+			list, err = listClusterPolicies(c, o)
+		}
+		// the CRD is missing from clusters running an older KubeArmor
+		if k8serrors.IsNotFound(err) && len(o.Kinds) == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+		policies = append(policies, list...)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Kind != policies[j].Kind {
+			return policies[i].Kind < policies[j].Kind
+		}
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+	return policies, nil
+}
+
+// Get lists the named policies, failing if any of them does not exist
+func Get(c *k8s.Client, names []string, o Options) ([]Policy, error) {
+	all, err := List(c, o)
+	if err != nil {
+		return nil, err
+	}
+	var policies []Policy
+	var errs []error
+	for _, name := range names {
+		found := false
+		for _, p := range all {
+			if p.Name == name {
+				policies = append(policies, p)
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("policy %s not found", name))
+		}
+	}
+	return policies, errors.Join(errs...)
+}
+
+// Print writes the policies as a table, a yaml stream or a json array
+func Print(w io.Writer, policies []Policy, output string) error {
+	switch output {
+	case OutputYAML:
+		for i, p := range policies {
+			data, err := yaml.Marshal(p.Object)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Fprintln(w, "---")
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputJSON:
+		objects := []interface{}{}
+		for _, p := range policies {
+			objects = append(objects, p.Object)
+		}
+		data, err := json.MarshalIndent(objects, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	if len(policies) == 0 {
+		fmt.Fprintln(w, "no KubeArmor policies found")
+		return nil
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"KIND", "NAMESPACE", "NAME", "ACTION", "SELECTOR", "STATUS"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	for _, p := range policies {
+		table.Append([]string{p.Kind, orDash(p.Namespace), p.Name, p.Action, p.Selector, p.Status})
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,388 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+// Package policy applies, deletes and lists the KubeArmor policies of a cluster
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/fatih/color"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Kinds of the KubeArmor policies
+const (
+	KindPolicy        = "KubeArmorPolicy"
+	KindHostPolicy    = "KubeArmorHostPolicy"
+	KindClusterPolicy = "KubeArmorClusterPolicy"
+)
+
+// APIVersion of the KubeArmor policies
+const APIVersion = "security.kubearmor.com/v1"
+
+// ClusterPolicyResource cluster policies are not part of the KSP clientset,
+// they are handled with the dynamic client
+var ClusterPolicyResource = schema.GroupVersionResource{
+	Group:    "security.kubearmor.com",
+	Version:  "v1",
+	Resource: "kubearmorclusterpolicies",
+}
+
+// fieldManager recorded on the policies changed by karmor
+const fieldManager = "karmor"
+
+// short names of the kinds accepted by --kind
+var kindAliases = map[string]string{
+	"ksp":                    KindPolicy,
+	"policy":                 KindPolicy,
+	"kubearmorpolicy":        KindPolicy,
+	"hsp":                    KindHostPolicy,
+	"hostpolicy":             KindHostPolicy,
+	"kubearmorhostpolicy":    KindHostPolicy,
+	"csp":                    KindClusterPolicy,
+	"clusterpolicy":          KindClusterPolicy,
+	"kubearmorclusterpolicy": KindClusterPolicy,
+}
+
+// Options for karmor policy
+type Options struct {
+	Files     []string
+	Namespace string
+	Labels    string
+	Kinds     []string
+	Output    string
+	DryRun    bool
+}
+
+// KindOf returns the policy kind of a kind name or alias such as ksp
+func KindOf(name string) (string, error) {
+	kind, ok := kindAliases[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown policy kind %s, use ksp, hsp or csp", name)
+	}
+	return kind, nil
+}
+
+// kinds returns the requested kinds, all of them by default
+func (o Options) kinds() ([]string, error) {
+	if len(o.Kinds) == 0 {
+		return []string{KindPolicy, KindHostPolicy, KindClusterPolicy}, nil
+	}
+	var kinds []string
+	for _, k := range o.Kinds {
+		kind, err := KindOf(k)
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func (o Options) dryRun() []string {
+	if o.DryRun {
+		return []string{v1.DryRunAll}
+	}
+	return nil
+}
+
+// document policy read from a manifest, as json
+type document struct {
+	kind      string
+	name      string
+	namespace string
+	data      []byte
+}
+
+func (d document) String() string {
+	if d.namespace == "" {
+		return fmt.Sprintf("%s %s", d.kind, d.name)
+	}
+	return fmt.Sprintf("%s %s/%s", d.kind, d.namespace, d.name)
+}
+
+// readFiles reads the yaml and json files of a path, a file, a directory or stdin ("-")
+func readFiles(path string) ([][]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return [][]byte{data}, err
+	}
+	var files [][]byte
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		// files named explicitly are read whatever their extension
+		if p != path {
+			switch filepath.Ext(p) {
+			case ".yaml", ".yml", ".json":
+			default:
+				return nil
+			}
+		}
+		data, err := os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		files = append(files, data)
+		return nil
+	})
+	return files, err
+}
+
+// readDocuments reads the KubeArmor policies of the paths, other resources are skipped.
+// Namespaced policies without a namespace are put in namespace, default if empty.
+func readDocuments(paths []string, namespace string) ([]document, error) {
+	var docs []document
+	for _, path := range paths {
+		files, err := readFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, data := range files {
+			reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+			for {
+				raw, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", path, err)
+				}
+				if len(bytes.TrimSpace(raw)) == 0 {
+					continue
+				}
+				doc, err := parseDocument(raw, namespace)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", path, err)
+				}
+				if doc == nil {
+					continue
+				}
+				docs = append(docs, *doc)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// parseDocument returns nil for the resources that are not KubeArmor policies
+func parseDocument(raw []byte, namespace string) (*document, error) {
+	data, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+	var header struct {
+		v1.TypeMeta   `json:",inline"`
+		v1.ObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	switch header.Kind {
+	case KindPolicy, KindHostPolicy, KindClusterPolicy:
+	default:
+		log.WithFields(log.Fields{"kind": header.Kind, "name": header.Name}).Warn("skipping resource that is not a KubeArmor policy")
+		return nil, nil
+	}
+	if header.Name == "" {
+		return nil, fmt.Errorf("%s without a name", header.Kind)
+	}
+	doc := &document{kind: header.Kind, name: header.Name, data: data}
+	if header.Kind == KindPolicy {
+		doc.namespace = header.Namespace
+		if namespace != "" && doc.namespace != "" && doc.namespace != namespace {
+			return nil, fmt.Errorf("the namespace %s of %s does not match --namespace %s", doc.namespace, doc.name, namespace)
+		}
+		if doc.namespace == "" {
+			doc.namespace = namespace
+		}
+		if doc.namespace == "" {
+			doc.namespace = "default"
+		}
+	}
+	return doc, nil
+}
+
+// applyDocument creates or updates the policy, it returns the change made
+func applyDocument(c *k8s.Client, doc document, o Options) (string, error) {
+	ctx := context.Background()
+	create := v1.CreateOptions{DryRun: o.dryRun(), FieldManager: fieldManager}
+	update := v1.UpdateOptions{DryRun: o.dryRun(), FieldManager: fieldManager}
+
+	switch doc.kind {
+	case KindPolicy:
+		var p pol.KubeArmorPolicy
+		if err := json.Unmarshal(doc.data, &p); err != nil {
+			return "", err
+		}
+		p.Namespace = doc.namespace
+		iface := c.KSPClientset.KubeArmorPolicies(p.Namespace)
+		live, err := iface.Get(ctx, p.Name, v1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			_, err = iface.Create(ctx, &p, create)
+			return "created", err
+		}
+		if err != nil {
+			return "", err
+		}
+		if reflect.DeepEqual(live.Spec, p.Spec) && reflect.DeepEqual(live.Labels, p.Labels) {
+			return "unchanged", nil
+		}
+		p.ResourceVersion = live.ResourceVersion
+		_, err = iface.Update(ctx, &p, update)
+		return "configured", err
+
+	case KindHostPolicy:
+		var p pol.KubeArmorHostPolicy
+		if err := json.Unmarshal(doc.data, &p); err != nil {
+			return "", err
+		}
+		iface := c.KSPClientset.KubeArmorHostPolicies()
+		live, err := iface.Get(ctx, p.Name, v1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			_, err = iface.Create(ctx, &p, create)
+			return "created", err
+		}
+		if err != nil {
+			return "", err
+		}
+		if reflect.DeepEqual(live.Spec, p.Spec) && reflect.DeepEqual(live.Labels, p.Labels) {
+			return "unchanged", nil
+		}
+		p.ResourceVersion = live.ResourceVersion
+		_, err = iface.Update(ctx, &p, update)
+		return "configured", err
+
+	default:
+		p := &unstructured.Unstructured{}
+		if err := p.UnmarshalJSON(doc.data); err != nil {
+			return "", err
+		}
+		iface := c.DynamicClientset.Resource(ClusterPolicyResource)
+		live, err := iface.Get(ctx, p.GetName(), v1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			_, err = iface.Create(ctx, p, create)
+			return "created", err
+		}
+		if err != nil {
+			return "", err
+		}
+		if reflect.DeepEqual(live.Object["spec"], p.Object["spec"]) && reflect.DeepEqual(live.GetLabels(), p.GetLabels()) {
+			return "unchanged", nil
+		}
+		p.SetResourceVersion(live.GetResourceVersion())
+		_, err = iface.Update(ctx, p, update)
+		return "configured", err
+	}
+}
+
+// Apply creates or updates the policies of the files. All the files are read
+// before any policy is applied.
+func Apply(c *k8s.Client, o Options) error {
+	docs, err := readDocuments(o.Files, o.Namespace)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return errors.New("no KubeArmor policies found")
+	}
+	suffix := ""
+	if o.DryRun {
+		suffix = " (dry run)"
+	}
+	for _, doc := range docs {
+		change, err := applyDocument(c, doc, o)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s: %w", doc, err)
+		}
+		color.Green("%s %s%s", doc, change, suffix)
+	}
+	return nil
+}
+
+// deletePolicy deletes a policy, the namespace is ignored for the cluster-wide kinds
+func deletePolicy(c *k8s.Client, kind, namespace, name string, o Options) error {
+	ctx := context.Background()
+	opts := v1.DeleteOptions{DryRun: o.dryRun()}
+	switch kind {
+	case KindPolicy:
+		return c.KSPClientset.KubeArmorPolicies(namespace).Delete(ctx, name, opts)
+	case KindHostPolicy:
+		return c.KSPClientset.KubeArmorHostPolicies().Delete(ctx, name, opts)
+	default:
+		return c.DynamicClientset.Resource(ClusterPolicyResource).Delete(ctx, name, opts)
+	}
+}
+
+// Delete deletes the policies of the files, or else the named policies of
+// the first kind given, KubeArmorPolicy by default
+func Delete(c *k8s.Client, names []string, o Options) error {
+	var docs []document
+	if len(o.Files) > 0 {
+		var err error
+		if docs, err = readDocuments(o.Files, o.Namespace); err != nil {
+			return err
+		}
+	} else {
+		if len(names) == 0 {
+			return errors.New("requires policy names or --file")
+		}
+		kind := KindPolicy
+		if len(o.Kinds) > 0 {
+			var err error
+			if kind, err = KindOf(o.Kinds[0]); err != nil {
+				return err
+			}
+		}
+		namespace := ""
+		if kind == KindPolicy {
+			namespace = o.Namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+		}
+		for _, name := range names {
+			docs = append(docs, document{kind: kind, name: name, namespace: namespace})
+		}
+	}
+
+	suffix := ""
+	if o.DryRun {
+		suffix = " (dry run)"
+	}
+	var errs []error
+	for _, doc := range docs {
+		if err := deletePolicy(c, doc.kind, doc.namespace, doc.name, o); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", doc, err))
+			continue
+		}
+		color.Green("%s deleted%s", doc, suffix)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,228 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kspfake "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/client/clientset/versioned/fake"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testPolicies = `apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: block-apt
+spec:
+  selector:
+    matchLabels:
+      app: nginx
+  process:
+    matchPaths:
+    - path: /usr/bin/apt
+  action: Block
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-policy
+---
+apiVersion: security.kubearmor.com/v1
+kind: KubeArmorHostPolicy
+metadata:
+  name: host-audit-shadow
+spec:
+  nodeSelector:
+    matchLabels:
+      kubernetes.io/os: linux
+  file:
+    matchPaths:
+    - path: /etc/shadow
+  action: Audit
+---
+apiVersion: security.kubearmor.com/v1
+kind: KubeArmorClusterPolicy
+metadata:
+  name: cluster-block-sh
+spec:
+  selector:
+    matchExpressions:
+    - key: namespace
+      operator: NotIn
+      values:
+      - kube-system
+  process:
+    matchPaths:
+    - path: /bin/sh
+  action: Block
+`
+
+func testPod(namespace, name string, labels map[string]string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func testClient() *k8s.Client {
+	return &k8s.Client{
+		K8sClientset: fake.NewSimpleClientset(
+			testPod("default", "nginx-1", map[string]string{"app": "nginx"}, corev1.PodRunning),
+			testPod("default", "nginx-2", map[string]string{"app": "nginx"}, corev1.PodPending),
+			testPod("default", "redis", map[string]string{"app": "redis"}, corev1.PodRunning),
+			testPod("kube-system", "coredns", map[string]string{"k8s-app": "kube-dns"}, corev1.PodRunning),
+			&corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "node-1", Labels: map[string]string{"kubernetes.io/os": "linux"}}},
+		),
+		KSPClientset: kspfake.NewSimpleClientset().SecurityV1(),
+		DynamicClientset: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{ClusterPolicyResource: "KubeArmorClusterPolicyList"}),
+	}
+}
+
+func writePolicies(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policies.yaml"), []byte(testPolicies), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("kind: KubeArmorPolicy"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReadDocuments(t *testing.T) {
+	docs, err := readDocuments([]string{writePolicies(t)}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, d := range docs {
+		names = append(names, d.String())
+	}
+	want := "KubeArmorPolicy default/block-apt,KubeArmorHostPolicy host-audit-shadow,KubeArmorClusterPolicy cluster-block-sh"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, err := readDocuments([]string{writePolicies(t)}, "nginx"); err != nil {
+		t.Errorf("policies without a namespace should go to --namespace: %v", err)
+	}
+	doc, err := parseDocument([]byte("kind: KubeArmorPolicy\nmetadata:\n  name: p\n  namespace: a\n"), "b")
+	if err == nil {
+		t.Errorf("expected a namespace mismatch error, got %+v", doc)
+	}
+}
+
+func TestApplyListDelete(t *testing.T) {
+	c := testClient()
+	o := Options{Files: []string{writePolicies(t)}}
+	if err := Apply(c, o); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := readDocuments(o.Files, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range docs {
+		if change, err := applyDocument(c, d, o); err != nil || change != "unchanged" {
+			t.Errorf("%s: expected unchanged, got %s %v", d, change, err)
+		}
+	}
+	docs[0].data = bytes.Replace(docs[0].data, []byte("Block"), []byte("Audit"), 1)
+	if change, err := applyDocument(c, docs[0], o); err != nil || change != "configured" {
+		t.Errorf("expected configured, got %s %v", change, err)
+	}
+
+	policies, err := List(c, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, p := range policies {
+		status[p.Name] = p.Status
+	}
+	for name, want := range map[string]string{
+		"block-apt":         "2 pods, 1 running",
+		"host-audit-shadow": "1 node",
+		"cluster-block-sh":  "3 pods, 2 running",
+	} {
+		if status[name] != want {
+			t.Errorf("%s: expected status %q, got %q", name, want, status[name])
+		}
+	}
+
+	policies, err = List(c, Options{Namespace: "kube-system"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 0 {
+		t.Errorf("expected no policies in kube-system, got %+v", policies)
+	}
+
+	if _, err := Get(c, []string{"block-apt", "missing"}, Options{}); err == nil {
+		t.Error("expected an error for the missing policy")
+	}
+
+	if err := Delete(c, []string{"block-apt"}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(c, nil, Options{Files: o.Files}); err == nil {
+		t.Error("expected an error deleting the policy deleted already")
+	}
+	list, err := c.DynamicClientset.Resource(ClusterPolicyResource).List(context.Background(), v1.ListOptions{})
+	if err != nil || len(list.Items) != 0 {
+		t.Errorf("expected the cluster policy to be deleted, got %d %v", len(list.Items), err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	c := testClient()
+	if err := Apply(c, Options{Files: []string{writePolicies(t)}}); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := List(c, Options{Kinds: []string{"ksp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Print(&out, policies, OutputTable); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"STATUS", "block-apt", "app=nginx", "2 pods, 1 running"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("table without %q:\n%s", s, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := Print(&out, policies, OutputYAML); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := readDocuments([]string{writeFile(t, out.Bytes())}, "")
+	if err != nil || len(docs) != 1 || docs[0].kind != KindPolicy {
+		t.Errorf("yaml output should apply back, got %+v %v", docs, err)
+	}
+
+	if err := ValidateOutput("wide"); err == nil {
+		t.Error("expected an unknown output error")
+	}
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	f := filepath.Join(t.TempDir(), "out")
+	if err := os.WriteFile(f, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -14,10 +14,19 @@ type keyMap struct {
 	Arrow  key.Binding
 	MaxRow key.Binding
 	Filter key.Binding
+	Enter  key.Binding
+	Back   key.Binding
+	Copy   key.Binding
+	Write  key.Binding
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
-	return [][]key.Binding{{k.Quit, k.Tab, k.Arrow, k.MaxRow, k.Filter}}
+	return [][]key.Binding{{k.Quit, k.Tab, k.Arrow, k.MaxRow, k.Filter, k.Enter}}
+}
+
+// DetailHelp key bindings shown in the event detail view
+func (k keyMap) DetailHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Quit, k.Arrow, k.Copy, k.Write, k.Back}}
 }
 
 var keys = keyMap{
@@ -41,4 +50,20 @@ var keys = keyMap{
 		key.WithKeys(""),
 		key.WithHelp("", "(/) To filter the tables, Press <Esc> to clear filter"),
 	),
+	Enter: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("", "(Enter) Show the events behind the selected row"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc", "backspace"),
+		key.WithHelp("", "(Esc) Back to the table"),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("", "(c) Copy event as JSON"),
+	),
+	Write: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("", "(w) Write event as JSON to Profile_Summary/"),
+	),
 }
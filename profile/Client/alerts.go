@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package profileclient
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/evertras/bubble-table/table"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	log "github.com/sirupsen/logrus"
+)
+
+var blockedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+// AlertProfile Row Data to display for blocked/audited events
+type AlertProfile struct {
+	PolicyName    string `json:"policy-name"`
+	Severity      string `json:"severity"`
+	Action        string `json:"action"`
+	Namespace     string `json:"namespace"`
+	ContainerName string `json:"container-name"`
+	Process       string `json:"process"`
+	Operation     string `json:"operation"`
+	Resource      string `json:"resource"`
+	Result        string `json:"result"`
+	Count         int    `json:"count"`
+	Time          string `json:"time"`
+}
+
+func generateAlertColumns() []table.Column {
+	return []table.Column{
+		table.NewFlexColumn(ColumnPolicyName, "Policy", 4).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnSeverity, "Severity", 1).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnAction, "Action", 1).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnNamespace, "Namespace", 2).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnContainerName, "ContainerName", 3).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnProcessName, "ProcessName", 3).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnOperation, "Operation", 1).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnResource, "Resource", 5).WithStyle(
+			lipgloss.NewStyle().
+				Foreground(lipgloss.Color("202")).
+				Align(lipgloss.Center)).WithFiltered(true),
+		table.NewFlexColumn(ColumnResult, "Result", 2).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnCount, "Count", 1).WithStyle(ColumnStyle).WithFiltered(true),
+		table.NewFlexColumn(ColumnTimestamp, "TimeStamp", 3).WithStyle(ColumnStyle),
+	}
+}
+
+// generateAlertRows aggregates alerts per policy and enforcement outcome
+func generateAlertRows(alerts []*pb.Alert) []table.Row {
+	var rows []table.Row
+	var jsondata []AlertProfile
+	counts := map[AlertProfile]int{}
+	times := map[AlertProfile]string{}
+	for _, alert := range alerts {
+		if !matchFilter(alert.NamespaceName, alert.PodName, alert.ContainerName) {
+			continue
+		}
+		resource := alert.Resource
+		if alert.Operation == "Syscall" {
+			resource = alert.Data
+		}
+		p := AlertProfile{
+			PolicyName:    alert.PolicyName,
+			Severity:      alert.Severity,
+			Action:        alert.Action,
+			Namespace:     alert.NamespaceName,
+			ContainerName: alert.ContainerName,
+			Process:       alert.ProcessName,
+			Operation:     alert.Operation,
+			Resource:      resource,
+			Result:        alert.Result,
+		}
+		counts[p]++
+		if times[p] == "" || isLaterTimestamp(alert.UpdatedTime, times[p]) {
+			times[p] = alert.UpdatedTime
+		}
+	}
+
+	for p, count := range counts {
+		updated := times[p]
+		row := table.NewRow(table.RowData{
+			ColumnPolicyName:    p.PolicyName,
+			ColumnSeverity:      p.Severity,
+			ColumnAction:        p.Action,
+			ColumnNamespace:     p.Namespace,
+			ColumnContainerName: p.ContainerName,
+			ColumnProcessName:   p.Process,
+			ColumnOperation:     p.Operation,
+			ColumnResource:      p.Resource,
+			ColumnResult:        p.Result,
+			ColumnCount:         count,
+			ColumnTimestamp:     updated,
+		})
+		if p.Action == "Block" {
+			row = row.WithStyle(blockedStyle)
+		}
+		rows = append(rows, row)
+
+		p.Count = count
+		p.Time = updated
+		jsondata = append(jsondata, p)
+	}
+
+	if o1.Save && len(jsondata) > 0 {
+		saveAlertsJSON(jsondata)
+	}
+
+	return rows
+}
+
+func saveAlertsJSON(data []AlertProfile) {
+	jsonByte, err := json.MarshalIndent(data, " ", "   ")
+	if err != nil {
+		log.WithError(err).Error("failed to marshal alerts")
+		return
+	}
+	filepath := "Profile_Summary/"
+	if err := os.MkdirAll(filepath, os.ModePerm); err != nil {
+		log.WithError(err).Error("failed to create profile summary directory")
+		return
+	}
+	if err := os.WriteFile(filepath+"Alerts.json", jsonByte, 0600); err != nil {
+		log.WithError(err).Error("failed to write alerts summary")
+	}
+}
@@ -0,0 +1,129 @@
+package profileclient
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/evertras/bubble-table/table"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+)
+
+// rowSummaries formats the rows as "key|...|count@time", sorted as the rows come from a map
+func rowSummaries(rows []table.Row, keys ...string) []string {
+	var out []string
+	for _, row := range rows {
+		s := ""
+		for _, k := range keys {
+			s += fmt.Sprintf("%v|", row.Data[k])
+		}
+		out = append(out, fmt.Sprintf("%s%v@%v", s, row.Data[ColumnCount], row.Data[ColumnTimestamp]))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func setFilter(t *testing.T, o Options) {
+	saved := o1
+	o1 = o
+	t.Cleanup(func() { o1 = saved })
+}
+
+func TestGenerateAlertRows(t *testing.T) {
+	alerts := []*pb.Alert{
+		{PolicyName: "block-sh", Severity: "5", Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Permission denied", UpdatedTime: "2023-10-01T10:00:00Z"},
+		{PolicyName: "block-sh", Severity: "5", Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Permission denied", UpdatedTime: "2023-10-01T12:00:00Z"},
+		{PolicyName: "block-sh", Severity: "5", Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Permission denied", UpdatedTime: "2023-10-01T11:00:00Z"},
+		{PolicyName: "audit-passwd", Severity: "3", Action: "Audit", NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/bin/cat", Operation: "File", Resource: "/etc/passwd", Result: "Passed", UpdatedTime: "2023-10-01T10:00:00Z"},
+		{PolicyName: "audit-setuid", Severity: "2", Action: "Audit", NamespaceName: "mysql", PodName: "mysql-6c4dbd6c79-9sdrq", ContainerName: "mysql", ProcessName: "/usr/sbin/mysqld", Operation: "Syscall", Resource: "/usr/sbin/mysqld", Data: "syscall=SYS_SETUID", Result: "Passed", UpdatedTime: "2023-10-01T10:00:00Z"},
+	}
+	keys := []string{ColumnPolicyName, ColumnAction, ColumnNamespace, ColumnOperation, ColumnResource}
+
+	tests := []struct {
+		name   string
+		filter Options
+		want   []string
+	}{
+		{
+			name: "aggregated per policy and outcome",
+			want: []string{
+				"audit-passwd|Audit|wordpress|File|/etc/passwd|1@2023-10-01T10:00:00Z",
+				"audit-setuid|Audit|mysql|Syscall|syscall=SYS_SETUID|1@2023-10-01T10:00:00Z",
+				"block-sh|Block|wordpress|Process|/bin/sh|3@2023-10-01T12:00:00Z",
+			},
+		},
+		{
+			name:   "filtered by namespace",
+			filter: Options{Namespace: "mysql"},
+			want: []string{
+				"audit-setuid|Audit|mysql|Syscall|syscall=SYS_SETUID|1@2023-10-01T10:00:00Z",
+			},
+		},
+		{
+			name:   "filtered by container",
+			filter: Options{Container: "wordpress"},
+			want: []string{
+				"audit-passwd|Audit|wordpress|File|/etc/passwd|1@2023-10-01T10:00:00Z",
+				"block-sh|Block|wordpress|Process|/bin/sh|3@2023-10-01T12:00:00Z",
+			},
+		},
+		{
+			name:   "no match",
+			filter: Options{Pod: "redis"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFilter(t, tt.filter)
+			got := rowSummaries(generateAlertRows(alerts), keys...)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected rows %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGenerateCapabilitiesRows(t *testing.T) {
+	logs := []pb.Log{
+		{NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "Capabilities", Resource: "CAP_NET_BIND_SERVICE", Result: "Passed", UpdatedTime: "2023-10-01T10:00:00Z"},
+		{NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "Capabilities", Resource: "CAP_NET_BIND_SERVICE", Result: "Passed", UpdatedTime: "2023-10-01T11:00:00Z"},
+		{NamespaceName: "mysql", PodName: "mysql-6c4dbd6c79-9sdrq", ContainerName: "mysql", ProcessName: "/usr/sbin/mysqld", Operation: "Capabilities", Resource: "CAP_SETUID", Result: "Passed", UpdatedTime: "2023-10-01T10:00:00Z"},
+		{NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "File", Resource: "/etc/passwd", Result: "Passed", UpdatedTime: "2023-10-01T10:00:00Z"},
+	}
+	alerts := []*pb.Alert{
+		{Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/bin/ping", Operation: "Capabilities", Resource: "CAP_NET_RAW", Result: "Permission denied", UpdatedTime: "2023-10-01T10:00:00Z"},
+		{Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-7c966bfc4d-xz2kq", ContainerName: "wordpress", ProcessName: "/bin/sh", Operation: "Process", Resource: "/bin/sh", Result: "Permission denied", UpdatedTime: "2023-10-01T10:00:00Z"},
+	}
+	keys := []string{ColumnNamespace, ColumnProcessName, ColumnResource, ColumnAction}
+
+	tests := []struct {
+		name   string
+		filter Options
+		want   []string
+	}{
+		{
+			name: "capability logs and alerts",
+			want: []string{
+				"mysql|/usr/sbin/mysqld|CAP_SETUID||1@2023-10-01T10:00:00Z",
+				"wordpress|/bin/ping|CAP_NET_RAW|Block|1@2023-10-01T10:00:00Z",
+				"wordpress|/usr/sbin/apache2|CAP_NET_BIND_SERVICE||2@2023-10-01T11:00:00Z",
+			},
+		},
+		{
+			name:   "filtered by namespace",
+			filter: Options{Namespace: "mysql"},
+			want: []string{
+				"mysql|/usr/sbin/mysqld|CAP_SETUID||1@2023-10-01T10:00:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFilter(t, tt.filter)
+			got := rowSummaries(generateRowsFromData(logs, alerts, "Capabilities"), keys...)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected rows %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package profileclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/evertras/bubble-table/table"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	profile "github.com/kubearmor/kubearmor-client/profile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Detail view column keys
+const (
+	ColumnPodName       = "PodName"
+	ColumnPID           = "PID"
+	ColumnPPID          = "PPID"
+	ColumnParentProcess = "ParentProcess"
+	columnEventIndex    = "eventIndex"
+)
+
+var jsonStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("12")).
+	Padding(0, 1)
+
+// telemetryEvent fields common to KubeArmor logs and alerts
+type telemetryEvent interface {
+	proto.Message
+	GetTimestamp() int64
+	GetUpdatedTime() string
+	GetNamespaceName() string
+	GetPodName() string
+	GetContainerName() string
+	GetHostPID() int32
+	GetPID() int32
+	GetPPID() int32
+	GetParentProcessName() string
+	GetProcessName() string
+	GetOperation() string
+	GetResource() string
+	GetData() string
+	GetResult() string
+}
+
+func actionOf(ev telemetryEvent) string {
+	if alert, ok := ev.(*pb.Alert); ok {
+		return alert.Action
+	}
+	return ""
+}
+
+// eventDetail lists the individual events behind an aggregated profile row
+type eventDetail struct {
+	title  string
+	events []telemetryEvent
+	table  table.Model
+	status string
+}
+
+func generateDetailColumns() []table.Column {
+	return []table.Column{
+		table.NewFlexColumn(ColumnTimestamp, "TimeStamp", 3).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnPodName, "PodName", 3).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnPID, "PID", 1).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnPPID, "PPID", 1).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnParentProcess, "ParentProcess", 3).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnProcessName, "ProcessName", 3).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnResource, "Resource", 5).WithStyle(
+			lipgloss.NewStyle().
+				Foreground(lipgloss.Color("202")).
+				Align(lipgloss.Center)),
+		table.NewFlexColumn(ColumnResult, "Result", 2).WithStyle(ColumnStyle),
+		table.NewFlexColumn(ColumnAction, "Action", 1).WithStyle(ColumnStyle),
+	}
+}
+
+func newEventDetail(title string, events []telemetryEvent, width, pageSize int) *eventDetail {
+	var rows []table.Row
+	for i, ev := range events {
+		rows = append(rows, table.NewRow(table.RowData{
+			ColumnTimestamp:     ev.GetUpdatedTime(),
+			ColumnPodName:       ev.GetPodName(),
+			ColumnPID:           ev.GetPID(),
+			ColumnPPID:          ev.GetPPID(),
+			ColumnParentProcess: ev.GetParentProcessName(),
+			ColumnProcessName:   ev.GetProcessName(),
+			ColumnResource:      ev.GetResource(),
+			ColumnResult:        ev.GetResult(),
+			ColumnAction:        actionOf(ev),
+			columnEventIndex:    i,
+		}))
+	}
+	return &eventDetail{
+		title:  title,
+		events: events,
+		table: table.New(generateDetailColumns()).
+			WithRows(rows).
+			WithBaseStyle(styleBase).
+			WithPageSize(pageSize).
+			WithTargetWidth(width).
+			Focused(true),
+	}
+}
+
+// selected returns the event under the cursor
+func (d *eventDetail) selected() telemetryEvent {
+	idx, ok := d.table.HighlightedRow().Data[columnEventIndex].(int)
+	if !ok || idx >= len(d.events) {
+		return nil
+	}
+	return d.events[idx]
+}
+
+func eventJSON(ev telemetryEvent) ([]byte, error) {
+	return protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(ev)
+}
+
+// copySelected copies the selected event as JSON to the clipboard
+func (d *eventDetail) copySelected() {
+	ev := d.selected()
+	if ev == nil {
+		return
+	}
+	data, err := eventJSON(ev)
+	if err != nil {
+		d.status = fmt.Sprintf("failed to marshal event: %s", err.Error())
+		return
+	}
+	if err := clipboard.WriteAll(string(data)); err != nil {
+		d.status = fmt.Sprintf("failed to copy event: %s", err.Error())
+		return
+	}
+	d.status = "event copied to clipboard"
+}
+
+// writeSelected writes the selected event as JSON under Profile_Summary/
+func (d *eventDetail) writeSelected() {
+	ev := d.selected()
+	if ev == nil {
+		return
+	}
+	data, err := eventJSON(ev)
+	if err != nil {
+		d.status = fmt.Sprintf("failed to marshal event: %s", err.Error())
+		return
+	}
+	dir := "Profile_Summary/"
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		d.status = fmt.Sprintf("failed to create %s: %s", dir, err.Error())
+		return
+	}
+	out := filepath.Join(dir, fmt.Sprintf("event-%d-%d.json", ev.GetTimestamp(), ev.GetHostPID()))
+	if err := os.WriteFile(out, data, 0600); err != nil {
+		d.status = fmt.Sprintf("failed to write event: %s", err.Error())
+		return
+	}
+	d.status = fmt.Sprintf("event written to %s", out)
+}
+
+func (d *eventDetail) View() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(helptheme).Render(
+		fmt.Sprintf("%s (%d events)", d.title, len(d.events)))
+	raw := ""
+	if ev := d.selected(); ev != nil {
+		if data, err := eventJSON(ev); err == nil {
+			raw = jsonStyle.Render(string(data))
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		d.table.View(),
+		raw,
+		lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(d.status),
+	)
+}
+
+func hasResource(row table.RowData, resource string) bool {
+	aggregated, _ := row[ColumnResource].(string)
+	return resource == aggregated ||
+		(strings.HasSuffix(aggregated, "/") && strings.HasPrefix(resource, aggregated)) ||
+		strings.HasPrefix(resource, aggregated+"/")
+}
+
+// rowMatches checks whether the event was aggregated into the row, events of
+// pods left out by the filter never are
+func rowMatches(row table.RowData, ev telemetryEvent) bool {
+	if !matchFilter(ev.GetNamespaceName(), ev.GetPodName(), ev.GetContainerName()) {
+		return false
+	}
+	resource := ev.GetResource()
+	if ev.GetOperation() == "Syscall" {
+		resource = ev.GetData()
+	}
+	return row[ColumnNamespace] == ev.GetNamespaceName() &&
+		row[ColumnContainerName] == ev.GetContainerName() &&
+		row[ColumnProcessName] == ev.GetProcessName() &&
+		row[ColumnResult] == ev.GetResult() &&
+		row[ColumnAction] == actionOf(ev) &&
+		hasResource(row, resource)
+}
+
+// eventsForRow collects the telemetry events aggregated into the given row
+func eventsForRow(state sessionState, row table.RowData) []telemetryEvent {
+	var events []telemetryEvent
+	profile.TelMutex.RLock()
+	defer profile.TelMutex.RUnlock()
+
+	if state == alertview {
+		for _, alert := range profile.AlertTelemetry {
+			if row[ColumnPolicyName] == alert.PolicyName &&
+				row[ColumnOperation] == alert.Operation &&
+				row[ColumnSeverity] == alert.Severity &&
+				rowMatches(row, alert) {
+				events = append(events, alert)
+			}
+		}
+		return events
+	}
+
+	operation := operationOf(state)
+	for i := range profile.Telemetry {
+		entry := &profile.Telemetry[i]
+		if entry.Operation == operation && rowMatches(row, entry) {
+			events = append(events, entry)
+		}
+	}
+	for _, alert := range profile.AlertTelemetry {
+		if alert.Operation == operation && rowMatches(row, alert) {
+			events = append(events, alert)
+		}
+	}
+	return events
+}
+
+func operationOf(state sessionState) string {
+	switch state {
+	case fileview:
+		return "File"
+	case networkview:
+		return "Network"
+	case syscallview:
+		return "Syscall"
+	case capabilitiesview:
+		return "Capabilities"
+	}
+	return "Process"
+}
@@ -0,0 +1,100 @@
+package profileclient
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/evertras/bubble-table/table"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	profile "github.com/kubearmor/kubearmor-client/profile"
+)
+
+func setTelemetry(t *testing.T, logs []pb.Log, alerts []*pb.Alert) {
+	profile.TelMutex.Lock()
+	savedLogs, savedAlerts := profile.Telemetry, profile.AlertTelemetry
+	profile.Telemetry, profile.AlertTelemetry = logs, alerts
+	profile.TelMutex.Unlock()
+	t.Cleanup(func() {
+		profile.TelMutex.Lock()
+		profile.Telemetry, profile.AlertTelemetry = savedLogs, savedAlerts
+		profile.TelMutex.Unlock()
+	})
+}
+
+func wordpressRow(process, resource, result, action string) table.RowData {
+	return table.RowData{
+		ColumnNamespace:     "wordpress",
+		ColumnContainerName: "wordpress",
+		ColumnProcessName:   process,
+		ColumnResource:      resource,
+		ColumnResult:        result,
+		ColumnAction:        action,
+	}
+}
+
+func TestEventsForRow(t *testing.T) {
+	// events are told apart by their HostPID
+	logs := []pb.Log{
+		{HostPID: 1, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Passed"},
+		{HostPID: 2, NamespaceName: "wordpress", PodName: "wordpress-2", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Passed"},
+		{HostPID: 3, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "File", Resource: "/var/www/html/index.php", Result: "Passed"},
+		{HostPID: 4, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "File", Resource: "/var/www/html/wp-config.php", Result: "Passed"},
+		{HostPID: 5, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "File", Resource: "/var/www/html2/index.php", Result: "Passed"},
+		{HostPID: 6, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "Network", Resource: "remoteip=10.0.0.1 port=3306 protocol=TCP", Result: "Passed"},
+		{HostPID: 7, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "Syscall", Resource: "/usr/sbin/apache2", Data: "syscall=SYS_SETUID", Result: "Passed"},
+		{HostPID: 8, NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/bin/ping", Operation: "Capabilities", Resource: "CAP_NET_RAW", Result: "Passed"},
+		{HostPID: 9, NamespaceName: "mysql", PodName: "mysql-1", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Passed"},
+	}
+	alerts := []*pb.Alert{
+		{HostPID: 11, PolicyName: "block-sh", Severity: "5", Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Permission denied"},
+		{HostPID: 12, PolicyName: "audit-sh", Severity: "2", Action: "Audit", NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh", Result: "Passed"},
+		{HostPID: 13, PolicyName: "block-raw", Severity: "5", Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/bin/ping", Operation: "Capabilities", Resource: "CAP_NET_RAW", Result: "Permission denied"},
+	}
+	setTelemetry(t, logs, alerts)
+
+	alertRow := func(policy, severity, operation string, row table.RowData) table.RowData {
+		row[ColumnPolicyName] = policy
+		row[ColumnSeverity] = severity
+		row[ColumnOperation] = operation
+		return row
+	}
+
+	tests := []struct {
+		name   string
+		state  sessionState
+		row    table.RowData
+		want   []int32
+		filter Options
+	}{
+		{"process allowed", processview, wordpressRow("/bin/bash", "/bin/sh", "Passed", ""), []int32{1, 2}, Options{}},
+		{"process allowed in the filtered pod", processview, wordpressRow("/bin/bash", "/bin/sh", "Passed", ""), []int32{2}, Options{Pod: "wordpress-2"}},
+		{"alert of another pod", alertview, alertRow("block-sh", "5", "Process", wordpressRow("/bin/bash", "/bin/sh", "Permission denied", "Block")), nil, Options{Pod: "wordpress-2"}},
+		{"process blocked", processview, wordpressRow("/bin/bash", "/bin/sh", "Permission denied", "Block"), []int32{11}, Options{}},
+		{"process audited", processview, wordpressRow("/bin/bash", "/bin/sh", "Passed", "Audit"), []int32{12}, Options{}},
+		{"file aggregated directory", fileview, wordpressRow("/usr/sbin/apache2", "/var/www/html/", "Passed", ""), []int32{3, 4}, Options{}},
+		{"file aggregated path", fileview, wordpressRow("/usr/sbin/apache2", "/var/www/html", "Passed", ""), []int32{3, 4}, Options{}},
+		{"file single path", fileview, wordpressRow("/usr/sbin/apache2", "/var/www/html/index.php", "Passed", ""), []int32{3}, Options{}},
+		{"network", networkview, wordpressRow("/usr/sbin/apache2", "remoteip=10.0.0.1 port=3306 protocol=TCP", "Passed", ""), []int32{6}, Options{}},
+		{"syscall by data", syscallview, wordpressRow("/usr/sbin/apache2", "syscall=SYS_SETUID", "Passed", ""), []int32{7}, Options{}},
+		{"syscall by resource", syscallview, wordpressRow("/usr/sbin/apache2", "/usr/sbin/apache2", "Passed", ""), nil, Options{}},
+		{"capabilities allowed", capabilitiesview, wordpressRow("/bin/ping", "CAP_NET_RAW", "Passed", ""), []int32{8}, Options{}},
+		{"capabilities blocked", capabilitiesview, wordpressRow("/bin/ping", "CAP_NET_RAW", "Permission denied", "Block"), []int32{13}, Options{}},
+		{"other operation tab", fileview, wordpressRow("/bin/bash", "/bin/sh", "Passed", ""), nil, Options{}},
+		{"alert", alertview, alertRow("block-sh", "5", "Process", wordpressRow("/bin/bash", "/bin/sh", "Permission denied", "Block")), []int32{11}, Options{}},
+		{"alert capability", alertview, alertRow("block-raw", "5", "Capabilities", wordpressRow("/bin/ping", "CAP_NET_RAW", "Permission denied", "Block")), []int32{13}, Options{}},
+		{"alert other policy", alertview, alertRow("block-bash", "5", "Process", wordpressRow("/bin/bash", "/bin/sh", "Permission denied", "Block")), nil, Options{}},
+		{"alert other severity", alertview, alertRow("audit-sh", "5", "Process", wordpressRow("/bin/bash", "/bin/sh", "Passed", "Audit")), nil, Options{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFilter(t, tt.filter)
+			var got []int32
+			for _, ev := range eventsForRow(tt.state, tt.row) {
+				got = append(got, ev.GetHostPID())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected events %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
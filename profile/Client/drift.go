@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package profileclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	profile "github.com/kubearmor/kubearmor-client/profile"
+	"github.com/olekukonko/tablewriter"
+)
+
+// DriftProfile behavior observed during profiling that is not part of the baseline
+type DriftProfile struct {
+	Namespace     string `json:"namespace"`
+	ContainerName string `json:"container-name"`
+	Operation     string `json:"operation"`
+	Process       string `json:"process"`
+	Resource      string `json:"resource"`
+	// Action of the policy that blocked or audited the behavior, empty for logs
+	Action string `json:"action,omitempty"`
+}
+
+// driftSummary collects the distinct behaviors of the logs and alerts not present in the baseline
+func driftSummary(data []pb.Log, alerts []*pb.Alert) []DriftProfile {
+	seen := map[DriftProfile]bool{}
+	var drifts []DriftProfile
+	add := func(ev telemetryEvent) {
+		if !matchFilter(ev.GetNamespaceName(), ev.GetPodName(), ev.GetContainerName()) || !baseline.IsDrift(ev) {
+			return
+		}
+		resource := ev.GetResource()
+		if ev.GetOperation() == "Syscall" {
+			resource = ev.GetData()
+		}
+		d := DriftProfile{
+			Namespace:     ev.GetNamespaceName(),
+			ContainerName: ev.GetContainerName(),
+			Operation:     ev.GetOperation(),
+			Process:       ev.GetProcessName(),
+			Resource:      resource,
+			Action:        actionOf(ev),
+		}
+		if seen[d] {
+			return
+		}
+		seen[d] = true
+		drifts = append(drifts, d)
+	}
+	for i := range data {
+		add(&data[i])
+	}
+	for _, alert := range alerts {
+		add(alert)
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		return fmt.Sprint(drifts[i]) < fmt.Sprint(drifts[j])
+	})
+	return drifts
+}
+
+// printDrift prints the behaviors observed during profiling that are not part of the baseline
+func printDrift(w io.Writer, drifts []DriftProfile) {
+	if len(drifts) == 0 {
+		fmt.Fprintln(w, "No drift from baseline detected")
+		return
+	}
+	fmt.Fprintf(w, "Detected %d new behavior(s) not in baseline:\n", len(drifts))
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Namespace", "Container", "Operation", "Process", "Resource", "Action"})
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, d := range drifts {
+		table.Append([]string{d.Namespace, d.ContainerName, d.Operation, d.Process, d.Resource, d.Action})
+	}
+	table.Render()
+}
+
+// writeDriftReport writes the drift in json format, an empty list if there is none
+func writeDriftReport(path string, drifts []DriftProfile) error {
+	if drifts == nil {
+		drifts = []DriftProfile{}
+	}
+	data, err := json.MarshalIndent(drifts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(path), data, 0600)
+}
+
+// reportDrift prints the drift of the profiled telemetry from the baseline and
+// writes the drift report if requested, returning the number of new behaviors
+func reportDrift(w io.Writer, reportFile string) (int, error) {
+	if baseline == nil {
+		return 0, nil
+	}
+	profile.TelMutex.RLock()
+	drifts := driftSummary(profile.Telemetry, profile.AlertTelemetry)
+	profile.TelMutex.RUnlock()
+	printDrift(w, drifts)
+	if reportFile != "" {
+		if err := writeDriftReport(reportFile, drifts); err != nil {
+			return len(drifts), fmt.Errorf("failed to write drift report %s: %w", reportFile, err)
+		}
+	}
+	return len(drifts), nil
+}
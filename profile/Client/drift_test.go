@@ -0,0 +1,76 @@
+package profileclient
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	profile "github.com/kubearmor/kubearmor-client/profile"
+)
+
+func setBaseline(t *testing.T, logs []pb.Log) {
+	saved := baseline
+	baseline = profile.NewBaseline(logs)
+	t.Cleanup(func() { baseline = saved })
+}
+
+func TestDriftSummary(t *testing.T) {
+	setFilter(t, Options{})
+	setBaseline(t, []pb.Log{
+		{NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", Operation: "Process", Resource: "/usr/sbin/apache2"},
+	})
+	logs := []pb.Log{
+		{NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "Process", Resource: "/usr/sbin/apache2"},
+		{NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "File", Resource: "/etc/passwd"},
+		{NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/usr/sbin/apache2", Operation: "File", Resource: "/etc/passwd"},
+	}
+	alerts := []*pb.Alert{
+		{Action: "Block", NamespaceName: "wordpress", PodName: "wordpress-1", ContainerName: "wordpress", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh"},
+		{Action: "Block", NamespaceName: "mysql", PodName: "mysql-1", ContainerName: "mysql", ProcessName: "/bin/bash", Operation: "Process", Resource: "/bin/sh"},
+	}
+
+	drifts := driftSummary(logs, alerts)
+	want := []DriftProfile{
+		{Namespace: "mysql", ContainerName: "mysql", Operation: "Process", Process: "/bin/bash", Resource: "/bin/sh", Action: "Block"},
+		{Namespace: "wordpress", ContainerName: "wordpress", Operation: "File", Process: "/usr/sbin/apache2", Resource: "/etc/passwd"},
+		{Namespace: "wordpress", ContainerName: "wordpress", Operation: "Process", Process: "/bin/bash", Resource: "/bin/sh", Action: "Block"},
+	}
+	if len(drifts) != len(want) {
+		t.Fatalf("expected drift %+v, got %+v", want, drifts)
+	}
+	for i := range want {
+		if drifts[i] != want[i] {
+			t.Errorf("expected drift %+v, got %+v", want[i], drifts[i])
+		}
+	}
+
+	setFilter(t, Options{Namespace: "mysql"})
+	if drifts := driftSummary(logs, alerts); len(drifts) != 1 || drifts[0].Namespace != "mysql" {
+		t.Errorf("drift not filtered by namespace %+v", drifts)
+	}
+
+	rows := generateRowsFromData(nil, alerts, "Process")
+	if len(rows) != 1 {
+		t.Fatalf("expected the mysql alert row, got %d rows", len(rows))
+	}
+	for _, row := range rows {
+		if row.Data[ColumnDrift] != "NEW" {
+			t.Errorf("blocked behavior not in baseline not flagged as drift %+v", row.Data)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "drift.json")
+	if err := writeDriftReport(path, want); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var report []DriftProfile
+	if err := json.Unmarshal(data, &report); err != nil || len(report) != len(want) {
+		t.Errorf("unexpected drift report %s: %v", data, err)
+	}
+}
@@ -7,6 +7,7 @@ package profileclient
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -31,8 +32,13 @@ const (
 	ColumnProcessName   = "ProcName"
 	ColumnResource      = "Resource"
 	ColumnResult        = "Result"
+	ColumnAction        = "Action"
+	ColumnPolicyName    = "PolicyName"
+	ColumnSeverity      = "Severity"
+	ColumnOperation     = "Operation"
 	ColumnCount         = "Count"
 	ColumnTimestamp     = "Timestamp"
+	ColumnDrift         = "Drift"
 )
 
 var errbuf bytes.Buffer
@@ -46,6 +52,8 @@ const (
 	fileview
 	syscallview
 	networkview
+	capabilitiesview
+	alertview
 )
 
 var (
@@ -56,6 +64,8 @@ var (
 	ColumnStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#00af00")).Align(lipgloss.Center).Bold(true)
 
+	driftStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
 	helptheme = lipgloss.AdaptiveColor{
 		Light: "#000000",
 		Dark:  "#ffffff",
@@ -69,14 +79,28 @@ type Options struct {
 	GRPC      string
 	Container string
 	Save      bool
+	// Baseline path of a baseline to highlight drift against
+	Baseline string
+	// SaveBaseline path to record the observed behavior into on exit
+	SaveBaseline string
+	// DriftReport path to write the drift from the baseline into on exit, in json format
+	DriftReport string
+	// Duration profiles without the TUI for this long, for non-interactive use
+	Duration time.Duration
 }
 
 // Model for main Bubble Tea
 type Model struct {
-	File     table.Model
-	Process  table.Model
-	Network  table.Model
-	Syscall  table.Model
+	File    table.Model
+	Process table.Model
+	Network table.Model
+	Syscall table.Model
+	// Capabilities table of capability usage
+	Capabilities table.Model
+	// Alerts table of blocked/audited events grouped by policy
+	Alerts table.Model
+	// detail drill-down of the selected row, nil when showing the tables
+	detail   *eventDetail
 	tabs     tea.Model
 	keys     keyMap
 	quitting bool
@@ -102,6 +126,8 @@ func waitForActivity() tea.Cmd {
 
 var o1 Options
 
+var baseline *profile.Baseline
+
 func generateColumns(Operation string) []table.Column {
 	CountCol := table.NewFlexColumn(ColumnCount, "Count", 1).WithStyle(ColumnStyle).WithFiltered(true)
 
@@ -118,17 +144,30 @@ func generateColumns(Operation string) []table.Column {
 
 	Result := table.NewFlexColumn(ColumnResult, "Result", 1).WithStyle(ColumnStyle).WithFiltered(true)
 
+	Action := table.NewFlexColumn(ColumnAction, "Action", 1).WithStyle(ColumnStyle).WithFiltered(true)
+
 	Timestamp := table.NewFlexColumn(ColumnTimestamp, "TimeStamp", 3).WithStyle(ColumnStyle)
 
-	return []table.Column{
+	columns := []table.Column{
 		Namespace,
 		ContainerName,
 		ProcName,
 		Resource,
 		Result,
+		Action,
 		CountCol,
 		Timestamp,
 	}
+
+	if baseline != nil {
+		Drift := table.NewFlexColumn(ColumnDrift, "Drift", 1).WithStyle(
+			lipgloss.NewStyle().
+				Foreground(lipgloss.Color("9")).
+				Align(lipgloss.Center).Bold(true)).WithFiltered(true)
+		columns = append(columns, Drift)
+	}
+
+	return columns
 }
 
 // Init calls initial functions if needed
@@ -141,13 +180,15 @@ func (m Model) Init() tea.Cmd {
 // NewModel initializates new bubbletea model
 func NewModel() Model {
 	model := Model{
-		File:    table.New(generateColumns("File")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
-		Process: table.New(generateColumns("Process")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
-		Network: table.New(generateColumns("Network")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
-		Syscall: table.New(generateColumns("Syscall")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
+		File:         table.New(generateColumns("File")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
+		Process:      table.New(generateColumns("Process")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
+		Network:      table.New(generateColumns("Network")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
+		Syscall:      table.New(generateColumns("Syscall")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
+		Capabilities: table.New(generateColumns("Capabilities")).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
+		Alerts:       table.New(generateAlertColumns()).WithBaseStyle(styleBase).WithPageSize(30).Filtered(true),
 		tabs: &tabs{
 			active: "Lip Gloss",
-			items:  []string{"Process", "File", "Network", "Syscall"},
+			items:  []string{"Process", "File", "Network", "Syscall", "Capabilities", "Blocked/Audited"},
 		},
 		keys:  keys,
 		help:  help.New(),
@@ -163,6 +204,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd  tea.Cmd
 		cmds []tea.Cmd
 	)
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.detail != nil {
+		return m.updateDetail(keyMsg)
+	}
 	m.tabs, _ = m.tabs.Update(msg)
 	cmds = append(cmds, cmd)
 
@@ -180,6 +224,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		}
 
+		active := m.activeTable()
+		if key.Matches(msg, m.keys.Enter) && !active.GetIsFilterInputFocused() {
+			row := active.HighlightedRow()
+			if len(row.Data) > 0 {
+				m.detail = newEventDetail(detailTitle(row.Data), eventsForRow(m.state, row.Data), m.width, active.PageSize())
+			}
+			return m, nil
+		}
+
 		switch msg.String() {
 
 		case "tab":
@@ -191,53 +244,43 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case networkview:
 				m.state = syscallview
 			case syscallview:
+				m.state = capabilitiesview
+			case capabilitiesview:
+				m.state = alertview
+			case alertview:
 				m.state = processview
 			}
 
 		case "u":
-			m.File = m.File.WithPageSize(m.File.PageSize() - 1)
-			m.Network = m.Network.WithPageSize(m.Network.PageSize() - 1)
-			m.Process = m.Process.WithPageSize(m.Process.PageSize() - 1)
-			m.Syscall = m.Syscall.WithPageSize(m.Syscall.PageSize() - 1)
+			for _, t := range m.allTables() {
+				*t = t.WithPageSize(t.PageSize() - 1)
+			}
 
 		case "i":
-			m.File = m.File.WithPageSize(m.File.PageSize() + 1)
-			m.Network = m.Network.WithPageSize(m.Network.PageSize() + 1)
-			m.Process = m.Process.WithPageSize(m.Process.PageSize() + 1)
-			m.Syscall = m.Syscall.WithPageSize(m.Syscall.PageSize() + 1)
+			for _, t := range m.allTables() {
+				*t = t.WithPageSize(t.PageSize() + 1)
+			}
 
 		}
 
-		switch m.state {
-		case processview:
-			m.Process = m.Process.Focused(true)
-			m.Process, cmd = m.Process.Update(msg)
-			cmds = append(cmds, cmd)
-		case fileview:
-			m.File = m.File.Focused(true)
-			m.File, cmd = m.File.Update(msg)
-			cmds = append(cmds, cmd)
-
-		case networkview:
-			m.Network = m.Network.Focused(true)
-			m.Network, cmd = m.Network.Update(msg)
-			cmds = append(cmds, cmd)
-
-		case syscallview:
-			m.Syscall = m.Syscall.Focused(true)
-			m.Syscall, cmd = m.Syscall.Update(msg)
-			cmds = append(cmds, cmd)
-		}
+		active = m.activeTable()
+		*active = active.Focused(true)
+		*active, cmd = active.Update(msg)
+		cmds = append(cmds, cmd)
 	case klog.EventInfo:
 		profile.TelMutex.RLock()
-		m.File = m.File.WithRows(generateRowsFromData(profile.Telemetry, "File")).WithColumns(generateColumns("File"))
+		m.File = m.File.WithRows(generateRowsFromData(profile.Telemetry, profile.AlertTelemetry, "File")).WithColumns(generateColumns("File"))
 		m.File = m.File.SortByAsc(ColumnNamespace).ThenSortByAsc(ColumnContainerName).ThenSortByAsc(ColumnProcessName).ThenSortByAsc(ColumnCount).ThenSortByAsc(ColumnResource)
-		m.Process = m.Process.WithRows(generateRowsFromData(profile.Telemetry, "Process")).WithColumns(generateColumns("Process"))
+		m.Process = m.Process.WithRows(generateRowsFromData(profile.Telemetry, profile.AlertTelemetry, "Process")).WithColumns(generateColumns("Process"))
 		m.Process = m.Process.SortByAsc(ColumnNamespace).ThenSortByAsc(ColumnContainerName).ThenSortByAsc(ColumnProcessName).ThenSortByAsc(ColumnCount).ThenSortByAsc(ColumnResource)
-		m.Network = m.Network.WithRows(generateRowsFromData(profile.Telemetry, "Network")).WithColumns(generateColumns("Network"))
+		m.Network = m.Network.WithRows(generateRowsFromData(profile.Telemetry, profile.AlertTelemetry, "Network")).WithColumns(generateColumns("Network"))
 		m.Network = m.Network.SortByAsc(ColumnNamespace).ThenSortByAsc(ColumnContainerName).ThenSortByAsc(ColumnProcessName).ThenSortByAsc(ColumnCount).ThenSortByAsc(ColumnResource)
-		m.Syscall = m.Syscall.WithRows(generateRowsFromData(profile.Telemetry, "Syscall")).WithColumns(generateColumns("Syscall"))
+		m.Syscall = m.Syscall.WithRows(generateRowsFromData(profile.Telemetry, profile.AlertTelemetry, "Syscall")).WithColumns(generateColumns("Syscall"))
 		m.Syscall = m.Syscall.SortByAsc(ColumnNamespace).ThenSortByAsc(ColumnContainerName).ThenSortByAsc(ColumnProcessName).ThenSortByAsc(ColumnCount).ThenSortByAsc(ColumnResource)
+		m.Capabilities = m.Capabilities.WithRows(generateRowsFromData(profile.Telemetry, profile.AlertTelemetry, "Capabilities")).WithColumns(generateColumns("Capabilities"))
+		m.Capabilities = m.Capabilities.SortByAsc(ColumnNamespace).ThenSortByAsc(ColumnContainerName).ThenSortByAsc(ColumnProcessName).ThenSortByAsc(ColumnCount).ThenSortByAsc(ColumnResource)
+		m.Alerts = m.Alerts.WithRows(generateAlertRows(profile.AlertTelemetry))
+		m.Alerts = m.Alerts.SortByAsc(ColumnPolicyName).ThenSortByAsc(ColumnNamespace).ThenSortByAsc(ColumnContainerName).ThenSortByAsc(ColumnProcessName).ThenSortByAsc(ColumnResource)
 		profile.TelMutex.RUnlock()
 
 		return m, waitForActivity()
@@ -248,10 +291,59 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) recalculateTable() {
-	m.File = m.File.WithTargetWidth(m.width)
-	m.Network = m.Network.WithTargetWidth(m.width)
-	m.Process = m.Process.WithTargetWidth(m.width)
-	m.Syscall = m.Syscall.WithTargetWidth(m.width)
+	for _, t := range m.allTables() {
+		*t = t.WithTargetWidth(m.width)
+	}
+}
+
+// updateDetail handles key presses while the detail view is open
+func (m Model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch {
+	case key.Matches(msg, m.keys.Quit):
+		m.quitting = true
+		return m, tea.Quit
+	case key.Matches(msg, m.keys.Back):
+		m.detail = nil
+		return m, nil
+	case key.Matches(msg, m.keys.Copy):
+		m.detail.copySelected()
+		return m, nil
+	case key.Matches(msg, m.keys.Write):
+		m.detail.writeSelected()
+		return m, nil
+	}
+	m.detail.table, cmd = m.detail.table.Update(msg)
+	return m, cmd
+}
+
+func detailTitle(row table.RowData) string {
+	title := fmt.Sprintf("%v/%v %v %v", row[ColumnNamespace], row[ColumnContainerName], row[ColumnProcessName], row[ColumnResource])
+	if policy, ok := row[ColumnPolicyName]; ok {
+		title = fmt.Sprintf("[%v] %s", policy, title)
+	}
+	return title
+}
+
+func (m *Model) allTables() []*table.Model {
+	return []*table.Model{&m.Process, &m.File, &m.Network, &m.Syscall, &m.Capabilities, &m.Alerts}
+}
+
+// activeTable returns the table of the currently selected tab
+func (m *Model) activeTable() *table.Model {
+	switch m.state {
+	case fileview:
+		return &m.File
+	case networkview:
+		return &m.Network
+	case syscallview:
+		return &m.Syscall
+	case capabilitiesview:
+		return &m.Capabilities
+	case alertview:
+		return &m.Alerts
+	}
+	return &m.Process
 }
 
 // View Renders Bubble Tea UI
@@ -260,43 +352,17 @@ func (m Model) View() string {
 	RowCount := lipgloss.JoinHorizontal(lipgloss.Left, lipgloss.NewStyle().Foreground(helptheme).Render(fmt.Sprintf("Max Rows: %d", m.Process.PageSize())))
 	helpKey := m.help.Styles.FullDesc.Foreground(helptheme).Padding(0, 0, 1)
 	help := lipgloss.JoinHorizontal(lipgloss.Left, helpKey.Render(m.help.FullHelpView(m.keys.FullHelp())))
-	var total string
 	s := lipgloss.NewStyle().Height(m.height).MaxHeight(m.height)
-	switch m.state {
-
-	case processview:
-
-		total = s.Render(lipgloss.JoinVertical(lipgloss.Top, lipgloss.JoinVertical(lipgloss.Top,
-			help,
-			RowCount,
-			m.tabs.View(),
-			lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.Process.View()))),
-		))
-	case fileview:
-		// s := lipgloss.NewStyle().MaxHeight(m.height).MaxWidth(m.width)
-		total = s.Render(lipgloss.JoinVertical(lipgloss.Top, lipgloss.JoinVertical(lipgloss.Top,
-			help,
-			RowCount,
-			m.tabs.View(),
-			lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.File.View()))),
-		))
-	case networkview:
-		// s := lipgloss.NewStyle().MaxHeight(m.height).MaxWidth(m.width)
-		total = s.Render(lipgloss.JoinVertical(lipgloss.Top, lipgloss.JoinVertical(lipgloss.Top,
-			help,
-			RowCount,
-			m.tabs.View(),
-			lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.Network.View()))),
-		))
-	case syscallview:
-		// s := lipgloss.NewStyle().MaxHeight(m.height).MaxWidth(m.width)
-		total = s.Render(lipgloss.JoinVertical(lipgloss.Top, lipgloss.JoinVertical(lipgloss.Top,
-			help,
-			RowCount,
-			m.tabs.View(),
-			lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.Syscall.View()))),
-		))
+	if m.detail != nil {
+		detailKey := helpKey.Render(m.help.FullHelpView(m.keys.DetailHelp()))
+		return s.Render(lipgloss.JoinVertical(lipgloss.Top, detailKey, pad.Render(m.detail.View())))
 	}
+	total := s.Render(lipgloss.JoinVertical(lipgloss.Top, lipgloss.JoinVertical(lipgloss.Top,
+		help,
+		RowCount,
+		m.tabs.View(),
+		lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.activeTable().View()))),
+	))
 	return total
 
 }
@@ -308,9 +374,11 @@ type Profile struct {
 	Process       string `json:"process"`
 	Resource      string `json:"resource"`
 	Result        string `json:"result"`
+	Action        string `json:"action,omitempty"`
 	Data          string `json:"data"`
 	Count         int    `json:"count"`
 	Time          string `json:"time"`
+	Drift         bool   `json:"drift,omitempty"`
 }
 
 // Frequency and Timestamp data for another map
@@ -341,7 +409,7 @@ func AggregateSummary(inputMap map[Profile]*Frequency, Operation string) map[Pro
 	var fileArr []string
 	fileSumMap := make(map[Profile]*Frequency)
 	updatedSumMap := make(map[Profile]*Frequency)
-	if Operation == "Network" || Operation == "Syscall" {
+	if Operation != "File" && Operation != "Process" {
 		return inputMap
 	}
 	for prof, count := range inputMap {
@@ -399,18 +467,30 @@ func (p Profile) MarshalText() (text []byte, err error) {
 	return json.Marshal(x(p))
 }
 
-func generateRowsFromData(data []pb.Log, Operation string) []table.Row {
+func matchFilter(namespace, pod, container string) bool {
+	return (namespace == o1.Namespace) ||
+		(pod == o1.Pod) ||
+		(container == o1.Container) ||
+		(len(o1.Namespace) == 0 && len(o1.Pod) == 0 && len(o1.Container) == 0)
+}
+
+func generateRowsFromData(data []pb.Log, alerts []*pb.Alert, Operation string) []table.Row {
 	var s SomeData
 	var jsondata []Profile
 	m := make(map[Profile]int)
 	w := make(map[Profile]*Frequency)
+	record := func(p Profile, updatedTime string) {
+		f := &Frequency{
+			time: updatedTime,
+		}
+		w[p] = f
+		m[p]++
+		w[p].freq = m[p]
+	}
 	for _, entry := range data {
 
 		if entry.Operation == Operation {
-			if (entry.NamespaceName == o1.Namespace) ||
-				(entry.PodName == o1.Pod) ||
-				(entry.ContainerName == o1.Container) ||
-				(len(o1.Namespace) == 0 && len(o1.Pod) == 0 && len(o1.Container) == 0) {
+			if matchFilter(entry.NamespaceName, entry.PodName, entry.ContainerName) {
 				var p Profile
 
 				if entry.Operation == "Syscall" {
@@ -430,58 +510,79 @@ func generateRowsFromData(data []pb.Log, Operation string) []table.Row {
 						Result:        entry.Result,
 					}
 				}
-
-				f := &Frequency{
-					time: entry.UpdatedTime,
-				}
-				w[p] = f
-				m[p]++
-				w[p].freq = m[p]
-
+				p.Drift = baseline.IsDrift(&entry)
+				record(p, entry.UpdatedTime)
 			}
 		}
 	}
 
+	// alerts are shown alongside the logs so that denied operations appear next to the allowed ones
+	for _, alert := range alerts {
+		if alert.Operation != Operation || !matchFilter(alert.NamespaceName, alert.PodName, alert.ContainerName) {
+			continue
+		}
+		p := Profile{
+			Namespace:     alert.NamespaceName,
+			ContainerName: alert.ContainerName,
+			Process:       alert.ProcessName,
+			Resource:      alert.Resource,
+			Result:        alert.Result,
+			Action:        alert.Action,
+		}
+		if alert.Operation == "Syscall" {
+			p.Resource = alert.Data
+		}
+		p.Drift = baseline.IsDrift(alert)
+		record(p, alert.UpdatedTime)
+	}
+
 	finalmap := AggregateSummary(w, Operation)
 	for r, frequency := range finalmap {
-		row := table.NewRow(table.RowData{
+		rowData := table.RowData{
 			ColumnNamespace:     r.Namespace,
 			ColumnContainerName: r.ContainerName,
 			ColumnProcessName:   r.Process,
 			ColumnResource:      r.Resource,
 			ColumnResult:        r.Result,
+			ColumnAction:        r.Action,
 			ColumnCount:         frequency.freq,
 			ColumnTimestamp:     frequency.time,
-		})
+		}
+		if baseline != nil {
+			rowData[ColumnDrift] = ""
+			if r.Drift {
+				rowData[ColumnDrift] = "NEW"
+			}
+		}
+		row := table.NewRow(rowData)
+		if r.Drift {
+			row = row.WithStyle(driftStyle)
+		} else if r.Action == "Block" {
+			row = row.WithStyle(blockedStyle)
+		}
 		jsondata = append(jsondata, Profile{
 			Namespace:     r.Namespace,
 			ContainerName: r.ContainerName,
 			Process:       r.Process,
 			Resource:      r.Resource,
 			Result:        r.Result,
+			Action:        r.Action,
 			Count:         frequency.freq,
 			Time:          frequency.time,
+			Drift:         r.Drift,
 		})
 		s.rows = append(s.rows, row)
 	}
 
 	if o1.Save {
-		if Operation == "File" {
-			convertToJSON("File", jsondata)
-		} else if Operation == "Process" {
-			convertToJSON("Process", jsondata)
-		} else if Operation == "Network" {
-			convertToJSON("Network", jsondata)
-		} else if Operation == "Syscall" {
-			convertToJSON("Syscall", jsondata)
-		}
+		convertToJSON(Operation, jsondata)
 	}
 
 	return s.rows
 }
 
-// Start entire TUI
-func Start(o Options) {
+// Start entire TUI, or profiles without it for the given duration
+func Start(o Options) error {
 	o1 = Options{
 		Namespace: o.Namespace,
 		Pod:       o.Pod,
@@ -489,6 +590,20 @@ func Start(o Options) {
 		Container: o.Container,
 		Save:      o.Save,
 	}
+	if o.DriftReport != "" && o.Baseline == "" {
+		return errors.New("--drift-report needs a --baseline to compare against")
+	}
+	if o.Baseline != "" {
+		var err error
+		baseline, err = profile.LoadBaseline(o.Baseline)
+		if err != nil {
+			return fmt.Errorf("failed to load baseline %s: %w", o.Baseline, err)
+		}
+	}
+	if o.Duration > 0 {
+		return profileFor(o)
+	}
+
 	p := tea.NewProgram(NewModel(), tea.WithAltScreen())
 	go func() {
 		err := profile.GetLogs(o1.GRPC)
@@ -509,4 +624,46 @@ func Start(o Options) {
 		break
 	}
 
+	saveBaseline(o.SaveBaseline)
+	_, err := reportDrift(os.Stdout, o.DriftReport)
+	return err
+}
+
+// profileFor collects the telemetry without the TUI for the duration of the
+// options, failing if behavior not in the baseline is observed
+func profileFor(o Options) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- profile.GetLogs(o1.GRPC)
+	}()
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to start observer: %v", err)
+	case <-time.After(o.Duration):
+	}
+
+	saveBaseline(o.SaveBaseline)
+	drifts, err := reportDrift(os.Stdout, o.DriftReport)
+	if err != nil {
+		return err
+	}
+	if drifts > 0 {
+		return fmt.Errorf("%d new behavior(s) not in baseline %s", drifts, o.Baseline)
+	}
+	return nil
+}
+
+// saveBaseline records the behavior observed in the logs to path, if set
+func saveBaseline(path string) {
+	if path == "" {
+		return
+	}
+	profile.TelMutex.RLock()
+	err := profile.NewBaseline(profile.Telemetry).Save(path)
+	profile.TelMutex.RUnlock()
+	if err != nil {
+		log.WithError(err).Errorf("failed to save baseline %s", path)
+		return
+	}
+	fmt.Printf("Baseline saved to %s\n", path)
 }
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	"golang.org/x/exp/slices"
+)
+
+// Operations tracked in a baseline
+var baselineOperations = []string{"Process", "File", "Network", "Syscall"}
+
+// ContainerBaseline holds the behavior observed for a single container
+type ContainerBaseline struct {
+	Process []string `json:"process,omitempty"`
+	File    []string `json:"file,omitempty"`
+	Network []string `json:"network,omitempty"`
+	Syscall []string `json:"syscall,omitempty"`
+}
+
+// Event fields of KubeArmor logs and alerts compared against a baseline
+type Event interface {
+	GetNamespaceName() string
+	GetContainerName() string
+	GetOperation() string
+	GetResource() string
+	GetData() string
+}
+
+// Baseline set of behaviors observed per container, used for drift detection
+type Baseline struct {
+	Containers map[string]*ContainerBaseline `json:"containers"`
+}
+
+// NewBaseline creates a baseline from the given telemetry events
+func NewBaseline(logs []pb.Log) *Baseline {
+	b := &Baseline{
+		Containers: map[string]*ContainerBaseline{},
+	}
+	for i := range logs {
+		b.Add(&logs[i])
+	}
+	return b
+}
+
+func containerKey(entry Event) string {
+	return entry.GetNamespaceName() + "/" + entry.GetContainerName()
+}
+
+// behaviorOf returns the value recorded for the event, same as what the profile table shows
+func behaviorOf(entry Event) string {
+	if entry.GetOperation() == "Syscall" {
+		return entry.GetData()
+	}
+	return entry.GetResource()
+}
+
+func (c *ContainerBaseline) list(operation string) *[]string {
+	switch operation {
+	case "Process":
+		return &c.Process
+	case "File":
+		return &c.File
+	case "Network":
+		return &c.Network
+	case "Syscall":
+		return &c.Syscall
+	}
+	return nil
+}
+
+// Add records the event in the baseline
+func (b *Baseline) Add(entry *pb.Log) {
+	if !slices.Contains(baselineOperations, entry.Operation) {
+		return
+	}
+	key := containerKey(entry)
+	cb, ok := b.Containers[key]
+	if !ok {
+		cb = &ContainerBaseline{}
+		b.Containers[key] = cb
+	}
+	l := cb.list(entry.Operation)
+	val := behaviorOf(entry)
+	idx := sort.SearchStrings(*l, val)
+	if idx < len(*l) && (*l)[idx] == val {
+		return
+	}
+	*l = slices.Insert(*l, idx, val)
+}
+
+// IsDrift checks whether the log or alert is a behavior not recorded in the baseline
+func (b *Baseline) IsDrift(entry Event) bool {
+	if b == nil || !slices.Contains(baselineOperations, entry.GetOperation()) {
+		return false
+	}
+	cb, ok := b.Containers[containerKey(entry)]
+	if !ok {
+		return true
+	}
+	l := *cb.list(entry.GetOperation())
+	val := behaviorOf(entry)
+	idx := sort.SearchStrings(l, val)
+	return idx >= len(l) || l[idx] != val
+}
+
+// Save writes the baseline in json format
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(path), data, 0600)
+}
+
+// LoadBaseline reads a baseline previously written with Save
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	if b.Containers == nil {
+		b.Containers = map[string]*ContainerBaseline{}
+	}
+	for key, cb := range b.Containers {
+		if cb == nil {
+			return nil, fmt.Errorf("invalid baseline %s: no behavior recorded for container %s", path, key)
+		}
+		for _, op := range baselineOperations {
+			sort.Strings(*cb.list(op))
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,65 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+)
+
+func TestBaselineDrift(t *testing.T) {
+	logs := []pb.Log{
+		{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Process", Resource: "/usr/sbin/apache2"},
+		{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "File", Resource: "/etc/passwd"},
+		{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Syscall", Data: "syscall=SYS_SETUID"},
+	}
+
+	path := filepath.Join(t.TempDir(), "base.json")
+	if err := NewBaseline(logs).Save(path); err != nil {
+		t.Fatal(err.Error())
+	}
+	b, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := range logs {
+		if b.IsDrift(&logs[i]) {
+			t.Errorf("event %d recorded in baseline reported as drift", i)
+		}
+	}
+
+	drifts := []pb.Log{
+		{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Process", Resource: "/bin/sh"},
+		{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Network", Resource: "remoteip=10.0.0.1 port=4444"},
+		{NamespaceName: "wordpress", ContainerName: "mysql", Operation: "File", Resource: "/etc/passwd"},
+	}
+	for i := range drifts {
+		if !b.IsDrift(&drifts[i]) {
+			t.Errorf("new behavior %d not reported as drift", i)
+		}
+	}
+}
+
+func TestBaselineDriftAlerts(t *testing.T) {
+	b := NewBaseline([]pb.Log{
+		{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Process", Resource: "/usr/sbin/apache2"},
+	})
+	if b.IsDrift(&pb.Alert{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Process", Resource: "/usr/sbin/apache2", Action: "Audit"}) {
+		t.Error("audited behavior recorded in baseline reported as drift")
+	}
+	if !b.IsDrift(&pb.Alert{NamespaceName: "wordpress", ContainerName: "wordpress", Operation: "Process", Resource: "/bin/sh", Action: "Block"}) {
+		t.Error("new blocked behavior not reported as drift")
+	}
+}
+
+func TestLoadBaselineNullContainer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "base.json")
+	if err := os.WriteFile(path, []byte(`{"containers": {"wordpress/wordpress": null}}`), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := LoadBaseline(path); err == nil {
+		t.Error("expected an error for a container without behavior")
+	}
+}
@@ -22,12 +22,15 @@ var ErrChan chan error
 // Telemetry to store incoming log events
 var Telemetry []pb.Log
 
+// AlertTelemetry to store incoming alert events
+var AlertTelemetry []*pb.Alert
+
 // TelMutex to prevent deadlock
 var TelMutex sync.RWMutex
 
 // GetLogs to fetch logs
 func GetLogs(grpc string) error {
-	errCh := KarmorProfileStart("system", grpc)
+	errCh := KarmorProfileStart("all", grpc)
 	var err error
 	if eventChan == nil {
 		log.Error("event channel not set. Did you call KarmorQueueLog()?")
@@ -46,6 +49,15 @@ func GetLogs(grpc string) error {
 				TelMutex.Lock()
 				Telemetry = append(Telemetry, log)
 				TelMutex.Unlock()
+			} else if evtin.Type == "Alert" {
+				alert := &pb.Alert{}
+				err := protojson.Unmarshal(evtin.Data, alert)
+				if err != nil {
+					return err
+				}
+				TelMutex.Lock()
+				AlertTelemetry = append(AlertTelemetry, alert)
+				TelMutex.Unlock()
 			} else {
 				log.Errorf("UNKNOWN EVT type %s", evtin.Type)
 			}
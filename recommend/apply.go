@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RecommendedAnnotation marks the policies applied by karmor recommend
+const RecommendedAnnotation = "karmor.kubearmor.io/recommended"
+
+// fieldManager used for server-side apply
+const fieldManager = "karmor"
+
+// generated policies of the current run by output file
+var generated map[string][]byte
+
+// policyDiff rules added, removed and changed compared to the live policy
+type policyDiff struct {
+	added   []string
+	removed []string
+	changed []string
+}
+
+func (d policyDiff) empty() bool {
+	return len(d.added)+len(d.removed)+len(d.changed) == 0
+}
+
+// ruleID fields identifying a rule within its match list
+var ruleID = []string{"path", "dir", "pattern", "protocol", "capability"}
+
+// flattenRules returns the rules of the spec as "<section>.<list> <id>" keys
+// mapped to their json representation
+func flattenRules(spec pol.KubeArmorPolicySpec) map[string]string {
+	rules := map[string]string{}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return rules
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return rules
+	}
+	for _, field := range []string{"action", "severity", "message", "tags", "apparmor"} {
+		if v, ok := m[field]; ok {
+			val, _ := json.Marshal(v)
+			rules["spec."+field] = string(val)
+		}
+	}
+	for _, section := range []string{"process", "file", "network", "capabilities", "syscalls"} {
+		lists, ok := m[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for list, items := range lists {
+			arr, ok := items.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, item := range arr {
+				val, _ := json.Marshal(item)
+				id := string(val)
+				if fields, ok := item.(map[string]interface{}); ok {
+					for _, f := range ruleID {
+						if v, ok := fields[f].(string); ok {
+							id = v
+							if src, ok := fields["fromSource"]; ok {
+								from, _ := json.Marshal(src)
+								id += " fromSource=" + string(from)
+							}
+							break
+						}
+					}
+				}
+				rules[fmt.Sprintf("%s.%s %s", section, list, id)] = string(val)
+			}
+		}
+	}
+	return rules
+}
+
+// diffPolicies compares the rules of the generated policy with the live one
+func diffPolicies(live, policy *pol.KubeArmorPolicy) policyDiff {
+	var d policyDiff
+	oldRules := flattenRules(live.Spec)
+	newRules := flattenRules(policy.Spec)
+	for k, v := range newRules {
+		old, ok := oldRules[k]
+		if !ok {
+			d.added = append(d.added, k)
+		} else if old != v {
+			d.changed = append(d.changed, fmt.Sprintf("%s: %s -> %s", k, old, v))
+		}
+	}
+	for k := range oldRules {
+		if _, ok := newRules[k]; !ok {
+			d.removed = append(d.removed, k)
+		}
+	}
+	sort.Strings(d.added)
+	sort.Strings(d.removed)
+	sort.Strings(d.changed)
+	return d
+}
+
+// livePolicy returns the policy in the cluster with the same name, nil if
+// there is none. Policies are matched by name only: the policies recommended
+// for a workload all share its selector.
+func livePolicy(c *k8s.Client, policy *pol.KubeArmorPolicy) (*pol.KubeArmorPolicy, error) {
+	live, err := c.KSPClientset.KubeArmorPolicies(policy.Namespace).Get(context.Background(), policy.Name, v1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+func printDiff(policy, live *pol.KubeArmorPolicy, d policyDiff) {
+	name := fmt.Sprintf("%s/%s", policy.Namespace, policy.Name)
+	switch {
+	case live == nil:
+		color.Green("policy %s: new", name)
+	case d.empty():
+		fmt.Printf("policy %s: unchanged\n", name)
+		return
+	default:
+		color.Yellow("policy %s: differs from the live policy", name)
+	}
+	for _, r := range d.added {
+		color.Green("  + %s", r)
+	}
+	for _, r := range d.removed {
+		color.Red("  - %s", r)
+	}
+	for _, r := range d.changed {
+		color.Yellow("  ~ %s", r)
+	}
+}
+
+// applyPolicy creates or updates the policy, annotated as recommended
+func applyPolicy(c *k8s.Client, policy *pol.KubeArmorPolicy, exists, dryRun bool) error {
+	if policy.Annotations == nil {
+		policy.Annotations = map[string]string{}
+	}
+	policy.Annotations[RecommendedAnnotation] = "true"
+	var dry []string
+	if dryRun {
+		dry = []string{v1.DryRunAll}
+	}
+	kspInterface := c.KSPClientset.KubeArmorPolicies(policy.Namespace)
+	if !exists {
+		_, err := kspInterface.Create(context.Background(), policy, v1.CreateOptions{DryRun: dry, FieldManager: fieldManager})
+		return err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	force := true
+	_, err = kspInterface.Patch(context.Background(), policy.Name, types.ApplyPatchType, data,
+		v1.PatchOptions{DryRun: dry, FieldManager: fieldManager, Force: &force})
+	return err
+}
+
+// syncPolicies diffs the generated policies against the cluster and applies them.
+// Applying is validated by a server-side dry-run of all the policies first, so
+// that nothing is changed if any of them is rejected.
+func syncPolicies(c *k8s.Client, diff, apply, dryRun bool) error {
+	var files []string
+	for f := range generated {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	type pending struct {
+		policy *pol.KubeArmorPolicy
+		exists bool
+	}
+	var policies []pending
+	for _, f := range files {
+		var policy pol.KubeArmorPolicy
+		if err := json.Unmarshal(generated[f], &policy); err != nil {
+			log.WithError(err).Error(fmt.Sprintf("failed to parse policy %s", f))
+			continue
+		}
+		if policy.Kind != "KubeArmorPolicy" {
+			continue
+		}
+		live, err := livePolicy(c, &policy)
+		if err != nil {
+			return err
+		}
+		if diff {
+			d := policyDiff{}
+			if live != nil {
+				d = diffPolicies(live, &policy)
+			}
+			printDiff(&policy, live, d)
+		}
+		policies = append(policies, pending{&policy, live != nil})
+	}
+	if !apply {
+		return nil
+	}
+
+	for _, p := range policies {
+		if err := applyPolicy(c, p.policy.DeepCopy(), p.exists, true); err != nil {
+			return fmt.Errorf("dry-run of policy %s/%s failed, nothing applied: %w", p.policy.Namespace, p.policy.Name, err)
+		}
+	}
+	if dryRun {
+		color.Green("dry-run of %d policies succeeded", len(policies))
+		return nil
+	}
+	for _, p := range policies {
+		if err := applyPolicy(c, p.policy, p.exists, false); err != nil {
+			return err
+		}
+		color.Green("applied policy %s/%s ...", p.policy.Namespace, p.policy.Name)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package recommend
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	kspfake "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/client/clientset/versioned/fake"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPolicy(name string, paths ...string) *pol.KubeArmorPolicy {
+	policy := &pol.KubeArmorPolicy{
+		TypeMeta:   v1.TypeMeta{APIVersion: "security.kubearmor.com/v1", Kind: "KubeArmorPolicy"},
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "wordpress-mysql"},
+		Spec: pol.KubeArmorPolicySpec{
+			Selector: pol.SelectorType{MatchLabels: map[string]string{"app": "wordpress"}},
+			Severity: 5,
+			Action:   "Block",
+		},
+	}
+	for _, p := range paths {
+		policy.Spec.Process.MatchPaths = append(policy.Spec.Process.MatchPaths, pol.ProcessPathType{Path: pol.MatchPathType(p)})
+	}
+	return policy
+}
+
+func TestDiffPolicies(t *testing.T) {
+	live := testPolicy("wordpress-pkg-mngr-exec", "/usr/bin/apt", "/usr/bin/dpkg")
+	policy := testPolicy("wordpress-pkg-mngr-exec", "/usr/bin/apt", "/sbin/apk")
+	policy.Spec.Severity = 7
+
+	d := diffPolicies(live, policy)
+	if len(d.added) != 1 || d.added[0] != "process.matchPaths /sbin/apk" {
+		t.Errorf("unexpected added rules %v", d.added)
+	}
+	if len(d.removed) != 1 || d.removed[0] != "process.matchPaths /usr/bin/dpkg" {
+		t.Errorf("unexpected removed rules %v", d.removed)
+	}
+	if len(d.changed) != 1 || d.changed[0] != "spec.severity: 5 -> 7" {
+		t.Errorf("unexpected changed rules %v", d.changed)
+	}
+	if !diffPolicies(policy, policy).empty() {
+		t.Error("expected no difference for the same policy")
+	}
+}
+
+func TestSyncPolicies(t *testing.T) {
+	client := &k8s.Client{
+		// unrelated live policy with the same selector, not to be mistaken for the generated ones
+		KSPClientset: kspfake.NewSimpleClientset(testPolicy("wordpress-block", "/usr/bin/apt"), testPolicy("wordpress-admin", "/bin/su")).SecurityV1(),
+	}
+	generated = map[string][]byte{}
+	for file, policy := range map[string]*pol.KubeArmorPolicy{
+		"out/wordpress-block.yaml":  testPolicy("wordpress-block", "/usr/bin/apt", "/usr/bin/dpkg"),
+		"out/wordpress-audit.yaml":  testPolicy("wordpress-audit", "/bin/sh"),
+		"out/wordpress-ignore.yaml": {TypeMeta: v1.TypeMeta{Kind: "KubeArmorHostPolicy"}},
+	} {
+		data, err := json.Marshal(policy)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		generated[file] = data
+	}
+
+	if err := syncPolicies(client, true, true, true); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := syncPolicies(client, false, true, false); err != nil {
+		t.Fatal(err.Error())
+	}
+	policies, err := client.KSPClientset.KubeArmorPolicies("wordpress-mysql").List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(policies.Items) != 3 {
+		t.Fatalf("expected 3 policies, got %d", len(policies.Items))
+	}
+	for _, p := range policies.Items {
+		if p.Name == "wordpress-admin" {
+			if len(p.Spec.Process.MatchPaths) != 1 || p.Annotations[RecommendedAnnotation] != "" {
+				t.Errorf("unrelated policy changed %+v", p)
+			}
+			continue
+		}
+		if p.Annotations[RecommendedAnnotation] != "true" {
+			t.Errorf("policy %s not annotated", p.Name)
+		}
+		if p.Name == "wordpress-block" && len(p.Spec.Process.MatchPaths) != 2 {
+			t.Errorf("policy not updated %+v", p.Spec.Process)
+		}
+	}
+}
+
+func TestApplyNeedsNamespace(t *testing.T) {
+	client := &k8s.Client{KSPClientset: kspfake.NewSimpleClientset().SecurityV1()}
+	o := common.Options{Images: []string{"wordpress:4.8-apache"}, Apply: true, Action: common.ActionTemplate}
+	if err := Recommend(client, o); err == nil || !strings.Contains(err.Error(), "--namespace") {
+		t.Errorf("expected a missing namespace error, got %v", err)
+	}
+}
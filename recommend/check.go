@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
+	"github.com/olekukonko/tablewriter"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// checkedWorkload policies recommended for a workload in --check mode
+type checkedWorkload struct {
+	deployment Deployment
+	name       string
+	// recommended names of the recommended policies by tag
+	recommended map[string][]string
+}
+
+// checkGap required tag not covered by the existing policies of a workload
+type checkGap struct {
+	namespace   string
+	workload    string
+	tag         string
+	recommended []string
+}
+
+// workloads recorded for --check, in order
+var checked []*checkedWorkload
+
+// loadExistingPolicies reads the KubeArmorPolicies of a policy directory or
+// file, or lists them in the cluster if no directory is given
+func loadExistingPolicies(c *k8s.Client, dir string) ([]pol.KubeArmorPolicy, error) {
+	if dir == "" {
+		if c == nil {
+			return nil, errors.New("--check needs --policy-dir or a k8s cluster connection")
+		}
+		list, err := c.KSPClientset.KubeArmorPolicies("").List(context.Background(), v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+
+	manifests, err := readManifests(dir)
+	if err != nil {
+		return nil, err
+	}
+	var policies []pol.KubeArmorPolicy
+	for _, manifest := range manifests {
+		docs, err := splitDocuments(manifest)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			js, err := yaml.YAMLToJSON(doc)
+			if err != nil {
+				return nil, err
+			}
+			var policy pol.KubeArmorPolicy
+			if err := json.Unmarshal(js, &policy); err != nil || policy.Kind != "KubeArmorPolicy" {
+				continue
+			}
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+// recordCheck records the policies recommended for the image of the workload
+func recordCheck(deployment Deployment, img string, msMap map[string]interface{}) {
+	if !options.Check {
+		return
+	}
+	name := coverageWorkload(deployment, img)
+	var wl *checkedWorkload
+	for _, w := range checked {
+		if w.deployment.Namespace == deployment.Namespace && w.name == name {
+			wl = w
+		}
+	}
+	if wl == nil {
+		wl = &checkedWorkload{deployment: deployment, name: name, recommended: map[string][]string{}}
+		checked = append(checked, wl)
+	}
+	for file, v := range msMap {
+		ms, ok := v.(common.MatchSpec)
+		if !ok {
+			continue
+		}
+		policy := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		for _, tag := range ms.Spec.Tags {
+			wl.recommended[tag] = append(wl.recommended[tag], policy)
+		}
+	}
+}
+
+// selects checks whether the existing policy applies to the workload, policies
+// without a namespace apply to any namespace
+func selects(policy pol.KubeArmorPolicy, deployment Deployment) bool {
+	if policy.Namespace != "" && policy.Namespace != deployment.Namespace {
+		return false
+	}
+	return matchLabels(policy.Spec.Selector.MatchLabels, deployment.Labels)
+}
+
+// findGaps lists the required tags not covered by the existing policies of the workloads
+func findGaps(workloads []*checkedWorkload, existing []pol.KubeArmorPolicy, required []string) []checkGap {
+	var gaps []checkGap
+	for _, wl := range workloads {
+		for _, req := range required {
+			covered := false
+			for _, p := range existing {
+				if !selects(p, wl.deployment) {
+					continue
+				}
+				for _, tag := range p.Spec.Tags {
+					if report.TagMatches(tag, req) {
+						covered = true
+					}
+				}
+			}
+			if covered {
+				continue
+			}
+			gap := checkGap{namespace: wl.deployment.Namespace, workload: wl.name, tag: req}
+			for tag, policies := range wl.recommended {
+				if report.TagMatches(tag, req) {
+					gap.recommended = append(gap.recommended, policies...)
+				}
+			}
+			gap.recommended = unique(gap.recommended)
+			sort.Strings(gap.recommended)
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps
+}
+
+func printGaps(w io.Writer, gaps []checkGap) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"NAMESPACE", "WORKLOAD", "MISSING TAG", "RECOMMENDED POLICIES"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, g := range gaps {
+		recommended := "-"
+		if len(g.recommended) > 0 {
+			recommended = strings.Join(g.recommended, "\n")
+		}
+		table.Append([]string{g.namespace, g.workload, g.tag, recommended})
+	}
+	table.SetRowLine(true)
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
+	table.Render()
+}
+
+// checkPolicies reports the workloads lacking policies for the required tags,
+// failing if there are any
+func checkPolicies(w io.Writer, existing []pol.KubeArmorPolicy) error {
+	gaps := findGaps(checked, existing, options.RequireTags)
+	if len(gaps) == 0 {
+		color.Green("all %d workloads have policies for %s", len(checked), strings.Join(options.RequireTags, ", "))
+		return nil
+	}
+	printGaps(w, gaps)
+	return fmt.Errorf("%d required tags not covered by the existing policies", len(gaps))
+}
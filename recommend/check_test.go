@@ -0,0 +1,90 @@
+package recommend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+)
+
+const existingPolicies = `apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: wordpress-pkg-mngr-exec
+  namespace: wordpress-mysql
+spec:
+  selector:
+    matchLabels:
+      app: wordpress
+  tags: ["NIST_800-53_CM-7(4)"]
+  process:
+    matchPaths:
+    - path: /usr/bin/apt
+  action: Block
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestCheckPolicies(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policies.yaml"), []byte(existingPolicies), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	existing, err := loadExistingPolicies(nil, dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(existing) != 1 || existing[0].Name != "wordpress-pkg-mngr-exec" {
+		t.Fatalf("unexpected existing policies %+v", existing)
+	}
+	if _, err := loadExistingPolicies(nil, ""); err == nil {
+		t.Error("expected an error without policy dir nor cluster")
+	}
+
+	options = common.Options{Check: true, RequireTags: []string{"MITRE", "NIST"}}
+	checked = nil
+	defer func() {
+		options = common.Options{}
+		checked = nil
+	}()
+	wordpress := Deployment{Name: "wordpress", Namespace: "wordpress-mysql", Labels: LabelMap{"app": "wordpress"}}
+	mysql := Deployment{Name: "mysql", Namespace: "wordpress-mysql", Labels: LabelMap{"app": "mysql"}}
+	recordCheck(wordpress, "wordpress:4.8-apache", map[string]interface{}{
+		"out/wordpress-mysql-wordpress/wordpress-4-8-apache-write-under-bin-dir.yaml": common.MatchSpec{
+			Spec: pol.KubeArmorPolicySpec{Tags: []string{"MITRE_T1036_masquerading", "NIST"}},
+		},
+	})
+	recordCheck(mysql, "mysql:5.6", nil)
+
+	gaps := findGaps(checked, existing, options.RequireTags)
+	if len(gaps) != 3 {
+		t.Fatalf("expected 3 gaps, got %+v", gaps)
+	}
+	if g := gaps[0]; g.workload != "wordpress" || g.tag != "MITRE" || len(g.recommended) != 1 || g.recommended[0] != "wordpress-4-8-apache-write-under-bin-dir" {
+		t.Errorf("unexpected wordpress gap %+v", g)
+	}
+	if g := gaps[2]; g.workload != "mysql" || g.tag != "NIST" || len(g.recommended) != 0 {
+		t.Errorf("unexpected mysql gap %+v", g)
+	}
+
+	var out bytes.Buffer
+	if err := checkPolicies(&out, existing); err == nil {
+		t.Error("expected the check to fail")
+	}
+	if !strings.Contains(out.String(), "MISSING TAG") || !strings.Contains(out.String(), "wordpress-4-8-apache-write-under-bin-dir") {
+		t.Errorf("unexpected gap table\n%s", out.String())
+	}
+
+	options.RequireTags = []string{"NIST"}
+	checked = checked[:1]
+	if err := checkPolicies(&out, existing); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -5,8 +5,10 @@
 package common
 
 import (
+	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
 )
@@ -21,6 +23,77 @@ type MatchSpec struct {
 	Description  Description             `json:"description" yaml:"description"`
 	Yaml         string                  `json:"yaml" yaml:"yaml"`
 	Spec         pol.KubeArmorPolicySpec `json:"spec,omitempty" yaml:"spec,omitempty"`
+	// Kinds policy kinds the rule applies to, all kinds when empty
+	Kinds []string `json:"kinds,omitempty" yaml:"kinds,omitempty"`
+	// Source where the rule comes from, empty for policy-templates
+	Source string `json:"-" yaml:"-"`
+	// TemplateAction action of the rule when overridden by --action, --min-severity or --override
+	TemplateAction string `json:"-" yaml:"-"`
+	// Evidence what the rule was recommended for, set when the image is scanned
+	Evidence []Evidence `json:"-" yaml:"-"`
+	// TemplateVersion policy-templates release of the rule, empty for other sources
+	TemplateVersion string `json:"-" yaml:"-"`
+}
+
+// maxEvidenceShown number of matches listed by Evidence.String
+const maxEvidenceShown = 3
+
+// Evidence files, packages or config entries of the image matched by a precondition
+type Evidence struct {
+	// Precondition pattern of the rule, e.g. /usr/bin/apt.* or package:openssl
+	Precondition string `json:"precondition"`
+	// Matches what the precondition matched in the image
+	Matches []string `json:"matches"`
+}
+
+func (e Evidence) String() string {
+	shown := e.Matches
+	if len(shown) > maxEvidenceShown {
+		shown = shown[:maxEvidenceShown]
+	}
+	s := fmt.Sprintf("%s: %s", e.Precondition, strings.Join(shown, ", "))
+	if len(e.Matches) > len(shown) {
+		s += fmt.Sprintf(" (+%d more)", len(e.Matches)-len(shown))
+	}
+	return s
+}
+
+// SourcePolicyTemplates provenance of the upstream policy-templates rules
+const SourcePolicyTemplates = "policy-templates"
+
+// Provenance returns where the rule comes from
+func (ms MatchSpec) Provenance() string {
+	if ms.Source == "" {
+		return SourcePolicyTemplates
+	}
+	return ms.Source
+}
+
+// AppliesTo checks whether the rule is meant for the given policy kind
+func (ms MatchSpec) AppliesTo(kind string) bool {
+	if len(ms.Kinds) == 0 {
+		return true
+	}
+	for _, k := range ms.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy kinds recommended
+const (
+	KindKubeArmorPolicy     = "KubeArmorPolicy"
+	KindKubeArmorHostPolicy = "KubeArmorHostPolicy"
+)
+
+// OriginalAction returns the action of the rule before the overrides
+func (ms MatchSpec) OriginalAction() string {
+	if ms.TemplateAction != "" {
+		return ms.TemplateAction
+	}
+	return string(ms.Spec.Action)
 }
 
 // Ref for the policy rules
@@ -38,14 +111,144 @@ type Description struct {
 
 // Options for karmor recommend
 type Options struct {
-	Images     []string
-	Labels     []string
-	Tags       []string
+	Images []string
+	Labels []string
+	Tags   []string
+	// Policy kinds of the recommended policies, KubeArmorPolicy by default
 	Policy     []string
 	Namespace  string
 	OutDir     string
 	ReportFile string
 	Config     string
+	Backend    string
+	Engine     string
+	Events     string
+	// ReportFormat format of the report, derived from the ReportFile extension if empty
+	ReportFormat string
+	// CoverageReport file of the compliance coverage matrix (.html, .csv or .json)
+	CoverageReport string
+	// Check compares the recommended policies with the existing ones and fails on missing RequireTags
+	Check bool
+	// RequireTags tags every workload must have existing policies for in Check mode
+	RequireTags []string
+	// PolicyDir existing policies to check against, the cluster policies if empty
+	PolicyDir string
+	// SBOMs SPDX or CycloneDX JSON files analyzed instead of pulling the images
+	SBOMs []string
+	// Interactive reviews the recommended policies before keeping them
+	Interactive bool
+	// File local k8s manifests (file, directory, kustomization or "-" for stdin)
+	File string
+	// Workers number of images analyzed in parallel
+	Workers int
+	// NoCache disables the persistent image analysis cache
+	NoCache bool
+	// CacheMaxSize size limit of the image analysis cache in MB
+	CacheMaxSize int64
+	// Consolidate merges the rules of a workload into one policy per action
+	Consolidate bool
+	// Diff compares the generated policies with the ones in the cluster
+	Diff bool
+	// Apply creates or updates the generated policies in the cluster
+	Apply bool
+	// DryRun validates the policies server-side without applying them
+	DryRun bool
+	// Templates local policy-templates directory, zip or tar.gz
+	Templates string
+	// TemplatesVersion pinned policy-templates release
+	TemplatesVersion string
+	// Rules user defined rules file or directory
+	Rules string
+	// NetworkBaseline recommends a network protocol allow list for the workloads listening on ports
+	NetworkBaseline bool
+	// NetworkPolicy additionally generates network policies of the given type, e.g. cilium
+	NetworkPolicy string
+	// NodeSelector node labels selected by the recommended host policies
+	NodeSelector []string
+	// Rootfs node root filesystems (directory or tarball) analyzed for host policies
+	Rootfs []string
+	// Platform platform of multi-platform images to analyze, e.g. linux/arm64
+	Platform string
+	// AllPlatforms analyzes every platform of multi-platform images
+	AllPlatforms bool
+	// Action of the recommended policies, Audit, Block or template to keep the template actions
+	Action string
+	// MinSeverity rules below this severity are audited instead of blocked, 0 disables it
+	MinSeverity int
+	// Overrides per tag actions, TAG=Action, taking precedence over Action and MinSeverity
+	Overrides []string
+}
+
+// ActionTemplate keeps the actions of the templates
+const ActionTemplate = "template"
+
+// ValidateActions checks the action, severity and override options
+func (o Options) ValidateActions() error {
+	switch o.Action {
+	case "", ActionTemplate, "Audit", "Block":
+	default:
+		return fmt.Errorf("invalid action %q, use Audit, Block or %s", o.Action, ActionTemplate)
+	}
+	if o.MinSeverity < 0 || o.MinSeverity > 10 {
+		return fmt.Errorf("invalid minimum severity %d, use 0 (disabled) or 1 to 10", o.MinSeverity)
+	}
+	for _, override := range o.Overrides {
+		tag, action, ok := strings.Cut(override, "=")
+		if !ok || tag == "" {
+			return fmt.Errorf("invalid override %q, use TAG=Action", override)
+		}
+		if action != "Audit" && action != "Block" {
+			return fmt.Errorf("invalid override %q, the action must be Audit or Block", override)
+		}
+	}
+	return nil
+}
+
+// EffectiveAction returns the action of the rule after applying the
+// --action, --min-severity and --override options. Allow rules are left
+// untouched, changing their action would invert their meaning.
+func (o Options) EffectiveAction(ms MatchSpec) string {
+	action := string(ms.Spec.Action)
+	if action == "Allow" {
+		return action
+	}
+	if o.Action != "" && o.Action != ActionTemplate {
+		action = o.Action
+	}
+	if o.MinSeverity > 0 && action == "Block" && int(ms.Spec.Severity) < o.MinSeverity {
+		action = "Audit"
+	}
+	for _, override := range o.Overrides {
+		tag, act, ok := strings.Cut(override, "=")
+		if !ok {
+			continue
+		}
+		for _, t := range ms.Spec.Tags {
+			if strings.EqualFold(t, tag) {
+				return act
+			}
+		}
+	}
+	return action
+}
+
+// WithEffectiveAction returns the rule with its effective action, keeping the template action
+func (o Options) WithEffectiveAction(ms MatchSpec) MatchSpec {
+	if action := o.EffectiveAction(ms); action != string(ms.Spec.Action) {
+		ms.TemplateAction = string(ms.Spec.Action)
+		ms.Spec.Action = pol.ActionType(action)
+	}
+	return ms
+}
+
+// HostPolicy checks whether host policies are recommended instead of pod policies
+func (o Options) HostPolicy() bool {
+	for _, kind := range o.Policy {
+		if kind == KindKubeArmorHostPolicy {
+			return true
+		}
+	}
+	return false
 }
 
 // UserHome function returns users home directory
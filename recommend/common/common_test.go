@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package common
+
+import (
+	"strings"
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+)
+
+func TestEffectiveAction(t *testing.T) {
+	rule := func(action string, severity int, tags ...string) MatchSpec {
+		return MatchSpec{Spec: pol.KubeArmorPolicySpec{Action: pol.ActionType(action), Severity: pol.SeverityType(severity), Tags: tags}}
+	}
+	for _, tc := range []struct {
+		name string
+		o    Options
+		ms   MatchSpec
+		want string
+	}{
+		{"template", Options{Action: ActionTemplate}, rule("Block", 5), "Block"},
+		{"audit everything", Options{Action: "Audit"}, rule("Block", 9), "Audit"},
+		{"allow untouched", Options{Action: "Block"}, rule("Allow", 1), "Allow"},
+		{"below min severity", Options{Action: "Block", MinSeverity: 7}, rule("Audit", 5), "Audit"},
+		{"above min severity", Options{Action: "Block", MinSeverity: 7}, rule("Audit", 7), "Block"},
+		{"override", Options{Action: "Audit", Overrides: []string{"MITRE=Block"}}, rule("Audit", 3, "mitre"), "Block"},
+		{"override below min severity", Options{MinSeverity: 7, Overrides: []string{"NIST=Block"}}, rule("Block", 2, "NIST"), "Block"},
+	} {
+		if got := tc.o.EffectiveAction(tc.ms); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+
+	ms := Options{Action: "Audit"}.WithEffectiveAction(rule("Block", 5))
+	if ms.Spec.Action != "Audit" || ms.OriginalAction() != "Block" {
+		t.Errorf("unexpected actions %s (template: %s)", ms.Spec.Action, ms.OriginalAction())
+	}
+}
+
+func TestValidateActions(t *testing.T) {
+	for _, o := range []Options{
+		{Action: "Allow"},
+		{MinSeverity: 11},
+		{Overrides: []string{"MITRE"}},
+		{Overrides: []string{"MITRE=Allow"}},
+	} {
+		if err := o.ValidateActions(); err == nil {
+			t.Errorf("expected %+v to be rejected", o)
+		}
+	}
+	if err := (Options{Action: "Block", MinSeverity: 7, Overrides: []string{"MITRE=Audit"}}).ValidateActions(); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestValidateMinSeverity(t *testing.T) {
+	for _, tc := range []struct {
+		minSeverity int
+		valid       bool
+	}{
+		{0, true},
+		{1, true},
+		{10, true},
+		{11, false},
+		{-1, false},
+	} {
+		err := (Options{MinSeverity: tc.minSeverity}).ValidateActions()
+		if (err == nil) != tc.valid {
+			t.Errorf("min severity %d: expected valid %t, got %v", tc.minSeverity, tc.valid, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "0 (disabled) or 1 to 10") {
+			t.Errorf("unexpected error message %s", err.Error())
+		}
+	}
+}
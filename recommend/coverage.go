@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// coverage of the current run, nil unless a coverage report is requested
+var coverage *report.Coverage
+
+// coverageWorkload names the row of the deployment, images without a
+// workload get a row of their own
+func coverageWorkload(deployment Deployment, img string) string {
+	if deployment.Name != "" {
+		return deployment.Name
+	}
+	return img
+}
+
+// coverage of the images recorded once the policies are reviewed, in interactive mode
+type pendingCoverage struct {
+	deployment Deployment
+	img        string
+	msMap      map[string]interface{}
+}
+
+var pendingCoverages []pendingCoverage
+
+// recordCoverage records the controls covered by the rules recommended for the image
+func recordCoverage(deployment Deployment, img string, msMap map[string]interface{}) {
+	if coverage == nil {
+		return
+	}
+	if options.Interactive {
+		pendingCoverages = append(pendingCoverages, pendingCoverage{deployment, img, msMap})
+		return
+	}
+	addCoverage(deployment, img, msMap)
+}
+
+func addCoverage(deployment Deployment, img string, msMap map[string]interface{}) {
+	name := coverageWorkload(deployment, img)
+	coverage.Add(deployment.Namespace, name, nil, "")
+	for _, v := range msMap {
+		if ms, ok := v.(common.MatchSpec); ok {
+			coverage.Add(deployment.Namespace, name, ms.Spec.Tags, report.CoverageRecommended)
+		}
+	}
+}
+
+// recordReviewedCoverage records the coverage of the policies kept by the review
+func recordReviewedCoverage() {
+	for _, pc := range pendingCoverages {
+		kept := map[string]interface{}{}
+		for outFile, ms := range pc.msMap {
+			if _, ok := generated[outFile]; ok {
+				kept[outFile] = ms
+			}
+		}
+		addCoverage(pc.deployment, pc.img, kept)
+	}
+	pendingCoverages = nil
+}
+
+// recordAppliedCoverage records the controls covered by the live policies
+// selecting the workload. Live policies are listed once per namespace.
+func recordAppliedCoverage(c *k8s.Client, deployment Deployment, live map[string][]pol.KubeArmorPolicy) {
+	if coverage == nil || c == nil || deployment.Name == "" || deployment.Kind == "Node" {
+		return
+	}
+	policies, ok := live[deployment.Namespace]
+	if !ok {
+		list, err := c.KSPClientset.KubeArmorPolicies(deployment.Namespace).List(context.Background(), v1.ListOptions{})
+		if err != nil {
+			log.WithError(err).WithField("namespace", deployment.Namespace).Warn("failed to list the live policies, coverage shows recommended policies only")
+		} else {
+			policies = list.Items
+		}
+		live[deployment.Namespace] = policies
+	}
+	for _, p := range policies {
+		if matchLabels(p.Spec.Selector.MatchLabels, deployment.Labels) {
+			coverage.Add(deployment.Namespace, deployment.Name, p.Spec.Tags, report.CoverageApplied)
+		}
+	}
+}
+
+func writeCoverage() {
+	if coverage == nil {
+		return
+	}
+	out := filepath.Clean(filepath.Join(options.OutDir, options.CoverageReport))
+	if err := coverage.Write(out); err != nil {
+		log.WithError(err).Error("coverage report failed")
+		return
+	}
+	color.Green("output coverage report in %s ...", out)
+}
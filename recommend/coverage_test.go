@@ -0,0 +1,65 @@
+package recommend
+
+import (
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	kspfake "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/client/clientset/versioned/fake"
+	"github.com/kubearmor/kubearmor-client/k8s"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
+)
+
+func TestCoverage(t *testing.T) {
+	applied := testPolicy("wordpress-block", "/usr/bin/apt")
+	applied.Spec.Tags = []string{"MITRE_T1610_deploy_container"}
+	client := &k8s.Client{
+		KSPClientset: kspfake.NewSimpleClientset(applied).SecurityV1(),
+	}
+	coverage = report.NewCoverage()
+	defer func() { coverage = nil }()
+
+	wordpress := Deployment{Name: "wordpress", Namespace: "wordpress-mysql", Labels: LabelMap{"app": "wordpress", "tier": "frontend"}}
+	mysql := Deployment{Name: "mysql", Namespace: "wordpress-mysql", Labels: LabelMap{"app": "mysql"}}
+	recordCoverage(wordpress, "wordpress:4.8-apache", map[string]interface{}{
+		"out/wordpress-pkg-mngr-exec.yaml": common.MatchSpec{Spec: pol.KubeArmorPolicySpec{Tags: []string{"NIST", "MITRE_T1610_deploy_container"}}},
+	})
+	recordCoverage(mysql, "mysql:5.6", nil)
+	live := map[string][]pol.KubeArmorPolicy{}
+	recordAppliedCoverage(client, wordpress, live)
+	recordAppliedCoverage(client, mysql, live)
+
+	m := coverage.Matrix()
+	if len(m.Workloads) != 2 {
+		t.Fatalf("unexpected workloads %+v", m.Workloads)
+	}
+	if wl := m.Workloads[1]; wl.Workload != "wordpress" || wl.Controls["MITRE_T1610_deploy_container"] != report.CoverageApplied || wl.Controls["NIST"] != report.CoverageRecommended {
+		t.Errorf("unexpected wordpress coverage %+v", wl)
+	}
+	if wl := m.Workloads[0]; len(wl.Controls) != 0 {
+		t.Errorf("unexpected mysql coverage %+v", wl)
+	}
+}
+
+func TestReviewedCoverage(t *testing.T) {
+	coverage = report.NewCoverage()
+	options = common.Options{Interactive: true}
+	defer func() { coverage, options, generated = nil, common.Options{}, nil }()
+
+	wordpress := Deployment{Name: "wordpress", Namespace: "wordpress-mysql"}
+	recordCoverage(wordpress, "wordpress:4.8-apache", map[string]interface{}{
+		"out/wordpress-pkg-mngr-exec.yaml": common.MatchSpec{Spec: pol.KubeArmorPolicySpec{Tags: []string{"NIST"}}},
+		"out/wordpress-cert-access.yaml":   common.MatchSpec{Spec: pol.KubeArmorPolicySpec{Tags: []string{"MITRE"}}},
+	})
+	if m := coverage.Matrix(); len(m.Workloads) != 0 {
+		t.Fatalf("coverage recorded before the review %+v", m.Workloads)
+	}
+
+	// the review rejected the cert-access policy
+	generated = map[string][]byte{"out/wordpress-pkg-mngr-exec.yaml": nil}
+	recordReviewedCoverage()
+	m := coverage.Matrix()
+	if len(m.Workloads) != 1 || len(m.Workloads[0].Controls) != 1 || m.Workloads[0].Controls["NIST"] != report.CoverageRecommended {
+		t.Errorf("expected the coverage of the kept policies only, got %+v", m.Workloads)
+	}
+}
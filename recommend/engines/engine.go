@@ -14,3 +14,9 @@ type Engine interface {
 	Init() error
 	Scan(img *image.Info, options common.Options) (map[string][]byte, map[string]interface{}, error)
 }
+
+// ImageIndependent is implemented by engines which generate policies without
+// analyzing the contents of the container image, e.g. from recorded telemetry
+type ImageIndependent interface {
+	ImageIndependent() bool
+}
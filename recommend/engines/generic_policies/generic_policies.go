@@ -28,12 +28,56 @@ const (
 
 // GenericPolicy defines Policy Generators
 type GenericPolicy struct {
+	// Templates local policy-templates directory, zip or tar.gz
+	Templates string
+	// TemplatesVersion pinned policy-templates release
+	TemplatesVersion string
+	// Rules user defined rules file or directory, merged with policy-templates
+	Rules string
+	// HostPolicy adds the built-in host rules, for node root filesystems
+	HostPolicy bool
 }
 
 // Init initializing Policy Generator
 func (P GenericPolicy) Init() error {
+	if err := P.loadTemplates(); err != nil {
+		return err
+	}
+	if P.HostPolicy {
+		if err := LoadHostRules(); err != nil {
+			return err
+		}
+	}
+	if P.Rules != "" {
+		if err := LoadRules(P.Rules); err != nil {
+			return fmt.Errorf("failed to load rules from %s: %w", P.Rules, err)
+		}
+	}
+	return nil
+}
+
+func (P GenericPolicy) loadTemplates() error {
+	if P.Templates != "" {
+		if err := LoadTemplates(P.Templates); err != nil {
+			return fmt.Errorf("failed to load policy-templates from %s: %w", P.Templates, err)
+		}
+		log.WithFields(log.Fields{
+			"Templates": P.Templates,
+			"Version":   CurrentVersion,
+		}).Info("using local policy-templates")
+		return nil
+	}
+	if P.TemplatesVersion != "" {
+		if err := LoadVersion(P.TemplatesVersion); err != nil {
+			return fmt.Errorf("failed to load policy-templates %s: %w", P.TemplatesVersion, err)
+		}
+		log.WithFields(log.Fields{
+			"Version": CurrentVersion,
+		}).Info("using pinned policy-templates version")
+		return nil
+	}
 	if _, err := DownloadAndUnzipRelease(); err != nil {
-		log.WithError(err).Error("could not download latest policy-templates version")
+		log.WithError(err).Warn("could not download latest policy-templates version, using the cached one. Use --templates in air-gapped environments")
 	} else {
 		log.WithFields(log.Fields{
 			"Updated Version": LatestVersion,
@@ -80,15 +124,32 @@ func matchTags(ms *common.MatchSpec, tags []string) bool {
 	return false
 }
 
-func checkPreconditions(img *image.Info, ms *common.MatchSpec) bool {
-	var matches []string
+// checkPreconditions checks whether the rule applies to the image and returns
+// what each precondition matched
+func checkPreconditions(img *image.Info, ms *common.MatchSpec) ([]common.Evidence, bool) {
+	var evidence []common.Evidence
+	matched := 0
+	paths := 0
 	for _, preCondition := range ms.Precondition {
-		matches = append(matches, checkForSpec(filepath.Join(preCondition), img.FileList)...)
+		if kind, val, ok := splitPrecondition(preCondition); ok {
+			matches := matchPrecondition(img, kind, val)
+			if len(matches) == 0 {
+				return nil, false
+			}
+			evidence = append(evidence, common.Evidence{Precondition: preCondition, Matches: matches})
+			continue
+		}
+		paths++
+		matches := checkForSpec(filepath.Join(preCondition), img.FileList)
+		matched += len(matches)
+		if len(matches) > 0 {
+			evidence = append(evidence, common.Evidence{Precondition: preCondition, Matches: matches})
+		}
 		if strings.Contains(preCondition, "OPTSCAN") {
-			return true
+			return evidence, true
 		}
 	}
-	return len(matches) >= len(ms.Precondition)
+	return evidence, matched >= paths
 }
 
 func getPolicyFromImageInfo(img *image.Info, options common.Options) (map[string][]byte, map[string]interface{}, error) {
@@ -98,7 +159,10 @@ func getPolicyFromImageInfo(img *image.Info, options common.Options) (map[string
 	msMap := make(map[string]interface{})
 
 	if img.OS != "linux" {
-		log.Errorf("non-linux platforms are not supported, yet.")
+		log.WithFields(log.Fields{
+			"image": img.Name,
+			"os":    img.OS,
+		}).Error("non-linux platforms are not supported, select a linux variant with --platform or --all-platforms")
 		return nil, nil, nil
 	}
 
@@ -110,15 +174,41 @@ func getPolicyFromImageInfo(img *image.Info, options common.Options) (map[string
 	}
 	var ms common.MatchSpec
 	var err error
+	kind := common.KindKubeArmorPolicy
+	if options.HostPolicy() {
+		kind = common.KindKubeArmorHostPolicy
+	}
 
 	ms, err = getNextRule(&idx)
 	for ; err == nil; ms, err = getNextRule(&idx) {
 
-		if !matchTags(&ms, options.Tags) {
+		if !matchTags(&ms, options.Tags) || !ms.AppliesTo(kind) {
+			continue
+		}
+
+		evidence, ok := checkPreconditions(img, &ms)
+		if !ok {
 			continue
 		}
+		ms.Evidence = evidence
+		if ms.Source == "" {
+			ms.TemplateVersion = CurrentVersion
+		}
+		policy, outFile = img.GetPolicy(ms, options)
+		policyMap[outFile] = policy
+		msMap[outFile] = ms
+	}
 
-		if !checkPreconditions(img, &ms) {
+	if options.HostPolicy() {
+		// setuid binaries and world-writable paths are expected on nodes
+		return policyMap, msMap, nil
+	}
+	rules := riskyFileRules(img)
+	if options.NetworkBaseline {
+		rules = append(rules, networkRules(img)...)
+	}
+	for _, ms := range rules {
+		if !matchTags(&ms, options.Tags) {
 			continue
 		}
 		policy, outFile = img.GetPolicy(ms, options)
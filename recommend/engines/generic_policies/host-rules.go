@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package genericpolicies
+
+import (
+	_ "embed" // need for embedding
+	"encoding/json"
+	"fmt"
+
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"sigs.k8s.io/yaml"
+)
+
+// SourceHostTemplates provenance of the built-in node hardening rules
+const SourceHostTemplates = "host-templates"
+
+//go:embed yaml/host-rules.yaml
+var hostRulesYAML []byte
+
+// hostRules returns the built-in rules for node root filesystems
+func hostRules() ([]common.MatchSpec, error) {
+	data, err := yaml.YAMLToJSON(hostRulesYAML)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		PolicyRules []common.MatchSpec `json:"policyRules"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for i := range doc.PolicyRules {
+		doc.PolicyRules[i].Source = SourceHostTemplates
+	}
+	return doc.PolicyRules, nil
+}
+
+// LoadHostRules merges the built-in host rules with the policy-templates rules,
+// rules are picked by their kinds when scanning
+func LoadHostRules() error {
+	rules, err := hostRules()
+	if err != nil {
+		return fmt.Errorf("failed to load host rules: %w", err)
+	}
+	mergeRules(rules)
+	return nil
+}
@@ -0,0 +1,80 @@
+package genericpolicies
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
+)
+
+func TestHostRules(t *testing.T) {
+	rules, err := hostRules()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, ms := range rules {
+		if err := validateRule(ms); err != nil {
+			t.Errorf("%s: %s", ms.Name, err)
+		}
+		if ms.AppliesTo(common.KindKubeArmorPolicy) || ms.Provenance() != SourceHostTemplates {
+			t.Errorf("%s: expected a host only rule", ms.Name)
+		}
+	}
+
+	policyRules = []common.MatchSpec{{
+		Name:         "container-only",
+		Precondition: []string{"/etc/passwd"},
+		Kinds:        []string{common.KindKubeArmorPolicy},
+		Spec: pol.KubeArmorPolicySpec{
+			Action: "Audit",
+			File:   pol.FileType{MatchPaths: []pol.FilePathType{{Path: "/etc/passwd"}}},
+		},
+	}}
+	if err := LoadHostRules(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir := t.TempDir()
+	if err := report.Init(filepath.Join(dir, "report.txt"), ""); err != nil {
+		t.Fatal(err.Error())
+	}
+	img := &image.Info{
+		OS:         "linux",
+		RepoTags:   []string{"node-1"},
+		Labels:     image.LabelMap{"kubernetes.io/hostname": "node-1"},
+		FileList:   []string{"/etc/passwd", "/etc/kubernetes/kubelet.conf", "/usr/sbin/modprobe"},
+		RiskyFiles: []image.FileAttr{{Path: "/usr/bin/sudo", Mode: 04755}},
+	}
+	options := common.Options{OutDir: dir, Policy: []string{common.KindKubeArmorHostPolicy}}
+	policyMap, msMap, err := getPolicyFromImageInfo(img, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(policyMap) != 2 {
+		t.Fatalf("expected the k8s-config-protect and kernel-module-load host policies, got %v", msMap)
+	}
+	for outFile, data := range policyMap {
+		var policy pol.KubeArmorHostPolicy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			t.Fatal(err.Error())
+		}
+		if policy.Kind != common.KindKubeArmorHostPolicy || policy.Namespace != "" ||
+			policy.Spec.NodeSelector.MatchLabels["kubernetes.io/hostname"] != "node-1" {
+			t.Errorf("%s: unexpected host policy %+v", outFile, policy)
+		}
+	}
+
+	options.Policy = nil
+	policyMap, _, err = getPolicyFromImageInfo(img, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// container-only and setuid-binaries
+	if len(policyMap) != 2 {
+		t.Errorf("host rules should not apply to pod policies, got %d policies", len(policyMap))
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package genericpolicies
+
+import (
+	"fmt"
+	"strings"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+// SourceWorkloadNetwork provenance of the rules derived from the ports of the workload
+const SourceWorkloadNetwork = "workload-network"
+
+func describePorts(ports []image.Port) []string {
+	var desc []string
+	for _, p := range ports {
+		desc = append(desc, fmt.Sprintf("%s (%s)", p, p.Source))
+	}
+	return desc
+}
+
+// protocols KubeArmor can match of the listening ports
+var portProtocols = []string{"tcp", "udp"}
+
+// baselineProtocols protocols of the ports, with udp for the DNS lookups
+func baselineProtocols(ports []image.Port) []string {
+	found := map[string]bool{}
+	for _, p := range ports {
+		found[p.Protocol] = true
+	}
+	var protocols []string
+	for _, proto := range portProtocols {
+		// tcp services still resolve names over udp
+		if found[proto] || proto == "udp" && found["tcp"] {
+			protocols = append(protocols, proto)
+		}
+	}
+	return protocols
+}
+
+// networkRules recommends a network baseline for workloads listening on ports
+// found in the image config, the container spec or the Services selecting the
+// workload: the protocols of the ports are allowed, and udp for DNS lookups,
+// raw sockets and icmp are not. The rule switches the workload to a network
+// allow list, it is only recommended with --network-baseline. KubeArmor
+// matches protocols only, the ports are enforced by the CiliumNetworkPolicy
+// generated with --network-policy cilium.
+func networkRules(img *image.Info) []common.MatchSpec {
+	ports := img.NetworkPorts()
+	protocols := baselineProtocols(ports)
+	if len(protocols) == 0 {
+		return nil
+	}
+	listening := describePorts(ports)
+	var matchProtocols []pol.MatchNetworkProtocolType
+	for _, proto := range protocols {
+		matchProtocols = append(matchProtocols, pol.MatchNetworkProtocolType{Protocol: pol.MatchNetworkProtocolStringType(proto)})
+	}
+	return []common.MatchSpec{{
+		Name:     "network-baseline",
+		Evidence: []common.Evidence{{Precondition: "listening ports", Matches: listening}},
+		Description: common.Description{
+			Tldr:     fmt.Sprintf("Allow only %s for the workload listening on %d ports", strings.Join(protocols, " and "), len(ports)),
+			Detailed: "Network services rarely need raw sockets or icmp, which can be abused for scanning and spoofing. Listening on " + strings.Join(listening, ", "),
+			Refs: []common.Ref{{
+				Name: "MITRE-TTP",
+				URL:  []string{"https://attack.mitre.org/techniques/T1046/"},
+			}},
+		},
+		Source: SourceWorkloadNetwork,
+		Spec: pol.KubeArmorPolicySpec{
+			Severity: 3,
+			Action:   "Allow",
+			Message:  "network protocol outside of the workload baseline",
+			Tags:     []string{"NIST", "NIST_SC-7_boundary_protection", "MITRE"},
+			Network:  pol.NetworkType{MatchProtocols: matchProtocols},
+		},
+	}}
+}
@@ -0,0 +1,55 @@
+package genericpolicies
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+func TestNetworkRules(t *testing.T) {
+	if rules := networkRules(&image.Info{}); len(rules) != 0 {
+		t.Errorf("no rules expected without ports, got %+v", rules)
+	}
+
+	img := &image.Info{
+		Config: image.Config{ExposedPorts: []string{"80/tcp", "invalid"}},
+		Ports: []image.Port{
+			{Port: 80, Protocol: "tcp", Source: "service/web"},
+			{Port: 9090, Protocol: "tcp", Source: image.PortSourceContainer},
+		},
+	}
+	rules := networkRules(img)
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	ms := rules[0]
+	if ms.Spec.Action != "Allow" || ms.Provenance() != SourceWorkloadNetwork {
+		t.Errorf("unexpected network rule %+v", ms)
+	}
+	if !strings.Contains(ms.Description.Detailed, "80/tcp (image,service/web), 9090/tcp (container)") {
+		t.Errorf("ports missing from the description: %s", ms.Description.Detailed)
+	}
+}
+
+func TestNetworkRulesProtocols(t *testing.T) {
+	for _, tc := range []struct {
+		ports []image.Port
+		want  string
+	}{
+		{[]image.Port{{Port: 80, Protocol: "tcp"}}, "tcp,udp"},
+		{[]image.Port{{Port: 53, Protocol: "udp"}}, "udp"},
+		{[]image.Port{{Port: 53, Protocol: "udp"}, {Port: 8080, Protocol: "tcp"}}, "tcp,udp"},
+		{[]image.Port{{Port: 3868, Protocol: "sctp"}}, ""},
+	} {
+		var got []string
+		for _, ms := range networkRules(&image.Info{Ports: tc.ports}) {
+			for _, p := range ms.Spec.Network.MatchProtocols {
+				got = append(got, string(p.Protocol))
+			}
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("ports %v: expected protocols %q, got %q", tc.ports, tc.want, strings.Join(got, ","))
+		}
+	}
+}
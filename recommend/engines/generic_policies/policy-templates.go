@@ -4,9 +4,13 @@
 package genericpolicies
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	_ "embed" // need for embedding
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +18,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/clarketm/json"
@@ -115,6 +120,9 @@ func downloadZip(url string, destination string) error {
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download of %s failed: %s", url, resp.Status)
+	}
 
 	out, err := os.Create(filepath.Clean(destination))
 	if err != nil {
@@ -134,52 +142,355 @@ func downloadZip(url string, destination string) error {
 	return nil
 }
 
-// DownloadAndUnzipRelease downloads the latest version of policy-templates
-func DownloadAndUnzipRelease() (string, error) {
-	latestVersion := latestRelease()
-	currentVersion := CurrentRelease()
+// getTemplatesPath returns the directory holding the cached policy-templates versions
+func getTemplatesPath() string {
+	return filepath.Join(getCachePath(), "templates")
+}
 
-	if isLatest() {
-		return latestVersion, nil
+func getVersionPath(version string) (string, error) {
+	if version == "" || version != filepath.Base(version) || strings.HasPrefix(version, ".") {
+		return "", fmt.Errorf("invalid policy-templates version %q", version)
 	}
+	return filepath.Join(getTemplatesPath(), version), nil
+}
 
-	log.WithFields(log.Fields{
-		"Current Version": currentVersion,
-	}).Info("Found outdated version of policy-templates")
-	log.Info("Downloading latest version [", latestVersion, "]")
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	err := removeData(getCachePath())
+// parseChecksums parses "<sha256>  <file>" lines as written by sha256sum
+func parseChecksums(data []byte) map[string]string {
+	sums := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 1 {
+			sums[""] = strings.ToLower(fields[0])
+		} else if len(fields) >= 2 {
+			sums[path.Base(strings.TrimPrefix(fields[1], "*"))] = strings.ToLower(fields[0])
+		}
+	}
+	return sums
+}
+
+// publishedChecksum returns the checksum published with the release for the
+// zip, or an empty string if the release has none
+func publishedChecksum(version string) (string, error) {
+	release, _, err := github.NewClient(nil).Repositories.GetReleaseByTag(context.Background(), org, repo, version)
 	if err != nil {
-		log.WithError(err).Error("failed to remove cache files")
+		return "", err
 	}
-	err = os.MkdirAll(filepath.Dir(getCachePath()), 0750)
+	for _, asset := range release.Assets {
+		name := asset.GetName()
+		if name != "checksums.txt" && name != "sha256sums.txt" && name != version+".zip.sha256" {
+			continue
+		}
+		resp, err := http.Get(asset.GetBrowserDownloadURL())
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return "", fmt.Errorf("download of %s failed: %s", name, resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		sums := parseChecksums(data)
+		for _, key := range []string{version + ".zip", repo + "-" + strings.TrimPrefix(version, "v") + ".zip", ""} {
+			if sum, ok := sums[key]; ok {
+				return sum, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// verifyChecksum checks the downloaded zip against the published checksum.
+// Releases without a published checksum, or whose checksum cannot be
+// fetched, are downloaded unverified.
+func verifyChecksum(version, zipPath string) (string, error) {
+	sum, err := fileSHA256(zipPath)
 	if err != nil {
 		return "", err
 	}
+	fields := log.Fields{"version": version, "sha256": sum}
+	expected, err := publishedChecksum(version)
+	if err != nil {
+		log.WithError(err).WithFields(fields).Warn("could not get the published checksum of policy-templates, the download is NOT verified")
+		return sum, nil
+	}
+	if expected == "" {
+		log.WithFields(fields).Warn("no checksum published for policy-templates, the download is NOT verified")
+		return sum, nil
+	}
+	if expected != sum {
+		return "", fmt.Errorf("checksum mismatch for policy-templates %s: expected %s, got %s", version, expected, sum)
+	}
+	log.WithFields(fields).Info("policy-templates checksum verified")
+	return sum, nil
+}
 
-	downloadURL := fmt.Sprintf("%s%s.zip", url, latestVersion)
-	zipPath := getCachePath() + ".zip"
-	err = downloadZip(downloadURL, zipPath)
+// checksumFile records the checksums of a cached policy-templates version:
+// the downloaded zip and the extracted tree, checked on every load
+const checksumFile = "sha256"
 
-	if err != nil {
-		err = removeData(getCachePath())
+// treeChecksum names the checksum of the extracted tree in checksumFile
+const treeChecksum = "tree"
+
+// treeSHA256 hashes the files of a directory with their relative paths,
+// checksumFile excluded
+func treeSHA256(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.WithError(err).Error("failed to remove cache files")
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == checksumFile {
+			return err
 		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(h, "%s  %s\n", sum, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
 		return "", err
 	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyCache checks a cached version against the checksum recorded when it
+// was downloaded
+func verifyCache(version, dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, checksumFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	expected, ok := parseChecksums(data)[treeChecksum]
+	if !ok {
+		log.WithField("version", version).Warn("no checksum recorded for the cached policy-templates, it is NOT verified")
+		return nil
+	}
+	sum, err := treeSHA256(dir)
+	if err != nil {
+		return err
+	}
+	if sum != expected {
+		return fmt.Errorf("cached policy-templates %s does not match its recorded checksum, remove %s to download it again", version, dir)
+	}
+	return nil
+}
+
+// fetchRelease downloads, verifies and caches a policy-templates release
+func fetchRelease(version string) error {
+	dir, err := getVersionPath(version)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(getTemplatesPath(), 0750); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(getTemplatesPath(), ".download-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := removeData(tmp); err != nil {
+			log.WithError(err).Error("failed to remove cache files")
+		}
+	}()
+
+	log.Info("Downloading policy-templates version [", version, "]")
+	zipPath := filepath.Join(tmp, version+".zip")
+	if err := downloadZip(fmt.Sprintf("%s%s.zip", url, version), zipPath); err != nil {
+		return err
+	}
+	sum, err := verifyChecksum(version, zipPath)
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(tmp, "src")
+	if err := unZip(zipPath, src); err != nil {
+		return err
+	}
+	if err := updatePolicyRules(src, filepath.Join(src, "rules.yaml")); err != nil {
+		return err
+	}
+	tree, err := treeSHA256(src)
+	if err != nil {
+		return err
+	}
+	sums := fmt.Sprintf("%s  %s.zip\n%s  %s\n", sum, version, tree, treeChecksum)
+	if err := os.WriteFile(filepath.Join(src, checksumFile), []byte(sums), 0600); err != nil {
+		return err
+	}
+	if err := removeData(dir); err != nil {
+		return err
+	}
+	return os.Rename(src, dir)
+}
 
-	err = unZip(zipPath, getCachePath())
+// CachedVersions lists the policy-templates versions available offline
+func CachedVersions() ([]string, error) {
+	entries, err := os.ReadDir(getTemplatesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(getTemplatesPath(), e.Name(), "rules.yaml")); err == nil {
+			versions = append(versions, e.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+func ensureVersion(version string) (string, error) {
+	dir, err := getVersionPath(version)
 	if err != nil {
 		return "", err
 	}
-	err = removeData(zipPath)
+	rules := filepath.Join(dir, "rules.yaml")
+	if _, err := os.Stat(rules); errors.Is(err, os.ErrNotExist) {
+		if err := fetchRelease(version); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	} else if err := verifyCache(version, dir); err != nil {
+		return "", err
+	}
+	return rules, nil
+}
+
+// UseVersion makes a policy-templates version the default one, downloading
+// it if it is not cached yet
+func UseVersion(version string) error {
+	rules, err := ensureVersion(version)
 	if err != nil {
-		log.WithError(err).Error("failed to remove cache files")
+		return err
 	}
-	err = updatePolicyRules(strings.TrimSuffix(zipPath, ".zip"))
+	data, err := os.ReadFile(filepath.Clean(rules))
 	if err != nil {
-		log.WithError(err).Error("failed to update policy rules")
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(getCachePath(), "rules.yaml"), data, 0600); err != nil {
+		return err
+	}
+	CurrentVersion = CurrentRelease()
+	return nil
+}
+
+// LoadVersion loads the rules of a policy-templates version for this run only,
+// downloading it if it is not cached yet
+func LoadVersion(version string) error {
+	rules, err := ensureVersion(version)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Clean(rules))
+	if err != nil {
+		return err
+	}
+	CurrentVersion = strings.Trim(updateRulesYAML(data), "\"")
+	return nil
+}
+
+// LoadTemplates loads the rules from a local policy-templates directory, zip
+// or tar.gz archive, without network access
+func LoadTemplates(templates string) error {
+	info, err := os.Stat(templates)
+	if err != nil {
+		return err
+	}
+	dir := templates
+	if !info.IsDir() {
+		tmp, err := os.MkdirTemp("", "karmor-templates-")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := removeData(tmp); err != nil {
+				log.WithError(err).Error("failed to remove temporary files")
+			}
+		}()
+		switch {
+		case strings.HasSuffix(templates, ".zip"):
+			err = unZip(templates, tmp)
+		case strings.HasSuffix(templates, ".tar.gz"), strings.HasSuffix(templates, ".tgz"):
+			err = unTarGz(templates, tmp)
+		default:
+			err = fmt.Errorf("unsupported policy-templates archive %s, use a directory, zip or tar.gz", templates)
+		}
+		if err != nil {
+			return err
+		}
+		dir = tmp
+	}
+
+	rules := filepath.Join(dir, "rules.yaml")
+	if _, err := os.Stat(rules); err == nil {
+		// already processed templates, e.g. a copy of the cache
+		data, err := os.ReadFile(filepath.Clean(rules))
+		if err != nil {
+			return err
+		}
+		CurrentVersion = strings.Trim(updateRulesYAML(data), "\"")
+	} else {
+		ms, version, err := buildPolicyRules(dir)
+		if err != nil {
+			return err
+		}
+		policyRules = ms
+		CurrentVersion = version
+	}
+	if len(policyRules) == 0 {
+		return fmt.Errorf("no policy templates found in %s", templates)
+	}
+	if CurrentVersion == "" {
+		CurrentVersion = "local"
+	}
+	return nil
+}
+
+// DownloadAndUnzipRelease downloads the latest version of policy-templates
+func DownloadAndUnzipRelease() (string, error) {
+	latestVersion := latestRelease()
+	currentVersion := CurrentRelease()
+
+	if isLatest() {
+		return latestVersion, nil
+	}
+
+	log.WithFields(log.Fields{
+		"Current Version": currentVersion,
+	}).Info("Found outdated version of policy-templates")
+
+	if err := UseVersion(latestVersion); err != nil {
+		return "", err
 	}
 	return latestVersion, nil
 }
@@ -236,6 +547,50 @@ func unZip(source, dest string) error {
 	return nil
 }
 
+func unTarGz(source, dest string) error {
+	f, err := os.Open(filepath.Clean(source))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, err := sanitizeArchivePath(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(name), 0750); err != nil {
+			return err
+		}
+		create, err := os.Create(filepath.Clean(name))
+		if err != nil {
+			return err
+		}
+		// policy templates are small yaml files
+		if _, err = io.Copy(create, io.LimitReader(tr, 64<<20)); err != nil {
+			_ = create.Close()
+			return err
+		}
+		if err = create.Close(); err != nil {
+			return err
+		}
+	}
+}
+
 func getNextRule(idx *int) (common.MatchSpec, error) {
 	if *idx < 0 {
 		(*idx)++
@@ -248,7 +603,8 @@ func getNextRule(idx *int) (common.MatchSpec, error) {
 	return r, nil
 }
 
-func updatePolicyRules(filePath string) error {
+// buildPolicyRules collects the rules of all the metadata.yaml files under filePath
+func buildPolicyRules(filePath string) ([]common.MatchSpec, string, error) {
 	var files []string
 	err := filepath.Walk(filePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -260,12 +616,7 @@ func updatePolicyRules(filePath string) error {
 		return nil
 	})
 	if err != nil {
-		return err
-	}
-	rulesYamlPath := filepath.Join(getCachePath(), "rules.yaml")
-	f, err := os.Create(filepath.Clean(rulesYamlPath))
-	if err != nil {
-		log.WithError(err).Errorf("Failed to create %s", rulesYamlPath)
+		return nil, "", err
 	}
 
 	var yamlFile []byte
@@ -276,7 +627,7 @@ func updatePolicyRules(filePath string) error {
 		idx := 0
 		yamlFile, err = os.ReadFile(filepath.Clean(file))
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 		version = updateRulesYAML(yamlFile)
 		ms, err := getNextRule(&idx)
@@ -289,14 +640,14 @@ func updatePolicyRules(filePath string) error {
 				}
 				err = yaml.Unmarshal(newYaml, &policy)
 				if err != nil {
-					return err
+					return nil, "", err
 				}
 				apiVersion := policy["apiVersion"].(string)
 				if strings.Contains(apiVersion, "kubearmor") {
 					var kubeArmorPolicy pol.KubeArmorPolicy
 					err = yaml.Unmarshal(newYaml, &kubeArmorPolicy)
 					if err != nil {
-						return err
+						return nil, "", err
 					}
 					ms.Spec = kubeArmorPolicy.Spec
 				} else {
@@ -308,11 +659,23 @@ func updatePolicyRules(filePath string) error {
 		}
 	}
 	policyRules = completePolicy
-	yamlFile, err = yaml.Marshal(completePolicy)
+	return completePolicy, strings.Trim(version, "\""), nil
+}
+
+// updatePolicyRules processes the templates under filePath into rulesYamlPath
+func updatePolicyRules(filePath, rulesYamlPath string) error {
+	completePolicy, version, err := buildPolicyRules(filePath)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Clean(rulesYamlPath))
+	if err != nil {
+		return err
+	}
+	yamlFile, err := yaml.Marshal(completePolicy)
 	if err != nil {
 		return err
 	}
-	version = strings.Trim(version, "\"")
 	yamlFile = []byte(fmt.Sprintf("version: %s\npolicyRules:\n%s", version, yamlFile))
 	if _, err := f.WriteString(string(yamlFile)); err != nil {
 		log.WithError(err).Error("WriteString failed")
@@ -0,0 +1,203 @@
+package genericpolicies
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var templateFiles = map[string]string{
+	"policy-templates-1.2.3/pkg-mngr/metadata.yaml": `version: v1.2.3
+policyRules:
+- name: pkg-mngr-exec
+  precondition:
+  - /usr/bin/apt
+  description:
+    tldr: Deny execution of package manager process in container
+  yaml: pkg-mngr.yaml
+`,
+	"policy-templates-1.2.3/pkg-mngr/pkg-mngr.yaml": `apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: pkg-mngr-exec
+spec:
+  severity: 5
+  action: Block
+  process:
+    matchPaths:
+    - path: /usr/bin/apt
+`,
+}
+
+func writeTemplatesDir(t *testing.T, dir string) {
+	for name, content := range templateFiles {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func writeTemplatesZip(t *testing.T, file string) {
+	f, err := os.Create(filepath.Clean(file))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range templateFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func writeTemplatesTarGz(t *testing.T, file string) {
+	f, err := os.Create(filepath.Clean(file))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range templateFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for _, c := range []interface{ Close() error }{tw, gz, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "templates")
+	writeTemplatesDir(t, dir)
+	writeTemplatesZip(t, filepath.Join(tmp, "templates.zip"))
+	writeTemplatesTarGz(t, filepath.Join(tmp, "templates.tar.gz"))
+
+	for _, src := range []string{dir, filepath.Join(tmp, "templates.zip"), filepath.Join(tmp, "templates.tar.gz")} {
+		policyRules = nil
+		CurrentVersion = ""
+		if err := LoadTemplates(src); err != nil {
+			t.Fatalf("%s: %s", src, err.Error())
+		}
+		if CurrentVersion != "v1.2.3" {
+			t.Errorf("%s: unexpected version %q", src, CurrentVersion)
+		}
+		if len(policyRules) != 1 || policyRules[0].Name != "pkg-mngr-exec" ||
+			policyRules[0].Spec.Severity != 5 || len(policyRules[0].Spec.Process.MatchPaths) != 1 {
+			t.Errorf("%s: unexpected rules %+v", src, policyRules)
+		}
+	}
+
+	if err := LoadTemplates(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory without templates")
+	}
+}
+
+func TestUseCachedVersion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	src := filepath.Join(home, "src")
+	writeTemplatesDir(t, src)
+	versionDir, err := getVersionPath("v1.2.3")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.MkdirAll(versionDir, 0750); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := updatePolicyRules(src, filepath.Join(versionDir, "rules.yaml")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	versions, err := CachedVersions()
+	if err != nil || len(versions) != 1 || versions[0] != "v1.2.3" {
+		t.Fatalf("unexpected cached versions %v (%v)", versions, err)
+	}
+	if err := UseVersion("v1.2.3"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if CurrentVersion != "v1.2.3" || CurrentRelease() != "v1.2.3" {
+		t.Errorf("version not in use: %s", CurrentVersion)
+	}
+	if _, err := getVersionPath("../v1.2.3"); err == nil {
+		t.Error("expected an error for an invalid version")
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := parseChecksums([]byte("ABCD  v1.2.3.zip\nef01 *dist/other.zip\n"))
+	if sums["v1.2.3.zip"] != "abcd" || sums["other.zip"] != "ef01" {
+		t.Errorf("unexpected checksums %v", sums)
+	}
+	if sums := parseChecksums([]byte("abcd\n")); sums[""] != "abcd" {
+		t.Errorf("unexpected checksums %v", sums)
+	}
+}
+
+func TestVerifyCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	src := filepath.Join(home, "src")
+	writeTemplatesDir(t, src)
+	versionDir, err := getVersionPath("v1.2.3")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.MkdirAll(versionDir, 0750); err != nil {
+		t.Fatal(err.Error())
+	}
+	rules := filepath.Join(versionDir, "rules.yaml")
+	if err := updatePolicyRules(src, rules); err != nil {
+		t.Fatal(err.Error())
+	}
+	tree, err := treeSHA256(versionDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sums := "0123  v1.2.3.zip\n" + tree + "  " + treeChecksum + "\n"
+	if err := os.WriteFile(filepath.Join(versionDir, checksumFile), []byte(sums), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := LoadVersion("v1.2.3"); err != nil {
+		t.Fatalf("unmodified cache rejected: %s", err.Error())
+	}
+
+	f, err := os.OpenFile(filepath.Clean(rules), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := f.WriteString("# tampered\n"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := LoadVersion("v1.2.3"); err == nil {
+		t.Error("expected the modified cache to be rejected")
+	}
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package genericpolicies
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+// precondition kinds matched against the image config and package databases,
+// plain preconditions are regexes of file paths
+const (
+	preconditionPackage    = "package"
+	preconditionEntrypoint = "entrypoint"
+	preconditionUser       = "user"
+	preconditionPort       = "port"
+	preconditionEnv        = "env"
+)
+
+// splitPrecondition returns the kind and value of a typed precondition such as package:openssl
+func splitPrecondition(pre string) (string, string, bool) {
+	kind, val, ok := strings.Cut(pre, ":")
+	if !ok {
+		return "", "", false
+	}
+	switch kind {
+	case preconditionPackage, preconditionEntrypoint, preconditionUser, preconditionPort, preconditionEnv:
+		return kind, val, true
+	}
+	return "", "", false
+}
+
+func matchAll(re *regexp.Regexp, vals []string) []string {
+	var matches []string
+	for _, v := range vals {
+		if re.MatchString(v) {
+			matches = append(matches, v)
+		}
+	}
+	return matches
+}
+
+// isRootUser checks whether the image config user runs as root
+func isRootUser(user string) bool {
+	u, _, _ := strings.Cut(user, ":")
+	return u == "" || u == "0" || u == "root"
+}
+
+// matchPrecondition checks a typed precondition against the image and
+// returns the packages or config entries it matched
+func matchPrecondition(img *image.Info, kind, val string) []string {
+	re, err := regexp.Compile("^(" + val + ")$")
+	if err != nil {
+		return nil
+	}
+	switch kind {
+	case preconditionPackage:
+		var matches []string
+		for _, p := range img.Packages {
+			if re.MatchString(p.Name) {
+				matches = append(matches, fmt.Sprintf("%s %s (%s)", p.Name, p.Version, p.Manager))
+			}
+		}
+		return matches
+	case preconditionEntrypoint:
+		// executables and arguments of the entrypoint and command, e.g. sh -c "nginx -g ..."
+		var words []string
+		for _, arg := range append(append([]string{}, img.Config.Entrypoint...), img.Config.Cmd...) {
+			for _, w := range strings.Fields(arg) {
+				words = append(words, filepath.Base(w))
+			}
+		}
+		return matchAll(re, words)
+	case preconditionUser:
+		u, _, _ := strings.Cut(img.Config.User, ":")
+		if val == "root" && isRootUser(img.Config.User) || val != "root" && re.MatchString(u) {
+			if img.Config.User == "" {
+				return []string{"user not set (root)"}
+			}
+			return []string{"user " + img.Config.User}
+		}
+	case preconditionPort:
+		var matches []string
+		for _, p := range img.Config.ExposedPorts {
+			port, _, _ := strings.Cut(p, "/")
+			if re.MatchString(port) || re.MatchString(p) {
+				matches = append(matches, "exposed port "+p)
+			}
+		}
+		return matches
+	case preconditionEnv:
+		var names []string
+		for _, e := range img.Config.Env {
+			name, _, _ := strings.Cut(e, "=")
+			names = append(names, name)
+		}
+		return matchAll(re, names)
+	}
+	return nil
+}
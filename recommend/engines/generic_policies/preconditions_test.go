@@ -0,0 +1,69 @@
+package genericpolicies
+
+import (
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	img := &image.Info{
+		FileList: []string{"/usr/sbin/nginx", "/etc/ssl/openssl.cnf"},
+		Config: image.Config{
+			Entrypoint:   []string{"/docker-entrypoint.sh"},
+			Cmd:          []string{"/usr/sbin/nginx", "-g", "daemon off;"},
+			ExposedPorts: []string{"80/tcp", "22/tcp"},
+			Env:          []string{"PATH=/usr/bin", "NGINX_VERSION=1.25.3"},
+		},
+		Packages: []image.Package{{Name: "openssl", Version: "3.0.11", Manager: image.ManagerDpkg}},
+	}
+
+	for pre, want := range map[string]bool{
+		"package:openssl":    true,
+		"package:libssl.*":   false,
+		"package:openssl|ss": true,
+		"entrypoint:nginx":   true,
+		"entrypoint:httpd":   false,
+		"user:root":          true,
+		"user:nginx":         false,
+		"port:22":            true,
+		"port:80/udp":        false,
+		"env:NGINX_VERSION":  true,
+		"/usr/sbin/nginx":    true,
+		"/usr/bin/apt":       false,
+	} {
+		ms := common.MatchSpec{Name: pre, Precondition: []string{pre}}
+		if _, got := checkPreconditions(img, &ms); got != want {
+			t.Errorf("%s: expected %t, got %t", pre, want, got)
+		}
+	}
+
+	ms := common.MatchSpec{Precondition: []string{"/etc/ssl/.*", "package:openssl", "port:443"}}
+	if _, ok := checkPreconditions(img, &ms); ok {
+		t.Error("all the preconditions should match")
+	}
+	img.Config.User = "101:101"
+	ms = common.MatchSpec{Precondition: []string{"user:101"}}
+	if _, ok := checkPreconditions(img, &ms); !ok {
+		t.Error("expected user 101 to match")
+	}
+}
+
+func TestPreconditionEvidence(t *testing.T) {
+	img := &image.Info{
+		FileList: []string{"/usr/bin/apt", "/usr/bin/apt-get", "/usr/sbin/nginx"},
+		Packages: []image.Package{{Name: "openssl", Version: "3.0.11", Manager: image.ManagerDpkg}},
+	}
+	ms := common.MatchSpec{Precondition: []string{"/usr/bin/apt.*", "package:openssl"}}
+	evidence, ok := checkPreconditions(img, &ms)
+	if !ok || len(evidence) != 2 {
+		t.Fatalf("unexpected evidence %+v", evidence)
+	}
+	if e := evidence[0]; e.Precondition != "/usr/bin/apt.*" || len(e.Matches) != 2 || e.Matches[1] != "/usr/bin/apt-get" {
+		t.Errorf("unexpected file evidence %+v", e)
+	}
+	if e := evidence[1].String(); e != "package:openssl: openssl 3.0.11 (dpkg)" {
+		t.Errorf("unexpected package evidence %s", e)
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package genericpolicies
+
+import (
+	"fmt"
+	"strings"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+// SourceImageAnalysis provenance of the rules derived from the image files
+const SourceImageAnalysis = "image-analysis"
+
+func describeFiles(files []string) string {
+	return "Found in the image: " + strings.Join(files, ", ")
+}
+
+// expectedSetuid setuid/setgid binaries shipped by the stock base images, they
+// are not reported as risky
+var expectedSetuid = map[string]bool{}
+
+func init() {
+	for _, name := range []string{
+		// util-linux, shadow and login
+		"su", "mount", "umount", "passwd", "chsh", "chfn", "gpasswd", "newgrp", "chage", "expiry",
+		"unix_chkpwd", "pam_timestamp_check", "wall", "write",
+		// iputils on older images, busybox on alpine
+		"ping", "ping6", "bbsuid",
+	} {
+		for _, dir := range []string{"/bin/", "/sbin/", "/usr/bin/", "/usr/sbin/"} {
+			expectedSetuid[dir+name] = true
+		}
+	}
+	for _, path := range []string{
+		"/usr/lib/openssh/ssh-keysign",
+		"/usr/libexec/openssh/ssh-keysign",
+		"/usr/lib/dbus-1.0/dbus-daemon-launch-helper",
+		"/usr/libexec/dbus-1/dbus-daemon-launch-helper",
+		"/usr/libexec/utempter/utempter",
+	} {
+		expectedSetuid[path] = true
+	}
+}
+
+// riskyFileRules recommends rules targeting the risky files found in the image:
+// execution of unexpected setuid/setgid binaries and binaries with file
+// capabilities is audited and world-writable directories are made read-only
+func riskyFileRules(img *image.Info) []common.MatchSpec {
+	var setuid, writable, capable []string
+	var setuidPaths, capPaths []pol.ProcessPathType
+	var spec pol.FileType
+	for _, f := range img.RiskyFiles {
+		if (f.Setuid() || f.Setgid()) && !expectedSetuid[f.Path] {
+			setuid = append(setuid, f.String())
+			setuidPaths = append(setuidPaths, pol.ProcessPathType{Path: pol.MatchPathType(f.Path)})
+		}
+		if f.WorldWritable() {
+			writable = append(writable, f.String())
+			if f.Dir {
+				spec.MatchDirectories = append(spec.MatchDirectories, pol.FileDirectoryType{
+					Directory: pol.MatchDirectoryType(strings.TrimSuffix(f.Path, "/") + "/"),
+					Recursive: true,
+					ReadOnly:  true,
+				})
+			} else {
+				spec.MatchPaths = append(spec.MatchPaths, pol.FilePathType{
+					Path:     pol.MatchPathType(f.Path),
+					ReadOnly: true,
+				})
+			}
+		}
+		if len(f.Capabilities) > 0 && !f.Dir {
+			capable = append(capable, f.String())
+			capPaths = append(capPaths, pol.ProcessPathType{Path: pol.MatchPathType(f.Path)})
+		}
+	}
+
+	var rules []common.MatchSpec
+	if len(setuidPaths) > 0 {
+		rules = append(rules, common.MatchSpec{
+			Name:     "setuid-binaries",
+			Evidence: []common.Evidence{{Precondition: "setuid/setgid files", Matches: setuid}},
+			Description: common.Description{
+				Tldr:     fmt.Sprintf("Audit execution of the %d unexpected setuid/setgid binaries found in the image", len(setuidPaths)),
+				Detailed: "Containers should not need to elevate privileges, setuid/setgid binaries not shipped by the base image can be abused to gain the privileges of their owner. " + describeFiles(setuid),
+				Refs: []common.Ref{{
+					Name: "MITRE-TTP",
+					URL:  []string{"https://attack.mitre.org/techniques/T1548/001/"},
+				}},
+			},
+			Source: SourceImageAnalysis,
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 7,
+				Action:   "Audit",
+				Message:  "unexpected setuid/setgid binary executed",
+				Tags:     []string{"MITRE", "MITRE_T1548.001_setuid_and_setgid"},
+				Process:  pol.ProcessType{MatchPaths: setuidPaths},
+			},
+		})
+	}
+	if len(writable) > 0 {
+		rules = append(rules, common.MatchSpec{
+			Name:     "world-writable-paths",
+			Evidence: []common.Evidence{{Precondition: "world-writable files", Matches: writable}},
+			Description: common.Description{
+				Tldr:     fmt.Sprintf("Make the %d world-writable paths found in the image read-only", len(writable)),
+				Detailed: "World-writable files and directories without the sticky bit can be modified by any user of the container. " + describeFiles(writable),
+			},
+			Source: SourceImageAnalysis,
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 5,
+				Action:   "Block",
+				Message:  "write to world-writable path",
+				Tags:     []string{"CIS", "NIST"},
+				File:     spec,
+			},
+		})
+	}
+	if len(capPaths) > 0 {
+		rules = append(rules, common.MatchSpec{
+			Name:     "file-capabilities",
+			Evidence: []common.Evidence{{Precondition: "files with capabilities", Matches: capable}},
+			Description: common.Description{
+				Tldr:     fmt.Sprintf("Audit execution of the %d binaries with file capabilities found in the image", len(capPaths)),
+				Detailed: "Binaries with file capabilities gain privileges when executed. " + describeFiles(capable),
+			},
+			Source: SourceImageAnalysis,
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 4,
+				Action:   "Audit",
+				Message:  "binary with file capabilities executed",
+				Tags:     []string{"MITRE"},
+				Process:  pol.ProcessType{MatchPaths: capPaths},
+			},
+		})
+	}
+	return rules
+}
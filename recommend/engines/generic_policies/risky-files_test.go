@@ -0,0 +1,66 @@
+package genericpolicies
+
+import (
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+func TestRiskyFileRules(t *testing.T) {
+	img := &image.Info{RiskyFiles: []image.FileAttr{
+		{Path: "/bin/ping", Mode: 0755, Capabilities: []string{"cap_net_raw"}},
+		{Path: "/opt/app/bin/helper", Mode: 04755},
+		{Path: "/data", Mode: 0777, Dir: true},
+		{Path: "/etc/app.conf", Mode: 0666},
+		{Path: "/usr/local/bin/wall", Mode: 02755, GID: 5},
+	}}
+	rules := riskyFileRules(img)
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(rules))
+	}
+
+	setuid := rules[0]
+	if setuid.Name != "setuid-binaries" || setuid.Spec.Action != "Audit" || len(setuid.Spec.Process.MatchPaths) != 2 ||
+		setuid.Spec.Process.MatchPaths[1].Path != "/usr/local/bin/wall" || setuid.Provenance() != SourceImageAnalysis {
+		t.Errorf("unexpected setuid rule %+v", setuid)
+	}
+	writable := rules[1].Spec.File
+	if len(writable.MatchDirectories) != 1 || writable.MatchDirectories[0].Directory != "/data/" ||
+		!writable.MatchDirectories[0].ReadOnly || len(writable.MatchPaths) != 1 || writable.MatchPaths[0].Path != "/etc/app.conf" {
+		t.Errorf("unexpected world-writable rule %+v", writable)
+	}
+	if caps := rules[2]; caps.Spec.Action != "Audit" || len(caps.Spec.Process.MatchPaths) != 1 {
+		t.Errorf("unexpected file capabilities rule %+v", caps)
+	}
+
+	if rules := riskyFileRules(&image.Info{RiskyFiles: []image.FileAttr{{Path: "/tmp", Mode: 01777, Dir: true}}}); len(rules) != 0 {
+		t.Errorf("sticky directories are not risky, got %+v", rules)
+	}
+}
+
+func TestExpectedSetuid(t *testing.T) {
+	// setuid/setgid binaries of debian:bookworm and alpine
+	img := &image.Info{RiskyFiles: []image.FileAttr{
+		{Path: "/usr/bin/chfn", Mode: 04755},
+		{Path: "/usr/bin/chsh", Mode: 04755},
+		{Path: "/usr/bin/gpasswd", Mode: 04755},
+		{Path: "/usr/bin/mount", Mode: 04755},
+		{Path: "/usr/bin/newgrp", Mode: 04755},
+		{Path: "/usr/bin/passwd", Mode: 04755},
+		{Path: "/usr/bin/su", Mode: 04755},
+		{Path: "/usr/bin/umount", Mode: 04755},
+		{Path: "/usr/bin/chage", Mode: 02755, GID: 42},
+		{Path: "/usr/bin/expiry", Mode: 02755, GID: 42},
+		{Path: "/usr/sbin/unix_chkpwd", Mode: 02755, GID: 42},
+		{Path: "/bin/bbsuid", Mode: 04111},
+	}}
+	if rules := riskyFileRules(img); len(rules) != 0 {
+		t.Errorf("expected no rules for the setuid binaries of a stock base image, got %+v", rules)
+	}
+
+	img.RiskyFiles = append(img.RiskyFiles, image.FileAttr{Path: "/usr/bin/sudo", Mode: 04755})
+	rules := riskyFileRules(img)
+	if len(rules) != 1 || len(rules[0].Spec.Process.MatchPaths) != 1 || rules[0].Spec.Process.MatchPaths[0].Path != "/usr/bin/sudo" {
+		t.Errorf("expected an audit rule for sudo only, got %+v", rules)
+	}
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package genericpolicies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	log "github.com/sirupsen/logrus"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ruleFiles returns the yaml files holding user defined rules
+func ruleFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && (strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml")) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// splitRules returns the rules of a document holding a list of rules, a
+// policy-templates metadata.yaml style document or a single rule
+func splitRules(doc []byte) ([]json.RawMessage, error) {
+	data, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var rules []json.RawMessage
+	if data[0] == '[' {
+		err = json.Unmarshal(data, &rules)
+		return rules, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["kind"]; ok {
+		// policy referenced by a rule
+		return nil, nil
+	}
+	if list, ok := fields["policyRules"]; ok {
+		err = json.Unmarshal(list, &rules)
+		return rules, err
+	}
+	return []json.RawMessage{data}, nil
+}
+
+// decodeRule decodes a rule, rejecting unknown fields
+func decodeRule(data []byte) (common.MatchSpec, error) {
+	var ms common.MatchSpec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&ms)
+	return ms, err
+}
+
+// resolveSpec loads the spec of a rule referencing a policy yaml
+func resolveSpec(ms *common.MatchSpec, dir string) error {
+	if ms.Yaml == "" {
+		return nil
+	}
+	file := ms.Yaml
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+	var policy pol.KubeArmorPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+	if policy.Kind != "KubeArmorPolicy" {
+		return fmt.Errorf("%s is not a KubeArmorPolicy", ms.Yaml)
+	}
+	ms.Spec = policy.Spec
+	ms.Yaml = ""
+	return nil
+}
+
+func validateRule(ms common.MatchSpec) error {
+	var errs []string
+	if ms.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	for _, pre := range ms.Precondition {
+		re := pre
+		if _, val, ok := splitPrecondition(pre); ok {
+			re = val
+		}
+		if _, err := regexp.Compile(re); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid precondition %q: %s", pre, err.Error()))
+		}
+	}
+	spec := ms.Spec
+	if len(spec.Process.MatchPaths)+len(spec.Process.MatchDirectories)+len(spec.Process.MatchPatterns)+
+		len(spec.File.MatchPaths)+len(spec.File.MatchDirectories)+len(spec.File.MatchPatterns)+
+		len(spec.Network.MatchProtocols)+len(spec.Capabilities.MatchCapabilities)+len(spec.Syscalls.MatchSyscalls)+
+		len(spec.Syscalls.MatchPaths) == 0 {
+		errs = append(errs, "spec has no rules, use an inline spec or a yaml reference")
+	}
+	switch spec.Action {
+	case "", "Allow", "Audit", "Block":
+	default:
+		errs = append(errs, fmt.Sprintf("invalid action %q, use Allow, Audit or Block", spec.Action))
+	}
+	for _, kind := range ms.Kinds {
+		if kind != common.KindKubeArmorPolicy && kind != common.KindKubeArmorHostPolicy {
+			errs = append(errs, fmt.Sprintf("invalid kind %q, use %s or %s", kind, common.KindKubeArmorPolicy, common.KindKubeArmorHostPolicy))
+		}
+	}
+	if spec.Severity < 0 || spec.Severity > 10 {
+		errs = append(errs, fmt.Sprintf("invalid severity %d, use 1 to 10", spec.Severity))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// readUserRules reads and validates the user defined rules under path
+func readUserRules(path string) ([]common.MatchSpec, error) {
+	files, err := ruleFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []common.MatchSpec
+	var errs []string
+	seen := map[string]string{}
+	for _, file := range files {
+		f, err := os.Open(filepath.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+		for {
+			doc, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				_ = f.Close()
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			docRules, err := splitRules(doc)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", file, err.Error()))
+				continue
+			}
+			for _, data := range docRules {
+				ms, err := decodeRule(data)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s: rule %q: %s", file, ms.Name, err.Error()))
+					continue
+				}
+				if err := resolveSpec(&ms, filepath.Dir(file)); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: rule %q: %s", file, ms.Name, err.Error()))
+					continue
+				}
+				if err := validateRule(ms); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: rule %q: %s", file, ms.Name, err.Error()))
+					continue
+				}
+				if prev, ok := seen[ms.Name]; ok {
+					errs = append(errs, fmt.Sprintf("%s: rule %q already defined in %s", file, ms.Name, prev))
+					continue
+				}
+				seen[ms.Name] = file
+				ms.Source = "local:" + file
+				rules = append(rules, ms)
+			}
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid rules:\n%s", strings.Join(errs, "\n"))
+	}
+	return rules, nil
+}
+
+// mergeRules adds the user defined rules to policyRules, replacing the
+// upstream rules of the same name
+func mergeRules(rules []common.MatchSpec) {
+	idx := map[string]int{}
+	for i, ms := range policyRules {
+		idx[ms.Name] = i
+	}
+	for _, ms := range rules {
+		if i, ok := idx[ms.Name]; ok {
+			log.WithFields(log.Fields{
+				"rule":   ms.Name,
+				"source": ms.Source,
+			}).Info("overriding policy-templates rule")
+			policyRules[i] = ms
+			continue
+		}
+		idx[ms.Name] = len(policyRules)
+		policyRules = append(policyRules, ms)
+	}
+}
+
+// LoadRules loads the user defined rules from a file or directory and merges
+// them with the policy-templates rules
+func LoadRules(path string) error {
+	rules, err := readUserRules(path)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules found in %s", path)
+	}
+	mergeRules(rules)
+	log.WithFields(log.Fields{
+		"rules":  len(rules),
+		"source": path,
+	}).Info("loaded user defined rules")
+	return nil
+}
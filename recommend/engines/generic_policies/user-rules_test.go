@@ -0,0 +1,108 @@
+package genericpolicies
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+)
+
+const userRules = `policyRules:
+- name: pkg-mngr-exec
+  precondition:
+  - /usr/bin/apt
+  description:
+    tldr: Block package managers, in-house variant
+  spec:
+    severity: 8
+    action: Block
+    process:
+      matchPaths:
+      - path: /usr/bin/apt
+      - path: /usr/bin/apt-get
+---
+name: deny-vault-token
+precondition:
+- /vault/.*
+description:
+  tldr: Deny access to the vault token
+yaml: vault-token.yaml
+`
+
+const vaultPolicy = `apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: deny-vault-token
+spec:
+  severity: 9
+  action: Block
+  file:
+    matchPaths:
+    - path: /vault/token
+`
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"rules.yaml":       userRules,
+		"vault-token.yaml": vaultPolicy,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	policyRules = []common.MatchSpec{
+		{Name: "pkg-mngr-exec", Description: common.Description{Tldr: "upstream"}},
+		{Name: "cert-access"},
+	}
+
+	if err := LoadRules(dir); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(policyRules) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(policyRules))
+	}
+	source := "local:" + filepath.Join(dir, "rules.yaml")
+	if ms := policyRules[0]; ms.Name != "pkg-mngr-exec" || ms.Description.Tldr == "upstream" ||
+		ms.Spec.Severity != 8 || ms.Provenance() != source {
+		t.Errorf("upstream rule not overridden: %+v", ms)
+	}
+	if ms := policyRules[1]; ms.Provenance() != common.SourcePolicyTemplates {
+		t.Errorf("unexpected provenance %s", ms.Provenance())
+	}
+	if ms := policyRules[2]; ms.Name != "deny-vault-token" || ms.Spec.Severity != 9 ||
+		len(ms.Spec.File.MatchPaths) != 1 || ms.Yaml != "" {
+		t.Errorf("yaml reference not resolved: %+v", ms)
+	}
+}
+
+func TestLoadInvalidRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	invalid := `- name: no-spec
+- name: bad
+  precondition:
+  - /usr/bin/(
+  spec:
+    action: Deny
+    process:
+      matchPaths:
+      - path: /bin/sh
+- name: typo
+  precondtion:
+  - /bin/sh
+`
+	if err := os.WriteFile(file, []byte(invalid), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	err := LoadRules(file)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"precondtion", `"no-spec": spec has no rules`, "invalid precondition", `invalid action "Deny"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %s", want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+// Package telemetry generates least-privilege allow policies from recorded KubeArmor telemetry
+package telemetry
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	ds "github.com/accuknox/auto-policy-discovery/src/common"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Version reported as the template version for telemetry based policies
+const Version = "telemetry"
+
+// maximum number of sample events listed as justification for a single rule
+const maxSamples = 3
+
+// Telemetry policy generator consuming recorded KubeArmor logs
+type Telemetry struct {
+	// Events path to an ndjson file or a directory (store) of ndjson files
+	Events string
+
+	logs []*pb.Log
+}
+
+// New creates a telemetry engine reading events from the given path
+func New(events string) *Telemetry {
+	return &Telemetry{Events: events}
+}
+
+// Init loads the recorded events
+func (t *Telemetry) Init() error {
+	if t.Events == "" {
+		return errors.New("no telemetry events provided, use --events")
+	}
+	var files []string
+	info, err := os.Stat(t.Events)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = filepath.Walk(t.Events, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && (strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".ndjson")) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		files = append(files, t.Events)
+	}
+
+	t.logs = nil
+	for _, f := range files {
+		logs, err := readEvents(f)
+		if err != nil {
+			return err
+		}
+		t.logs = append(t.logs, logs...)
+	}
+	log.WithFields(log.Fields{
+		"events": len(t.logs),
+		"files":  len(files),
+	}).Info("loaded telemetry events")
+	return nil
+}
+
+// readEvents parses newline delimited KubeArmor logs, as written by `karmor logs --json`
+func readEvents(path string) ([]*pb.Log, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.WithError(err).Warnf("failed to close %s", path)
+		}
+	}()
+
+	var logs []*pb.Log
+	unmarshal := protojson.UnmarshalOptions{DiscardUnknown: true}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		l := &pb.Log{}
+		if err := unmarshal.Unmarshal([]byte(line), l); err != nil {
+			log.WithError(err).WithField("file", path).Debug("skipping malformed event")
+			continue
+		}
+		logs = append(logs, l)
+	}
+	return logs, scanner.Err()
+}
+
+// ImageIndependent telemetry based recommendation does not need the image to be pulled
+func (t *Telemetry) ImageIndependent() bool {
+	return true
+}
+
+// Scan generates allow policies for the workload from the events observed for it
+func (t *Telemetry) Scan(img *image.Info, options common.Options) (map[string][]byte, map[string]interface{}, error) {
+	policyMap := map[string][]byte{}
+	msMap := map[string]interface{}{}
+
+	if len(img.RepoTags) == 0 {
+		img.RepoTags = append(img.RepoTags, img.Name)
+	}
+	if err := report.Start(img, options, Version); err != nil {
+		log.WithError(err).Error("report start failed")
+		return nil, nil, err
+	}
+
+	var events []*pb.Log
+	for _, l := range t.logs {
+		if matchWorkload(img, l) && l.Result == "Passed" {
+			events = append(events, l)
+		}
+	}
+	if len(events) == 0 {
+		log.WithFields(log.Fields{
+			"image":      img.Name,
+			"deployment": img.Deployment,
+		}).Warn("no telemetry events observed for workload")
+		return policyMap, msMap, nil
+	}
+
+	for _, ms := range []common.MatchSpec{
+		processRule(events),
+		fileRule(events),
+		networkRule(events),
+	} {
+		if ms.Name == "" {
+			continue
+		}
+		policy, outFile := img.GetPolicy(ms, options)
+		policyMap[outFile] = policy
+		msMap[outFile] = ms
+	}
+	return policyMap, msMap, nil
+}
+
+func parseLabels(labels string) map[string]string {
+	m := map[string]string{}
+	for _, l := range strings.Split(labels, ",") {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		}
+	}
+	return m
+}
+
+// matchWorkload checks whether the event originates from the workload of the image
+func matchWorkload(img *image.Info, l *pb.Log) bool {
+	if l.ContainerName == "" {
+		// host events
+		return false
+	}
+	if img.Namespace != "" && img.Namespace != l.NamespaceName {
+		return false
+	}
+	if len(img.Labels) > 0 {
+		labels := parseLabels(l.Labels)
+		for k, v := range img.Labels {
+			if labels[k] != v {
+				return false
+			}
+		}
+	}
+	if img.Deployment == "" && len(img.Labels) == 0 {
+		return l.ContainerImage == img.Name || strings.HasPrefix(l.ContainerImage, img.Name+"@")
+	}
+	return true
+}
+
+func firstField(s string) string {
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// observation aggregates events justifying a single rule
+type observation struct {
+	sources map[string]bool
+	count   int
+	samples []string
+	// written set if any of the file events may have modified the file
+	written bool
+}
+
+type observations map[string]*observation
+
+func (o observations) add(key, source string, l *pb.Log) {
+	obs, ok := o[key]
+	if !ok {
+		obs = &observation{sources: map[string]bool{}}
+		o[key] = obs
+	}
+	if source != "" {
+		obs.sources[source] = true
+	}
+	obs.count++
+	if len(obs.samples) < maxSamples {
+		obs.samples = append(obs.samples, fmt.Sprintf("%s pod=%s pid=%d source=%s", l.UpdatedTime, l.PodName, l.PID, l.Source))
+	}
+}
+
+func (o observations) keys() []string {
+	var keys []string
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (obs *observation) fromSource() []pol.MatchSourceType {
+	var srcs []string
+	for s := range obs.sources {
+		srcs = append(srcs, s)
+	}
+	sort.Strings(srcs)
+	var from []pol.MatchSourceType
+	for _, s := range srcs {
+		from = append(from, pol.MatchSourceType{Path: pol.MatchPathType(s)})
+	}
+	return from
+}
+
+func justification(kind string, o observations) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Allow list generated from %d observed %s event(s).", total(o), kind)
+	for _, k := range o.keys() {
+		obs := o[k]
+		fmt.Fprintf(&sb, "\n%s: %d event(s), e.g. %s", k, obs.count, strings.Join(obs.samples, "; "))
+	}
+	return sb.String()
+}
+
+func total(o observations) int {
+	cnt := 0
+	for _, obs := range o {
+		cnt += obs.count
+	}
+	return cnt
+}
+
+// evidence lists the observed resources with their number of events
+func evidence(kind string, o observations) []common.Evidence {
+	e := common.Evidence{Precondition: fmt.Sprintf("observed %s events", kind)}
+	for _, k := range o.keys() {
+		e.Matches = append(e.Matches, fmt.Sprintf("%s (%d)", k, o[k].count))
+	}
+	return []common.Evidence{e}
+}
+
+func allowSpec(kind string, o observations) common.MatchSpec {
+	return common.MatchSpec{
+		Name:     fmt.Sprintf("allow-observed-%s", kind),
+		Evidence: evidence(kind, o),
+		Description: common.Description{
+			Tldr:     fmt.Sprintf("Allow only the %d %s resource(s) observed at runtime (%d events)", len(o), kind, total(o)),
+			Detailed: justification(kind, o),
+		},
+		Source: "telemetry",
+		Spec: pol.KubeArmorPolicySpec{
+			Severity: 1,
+			Action:   "Allow",
+			Message:  fmt.Sprintf("%s not observed during profiling", kind),
+			Tags:     []string{"least-privilege"},
+		},
+	}
+}
+
+func processRule(events []*pb.Log) common.MatchSpec {
+	o := observations{}
+	for _, l := range events {
+		if l.Operation != "Process" {
+			continue
+		}
+		if path := firstField(l.Resource); strings.HasPrefix(path, "/") {
+			o.add(path, firstField(l.Source), l)
+		}
+	}
+	if len(o) == 0 {
+		return common.MatchSpec{}
+	}
+	ms := allowSpec("process", o)
+	for _, k := range o.keys() {
+		ms.Spec.Process.MatchPaths = append(ms.Spec.Process.MatchPaths, pol.ProcessPathType{
+			Path:       pol.MatchPathType(k),
+			FromSource: o[k].fromSource(),
+		})
+	}
+	return ms
+}
+
+// readOnly checks whether the file event opened the file for reading only, from
+// event data such as "fd=3 flags=O_RDONLY|O_CLOEXEC". Events without open
+// flags, such as unlink, are not.
+func readOnly(data string) bool {
+	for _, field := range strings.Fields(data) {
+		if flags, ok := strings.CutPrefix(field, "flags="); ok {
+			return strings.Contains(flags, "O_RDONLY") &&
+				!strings.Contains(flags, "O_WRONLY") && !strings.Contains(flags, "O_RDWR")
+		}
+	}
+	return false
+}
+
+// fileRule allows the observed files, aggregated into directories. Files only
+// ever opened for reading are allowed read-only.
+func fileRule(events []*pb.Log) common.MatchSpec {
+	o := observations{}
+	for _, l := range events {
+		if l.Operation != "File" {
+			continue
+		}
+		if path := firstField(l.Resource); strings.HasPrefix(path, "/") {
+			o.add(path, firstField(l.Source), l)
+			if !readOnly(l.Data) {
+				o[path].written = true
+			}
+		}
+	}
+	if len(o) == 0 {
+		return common.MatchSpec{}
+	}
+	ms := allowSpec("file", o)
+
+	// aggregate the observed files into directories the same way the profiler does
+	for _, sp := range ds.AggregatePaths(o.keys()) {
+		merged := &observation{sources: map[string]bool{}}
+		for _, k := range o.keys() {
+			if k == sp.Path || (sp.IsDir && strings.HasPrefix(k, strings.TrimSuffix(sp.Path, "/")+"/")) {
+				for s := range o[k].sources {
+					merged.sources[s] = true
+				}
+				merged.written = merged.written || o[k].written
+			}
+		}
+		if sp.IsDir {
+			dir := sp.Path
+			if !strings.HasSuffix(dir, "/") {
+				dir += "/"
+			}
+			ms.Spec.File.MatchDirectories = append(ms.Spec.File.MatchDirectories, pol.FileDirectoryType{
+				Directory:  pol.MatchDirectoryType(dir),
+				Recursive:  true,
+				ReadOnly:   !merged.written,
+				FromSource: merged.fromSource(),
+			})
+		} else {
+			ms.Spec.File.MatchPaths = append(ms.Spec.File.MatchPaths, pol.FilePathType{
+				Path:       pol.MatchPathType(sp.Path),
+				ReadOnly:   !merged.written,
+				FromSource: merged.fromSource(),
+			})
+		}
+	}
+	sort.Slice(ms.Spec.File.MatchDirectories, func(i, j int) bool {
+		return ms.Spec.File.MatchDirectories[i].Directory < ms.Spec.File.MatchDirectories[j].Directory
+	})
+	sort.Slice(ms.Spec.File.MatchPaths, func(i, j int) bool {
+		return ms.Spec.File.MatchPaths[i].Path < ms.Spec.File.MatchPaths[j].Path
+	})
+	return ms
+}
+
+// protocolOf extracts the protocol from network event resources such as
+// "domain=AF_INET type=SOCK_STREAM protocol=6"
+func protocolOf(resource string) string {
+	for _, field := range strings.Fields(resource) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[0] != "protocol" {
+			continue
+		}
+		switch strings.ToLower(kv[1]) {
+		case "6", "tcp":
+			return "tcp"
+		case "17", "udp":
+			return "udp"
+		case "1", "icmp":
+			return "icmp"
+		case "58", "icmpv6":
+			return "icmpv6"
+		case "255", "raw":
+			return "raw"
+		}
+	}
+	return ""
+}
+
+func networkRule(events []*pb.Log) common.MatchSpec {
+	o := observations{}
+	for _, l := range events {
+		if l.Operation != "Network" {
+			continue
+		}
+		if proto := protocolOf(l.Resource); proto != "" {
+			o.add(proto, firstField(l.Source), l)
+		}
+	}
+	if len(o) == 0 {
+		return common.MatchSpec{}
+	}
+	ms := allowSpec("network", o)
+	for _, k := range o.keys() {
+		ms.Spec.Network.MatchProtocols = append(ms.Spec.Network.MatchProtocols, pol.MatchNetworkProtocolType{
+			Protocol:   pol.MatchNetworkProtocolStringType(k),
+			FromSource: o[k].fromSource(),
+		})
+	}
+	return ms
+}
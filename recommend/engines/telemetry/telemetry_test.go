@@ -0,0 +1,177 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+const events = `{"NamespaceName":"wordpress-mysql","PodName":"wordpress-1","Labels":"app=wordpress","ContainerName":"wordpress","Operation":"Process","Source":"/bin/bash","Resource":"/usr/bin/php -v","Result":"Passed"}
+{"NamespaceName":"wordpress-mysql","PodName":"wordpress-1","Labels":"app=wordpress","ContainerName":"wordpress","Operation":"Network","Source":"/usr/sbin/apache2","Resource":"domain=AF_INET type=SOCK_STREAM protocol=6","Result":"Passed"}
+{"NamespaceName":"wordpress-mysql","PodName":"wordpress-1","Labels":"app=wordpress","ContainerName":"wordpress","Operation":"Process","Source":"/bin/bash","Resource":"/usr/bin/apt","Result":"Permission denied"}
+{"NamespaceName":"wordpress-mysql","PodName":"mysql-1","Labels":"app=mysql","ContainerName":"mysql","Operation":"Process","Source":"/bin/sh","Resource":"/usr/sbin/mysqld","Result":"Passed"}
+not a json line
+`
+
+func TestTelemetryRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	if err := os.WriteFile(path, []byte(events), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	engine := New(path)
+	if err := engine.Init(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(engine.logs) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(engine.logs))
+	}
+
+	img := &image.Info{
+		Namespace:  "wordpress-mysql",
+		Deployment: "wordpress",
+		Labels:     map[string]string{"app": "wordpress"},
+	}
+	var matched []int
+	for i, l := range engine.logs {
+		if matchWorkload(img, l) && l.Result == "Passed" {
+			matched = append(matched, i)
+		}
+	}
+	if len(matched) != 2 {
+		t.Fatalf("expected 2 events for the workload, got %v", matched)
+	}
+
+	ms := processRule(engine.logs[:2])
+	if len(ms.Spec.Process.MatchPaths) != 1 || ms.Spec.Process.MatchPaths[0].Path != "/usr/bin/php" {
+		t.Errorf("unexpected process rules %+v", ms.Spec.Process.MatchPaths)
+	}
+	if src := ms.Spec.Process.MatchPaths[0].FromSource; len(src) != 1 || src[0].Path != "/bin/bash" {
+		t.Errorf("unexpected fromSource %+v", src)
+	}
+
+	ms = networkRule(engine.logs[:2])
+	if len(ms.Spec.Network.MatchProtocols) != 1 || ms.Spec.Network.MatchProtocols[0].Protocol != "tcp" {
+		t.Errorf("unexpected network rules %+v", ms.Spec.Network.MatchProtocols)
+	}
+}
+
+// fileRules formats the file rules as "<path> ro=<readOnly> from=<sources>"
+func fileRules(ms common.MatchSpec) []string {
+	var rules []string
+	for _, d := range ms.Spec.File.MatchDirectories {
+		var from []string
+		for _, src := range d.FromSource {
+			from = append(from, string(src.Path))
+		}
+		rules = append(rules, fmt.Sprintf("%s ro=%v from=%v", d.Directory, d.ReadOnly, from))
+	}
+	for _, p := range ms.Spec.File.MatchPaths {
+		var from []string
+		for _, src := range p.FromSource {
+			from = append(from, string(src.Path))
+		}
+		rules = append(rules, fmt.Sprintf("%s ro=%v from=%v", p.Path, p.ReadOnly, from))
+	}
+	return rules
+}
+
+func TestFileRule(t *testing.T) {
+	file := func(source, resource, data string) *pb.Log {
+		return &pb.Log{Operation: "File", Source: source, Resource: resource, Data: data, Result: "Passed"}
+	}
+	var python []*pb.Log
+	for _, f := range []string{"os.py", "re.py", "io.py", "abc.py", "json/__init__.py", "json/decoder.py"} {
+		python = append(python, file("/usr/bin/python3", "/usr/lib/python3.11/"+f, "fd=3 flags=O_RDONLY|O_CLOEXEC"))
+	}
+	python = append(python, file("/usr/bin/pip", "/usr/lib/python3.11/site.py", "fd=3 flags=O_RDONLY"))
+
+	tests := []struct {
+		name   string
+		events []*pb.Log
+		want   []string
+	}{
+		{
+			name:   "read-only file",
+			events: []*pb.Log{file("/usr/sbin/apache2", "/etc/hosts", "fd=3 flags=O_RDONLY|O_CLOEXEC")},
+			want:   []string{"/etc/hosts ro=true from=[/usr/sbin/apache2]"},
+		},
+		{
+			name: "file written once",
+			events: []*pb.Log{
+				file("/usr/sbin/apache2", "/var/log/app.log", "fd=3 flags=O_RDONLY"),
+				file("/usr/sbin/apache2", "/var/log/app.log", "fd=3 flags=O_WRONLY|O_CREAT|O_APPEND"),
+			},
+			want: []string{"/var/log/app.log ro=false from=[/usr/sbin/apache2]"},
+		},
+		{
+			name:   "file opened read-write",
+			events: []*pb.Log{file("/bin/sh", "/var/lib/app/app.lock", "fd=3 flags=O_RDWR")},
+			want:   []string{"/var/lib/app/app.lock ro=false from=[/bin/sh]"},
+		},
+		{
+			name:   "file event without open flags",
+			events: []*pb.Log{file("/bin/rm", "/var/lib/app/app.lock", "syscall=SYS_UNLINKAT")},
+			want:   []string{"/var/lib/app/app.lock ro=false from=[/bin/rm]"},
+		},
+		{
+			name:   "files collapsed into a read-only directory",
+			events: python,
+			want:   []string{"/usr/lib/python3.11/ ro=true from=[/usr/bin/pip /usr/bin/python3]"},
+		},
+		{
+			name:   "directory with a written file",
+			events: append(python[:len(python):len(python)], file("/usr/bin/pip", "/usr/lib/python3.11/cache.pyc", "fd=3 flags=O_WRONLY|O_CREAT")),
+			want:   []string{"/usr/lib/python3.11/ ro=false from=[/usr/bin/pip /usr/bin/python3]"},
+		},
+		{
+			name: "other operations and relative paths",
+			events: []*pb.Log{
+				{Operation: "Process", Source: "/bin/bash", Resource: "/usr/bin/php"},
+				file("/bin/bash", "relative/path", "fd=3 flags=O_RDONLY"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fileRules(fileRule(tt.events))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected file rules %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchWorkloadImage(t *testing.T) {
+	l := &pb.Log{
+		NamespaceName:  "default",
+		ContainerName:  "web",
+		ContainerImage: "nginx:1.25@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Labels:         "app=web",
+	}
+	tests := []struct {
+		name string
+		img  *image.Info
+		ev   *pb.Log
+		want bool
+	}{
+		{"image with digest", &image.Info{Name: "nginx:1.25"}, l, true},
+		{"image pinned", &image.Info{Name: l.ContainerImage}, l, true},
+		{"other tag", &image.Info{Name: "nginx:1.24"}, l, false},
+		{"tag prefix", &image.Info{Name: "nginx:1.2"}, l, false},
+		{"image in another namespace", &image.Info{Name: "nginx:1.25", Namespace: "prod"}, l, false},
+		{"workload ignores the image", &image.Info{Name: "nginx:1.24", Labels: map[string]string{"app": "web"}}, l, true},
+		{"host event", &image.Info{Name: "nginx:1.25"}, &pb.Log{ContainerImage: l.ContainerImage}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchWorkload(tt.img, tt.ev); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
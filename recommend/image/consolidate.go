@@ -0,0 +1,330 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/clarketm/json"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+)
+
+// RulesAnnotation lists the rules merged into a consolidated policy
+const RulesAnnotation = "karmor.kubearmor.io/rules"
+
+// ConsolidatedRule rule merged into a consolidated policy
+type ConsolidatedRule struct {
+	Name     string           `json:"name"`
+	Severity pol.SeverityType `json:"severity"`
+	Message  string           `json:"message,omitempty"`
+	Tags     []string         `json:"tags,omitempty"`
+	// Evidence what the preconditions of the rule matched in the image
+	Evidence []common.Evidence `json:"evidence,omitempty"`
+}
+
+type consolidatedPolicy struct {
+	file            string
+	templateVersion string
+	policy          pol.KubeArmorPolicy
+	rules           map[string]ConsolidatedRule
+}
+
+// Consolidated merges the rules recommended for a workload into one policy per action
+type Consolidated struct {
+	policies map[pol.ActionType]*consolidatedPolicy
+}
+
+// NewConsolidated creates an empty set of consolidated policies
+func NewConsolidated() *Consolidated {
+	return &Consolidated{policies: map[pol.ActionType]*consolidatedPolicy{}}
+}
+
+func (img *Info) consolidatedName(action pol.ActionType) string {
+	base := img.Deployment
+	if base == "" {
+		base = mkPathFromTag(img.RepoTags[0])
+	}
+	return fmt.Sprintf("%s-%s", base, strings.ToLower(string(action)))
+}
+
+// Add merges the policy generated for ms and returns the file of the consolidated policy
+func (c *Consolidated) Add(img *Info, policy pol.KubeArmorPolicy, ms common.MatchSpec, outDir string) string {
+	action := policy.Spec.Action
+	if action == "" {
+		action = "Block"
+	}
+	cp, ok := c.policies[action]
+	if !ok {
+		name := img.consolidatedName(action)
+		cp = &consolidatedPolicy{
+			file:  filepath.Join(img.GetPolicyDir(outDir), name+".yaml"),
+			rules: map[string]ConsolidatedRule{},
+		}
+		cp.policy.APIVersion = policy.APIVersion
+		cp.policy.Kind = policy.Kind
+		cp.policy.Name = name
+		cp.policy.Namespace = policy.Namespace
+		cp.policy.Spec.Selector = policy.Spec.Selector
+		cp.policy.Spec.Action = action
+		c.policies[action] = cp
+	}
+
+	spec := policy.Spec
+	cp.rules[ms.Name] = ConsolidatedRule{
+		Name:     ms.Name,
+		Severity: spec.Severity,
+		Message:  spec.Message,
+		Tags:     spec.Tags,
+		Evidence: ms.Evidence,
+	}
+	if ms.TemplateVersion != "" {
+		cp.templateVersion = ms.TemplateVersion
+	}
+	merged := &cp.policy.Spec
+	if spec.Severity > merged.Severity {
+		merged.Severity = spec.Severity
+	}
+	merged.Tags = sortedUnion(merged.Tags, spec.Tags)
+
+	// rules keep their own severity, message and tags, so that alerts stay specific
+	for _, p := range spec.Process.MatchPaths {
+		inherit(&p.Severity, &p.Message, &p.Tags, spec)
+		merged.Process.MatchPaths = append(merged.Process.MatchPaths, p)
+	}
+	for _, d := range spec.Process.MatchDirectories {
+		inherit(&d.Severity, &d.Message, &d.Tags, spec)
+		merged.Process.MatchDirectories = append(merged.Process.MatchDirectories, d)
+	}
+	for _, p := range spec.Process.MatchPatterns {
+		inherit(&p.Severity, &p.Message, &p.Tags, spec)
+		merged.Process.MatchPatterns = append(merged.Process.MatchPatterns, p)
+	}
+	for _, p := range spec.File.MatchPaths {
+		inherit(&p.Severity, &p.Message, &p.Tags, spec)
+		merged.File.MatchPaths = append(merged.File.MatchPaths, p)
+	}
+	for _, d := range spec.File.MatchDirectories {
+		inherit(&d.Severity, &d.Message, &d.Tags, spec)
+		merged.File.MatchDirectories = append(merged.File.MatchDirectories, d)
+	}
+	for _, p := range spec.File.MatchPatterns {
+		inherit(&p.Severity, &p.Message, &p.Tags, spec)
+		merged.File.MatchPatterns = append(merged.File.MatchPatterns, p)
+	}
+	for _, p := range spec.Network.MatchProtocols {
+		inherit(&p.Severity, &p.Message, &p.Tags, spec)
+		merged.Network.MatchProtocols = append(merged.Network.MatchProtocols, p)
+	}
+	for _, mc := range spec.Capabilities.MatchCapabilities {
+		inherit(&mc.Severity, &mc.Message, &mc.Tags, spec)
+		merged.Capabilities.MatchCapabilities = append(merged.Capabilities.MatchCapabilities, mc)
+	}
+	normalize(merged)
+	return cp.file
+}
+
+// Policies returns the consolidated policies by output file
+func (c *Consolidated) Policies() (map[string][]byte, error) {
+	out := map[string][]byte{}
+	for _, cp := range c.policies {
+		var rules []ConsolidatedRule
+		for _, r := range cp.rules {
+			rules = append(rules, r)
+		}
+		sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+		annotation, err := json.Marshal(rules)
+		if err != nil {
+			return nil, err
+		}
+		cp.policy.Annotations = map[string]string{RulesAnnotation: string(annotation)}
+		if cp.templateVersion != "" {
+			cp.policy.Annotations[TemplateVersionAnnotation] = cp.templateVersion
+		}
+
+		arr, err := json.Marshal(cp.policy)
+		if err != nil {
+			return nil, err
+		}
+		out[cp.file] = arr
+	}
+	return out, nil
+}
+
+func inherit(severity *pol.SeverityType, message *string, tags *[]string, spec pol.KubeArmorPolicySpec) {
+	if *severity == 0 {
+		*severity = spec.Severity
+	}
+	if *message == "" {
+		*message = spec.Message
+	}
+	if len(*tags) == 0 {
+		*tags = spec.Tags
+	}
+}
+
+func sortedUnion(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = true
+	}
+	var out []string
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sourcesKey(from []pol.MatchSourceType) string {
+	var srcs []string
+	for _, s := range from {
+		srcs = append(srcs, string(s.Path))
+	}
+	sort.Strings(srcs)
+	return strings.Join(srcs, ",")
+}
+
+// dirCovers checks whether a directory rule applies to the path
+func dirCovers(dir, path string, recursive bool) bool {
+	dir = strings.TrimSuffix(dir, "/") + "/"
+	if !strings.HasPrefix(path, dir) || path == dir {
+		return false
+	}
+	return recursive || !strings.Contains(strings.TrimSuffix(path[len(dir):], "/"), "/")
+}
+
+// dedupe keeps the first item for every key, preserving order
+func dedupe[T any](items []T, key func(T) string) []T {
+	seen := map[string]bool{}
+	var out []T
+	for _, item := range items {
+		k := key(item)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, item)
+	}
+	return out
+}
+
+// normalize sorts the rules and removes duplicates and rules covered by
+// directory rules with the same conditions
+func normalize(spec *pol.KubeArmorPolicySpec) {
+	fdirKey := func(d pol.FileDirectoryType) string {
+		return fmt.Sprintf("%t|%t|%s", d.ReadOnly, d.OwnerOnly, sourcesKey(d.FromSource))
+	}
+	fd := spec.File.MatchDirectories
+	sort.SliceStable(fd, func(i, j int) bool { return fd[i].Directory < fd[j].Directory })
+	fd = dedupe(fd, func(d pol.FileDirectoryType) string {
+		return fmt.Sprintf("%s|%t|%s", d.Directory, d.Recursive, fdirKey(d))
+	})
+	var fdirs []pol.FileDirectoryType
+	for _, d := range fd {
+		covered := false
+		for _, o := range fd {
+			if o.Recursive && fdirKey(o) == fdirKey(d) && dirCovers(string(o.Directory), string(d.Directory), true) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			fdirs = append(fdirs, d)
+		}
+	}
+	spec.File.MatchDirectories = fdirs
+
+	fp := spec.File.MatchPaths
+	sort.SliceStable(fp, func(i, j int) bool { return fp[i].Path < fp[j].Path })
+	fp = dedupe(fp, func(p pol.FilePathType) string {
+		return fmt.Sprintf("%s|%t|%t|%s", p.Path, p.ReadOnly, p.OwnerOnly, sourcesKey(p.FromSource))
+	})
+	var fpaths []pol.FilePathType
+	for _, p := range fp {
+		covered := false
+		key := fmt.Sprintf("%t|%t|%s", p.ReadOnly, p.OwnerOnly, sourcesKey(p.FromSource))
+		for _, d := range fdirs {
+			if fdirKey(d) == key && dirCovers(string(d.Directory), string(p.Path), d.Recursive) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			fpaths = append(fpaths, p)
+		}
+	}
+	spec.File.MatchPaths = fpaths
+
+	fpat := spec.File.MatchPatterns
+	sort.SliceStable(fpat, func(i, j int) bool { return fpat[i].Pattern < fpat[j].Pattern })
+	spec.File.MatchPatterns = dedupe(fpat, func(p pol.FilePatternType) string {
+		return fmt.Sprintf("%s|%t|%t", p.Pattern, p.ReadOnly, p.OwnerOnly)
+	})
+
+	pdirKey := func(d pol.ProcessDirectoryType) string {
+		return fmt.Sprintf("%t|%s", d.OwnerOnly, sourcesKey(d.FromSource))
+	}
+	pd := spec.Process.MatchDirectories
+	sort.SliceStable(pd, func(i, j int) bool { return pd[i].Directory < pd[j].Directory })
+	pd = dedupe(pd, func(d pol.ProcessDirectoryType) string {
+		return fmt.Sprintf("%s|%t|%s", d.Directory, d.Recursive, pdirKey(d))
+	})
+	var pdirs []pol.ProcessDirectoryType
+	for _, d := range pd {
+		covered := false
+		for _, o := range pd {
+			if o.Recursive && pdirKey(o) == pdirKey(d) && dirCovers(string(o.Directory), string(d.Directory), true) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			pdirs = append(pdirs, d)
+		}
+	}
+	spec.Process.MatchDirectories = pdirs
+
+	pp := spec.Process.MatchPaths
+	sort.SliceStable(pp, func(i, j int) bool { return pp[i].Path < pp[j].Path })
+	pp = dedupe(pp, func(p pol.ProcessPathType) string {
+		return fmt.Sprintf("%s|%t|%s", p.Path, p.OwnerOnly, sourcesKey(p.FromSource))
+	})
+	var ppaths []pol.ProcessPathType
+	for _, p := range pp {
+		covered := false
+		key := fmt.Sprintf("%t|%s", p.OwnerOnly, sourcesKey(p.FromSource))
+		for _, d := range pdirs {
+			if pdirKey(d) == key && dirCovers(string(d.Directory), string(p.Path), d.Recursive) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			ppaths = append(ppaths, p)
+		}
+	}
+	spec.Process.MatchPaths = ppaths
+
+	ppat := spec.Process.MatchPatterns
+	sort.SliceStable(ppat, func(i, j int) bool { return ppat[i].Pattern < ppat[j].Pattern })
+	spec.Process.MatchPatterns = dedupe(ppat, func(p pol.ProcessPatternType) string {
+		return fmt.Sprintf("%s|%t", p.Pattern, p.OwnerOnly)
+	})
+
+	np := spec.Network.MatchProtocols
+	sort.SliceStable(np, func(i, j int) bool { return np[i].Protocol < np[j].Protocol })
+	spec.Network.MatchProtocols = dedupe(np, func(p pol.MatchNetworkProtocolType) string {
+		return fmt.Sprintf("%s|%s", p.Protocol, sourcesKey(p.FromSource))
+	})
+
+	mc := spec.Capabilities.MatchCapabilities
+	sort.SliceStable(mc, func(i, j int) bool { return mc[i].Capability < mc[j].Capability })
+	spec.Capabilities.MatchCapabilities = dedupe(mc, func(c pol.MatchCapabilitiesType) string {
+		return fmt.Sprintf("%s|%s", c.Capability, sourcesKey(c.FromSource))
+	})
+}
@@ -0,0 +1,158 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+)
+
+func testRules() []common.MatchSpec {
+	return []common.MatchSpec{
+		{
+			Name: "write-under-bin-dir",
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 5,
+				Action:   "Block",
+				Message:  "system binaries modified",
+				Tags:     []string{"NIST"},
+				File: pol.FileType{MatchDirectories: []pol.FileDirectoryType{
+					{Directory: "/bin/", Recursive: true, ReadOnly: true},
+					{Directory: "/bin/sub/", ReadOnly: true},
+				}},
+			},
+		},
+		{
+			Name: "write-bin-ls",
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 7,
+				Action:   "Block",
+				Tags:     []string{"MITRE"},
+				File: pol.FileType{MatchPaths: []pol.FilePathType{
+					{Path: "/bin/ls", ReadOnly: true},
+					{Path: "/etc/passwd", ReadOnly: true},
+				}},
+			},
+		},
+		{
+			Name: "pkg-mngr-exec",
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 5,
+				Action:   "Block",
+				Process: pol.ProcessType{MatchPaths: []pol.ProcessPathType{
+					{Path: "/usr/bin/apt"},
+					{Path: "/usr/bin/apt"},
+				}},
+			},
+		},
+		{
+			Name: "audit-shell",
+			Spec: pol.KubeArmorPolicySpec{
+				Severity: 2,
+				Action:   "Audit",
+				Process:  pol.ProcessType{MatchPaths: []pol.ProcessPathType{{Path: "/bin/sh"}}},
+			},
+		},
+	}
+}
+
+func consolidate(t *testing.T, img *Info, rules []common.MatchSpec) map[string][]byte {
+	c := NewConsolidated()
+	for _, ms := range rules {
+		policy, err := img.createPolicy(ms, common.Options{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c.Add(img, policy, ms, "out")
+	}
+	policies, err := c.Policies()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return policies
+}
+
+func TestConsolidate(t *testing.T) {
+	img := &Info{
+		Namespace:  "wordpress-mysql",
+		Deployment: "wordpress",
+		Labels:     map[string]string{"app": "wordpress"},
+		RepoTags:   []string{"wordpress:4.8-apache"},
+	}
+	policies := consolidate(t, img, testRules())
+	if len(policies) != 2 {
+		t.Fatalf("expected one policy per action, got %d", len(policies))
+	}
+
+	block := string(policies["out/wordpress-mysql-wordpress/wordpress-block.yaml"])
+	if block == "" {
+		t.Fatalf("block policy not found in %v", policies)
+	}
+	for _, want := range []string{
+		`"name":"wordpress-block"`,
+		`"severity":7`,
+		`"tags":["MITRE","NIST"]`,
+		`{"path":"/etc/passwd","readOnly":true,"severity":7,"tags":["MITRE"]}`,
+		`{"dir":"/bin/","recursive":true,"readOnly":true,"severity":5,"tags":["NIST"],"message":"system binaries modified"}`,
+		`pkg-mngr-exec`,
+	} {
+		if !strings.Contains(block, want) {
+			t.Errorf("expected %s in %s", want, block)
+		}
+	}
+	for _, covered := range []string{`"/bin/ls"`, `"/bin/sub/"`, `audit-shell`} {
+		if strings.Contains(block, covered) {
+			t.Errorf("unexpected %s in %s", covered, block)
+		}
+	}
+	if strings.Count(block, `"/usr/bin/apt"`) != 1 {
+		t.Errorf("duplicate process rule not removed in %s", block)
+	}
+
+	// the result does not depend on the order of the rules
+	rules := testRules()
+	for i, j := 0, len(rules)-1; i < j; i, j = i+1, j-1 {
+		rules[i], rules[j] = rules[j], rules[i]
+	}
+	reversed := consolidate(t, img, rules)
+	for file, policy := range policies {
+		if !bytes.Equal(policy, reversed[file]) {
+			t.Errorf("non deterministic output for %s:\n%s\n%s", file, policy, reversed[file])
+		}
+	}
+}
+
+func TestEvidenceAnnotations(t *testing.T) {
+	img := &Info{RepoTags: []string{"nginx:1.25"}}
+	var files []string
+	for i := 0; i < maxAnnotatedMatches+2; i++ {
+		files = append(files, fmt.Sprintf("/usr/bin/apt-%d", i))
+	}
+	ms := testRules()[2]
+	ms.Evidence = []common.Evidence{{Precondition: "/usr/bin/apt.*", Matches: files}}
+	ms.TemplateVersion = "v0.2.5"
+
+	policy, err := img.createPolicy(ms, common.Options{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if policy.Annotations[TemplateVersionAnnotation] != "v0.2.5" {
+		t.Errorf("unexpected annotations %v", policy.Annotations)
+	}
+	if evidence := policy.Annotations[EvidenceAnnotation]; !strings.Contains(evidence, `"precondition":"/usr/bin/apt.*"`) || !strings.Contains(evidence, `"... (+2 more)"`) || strings.Contains(evidence, "apt-10") {
+		t.Errorf("unexpected evidence annotation %s", evidence)
+	}
+	if len(ms.Evidence[0].Matches) != maxAnnotatedMatches+2 {
+		t.Error("the evidence of the rule was modified")
+	}
+
+	policies := consolidate(t, img, []common.MatchSpec{ms})
+	for _, p := range policies {
+		if !strings.Contains(string(p), `\"evidence\":[{\"precondition\":\"/usr/bin/apt.*\"`) || !strings.Contains(string(p), TemplateVersionAnnotation) {
+			t.Errorf("evidence missing from consolidated policy %s", p)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// capabilityXattr holds the file capabilities of executables
+const capabilityXattr = "security.capability"
+
+// mode bits
+const (
+	modeSetuid        = 04000
+	modeSetgid        = 02000
+	modeSticky        = 01000
+	modeWorldWritable = 0002
+	modeExec          = 0111
+)
+
+// capability names by number, see capability.h
+var capNames = []string{
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "kill",
+	"setgid", "setuid", "setpcap", "linux_immutable", "net_bind_service",
+	"net_broadcast", "net_admin", "net_raw", "ipc_lock", "ipc_owner",
+	"sys_module", "sys_rawio", "sys_chroot", "sys_ptrace", "sys_pacct",
+	"sys_admin", "sys_boot", "sys_nice", "sys_resource", "sys_time",
+	"sys_tty_config", "mknod", "lease", "audit_write", "audit_control",
+	"setfcap", "mac_override", "mac_admin", "syslog", "wake_alarm",
+	"block_suspend", "audit_read", "perfmon", "bpf", "checkpoint_restore",
+}
+
+// FileAttr security relevant attributes of a file or directory in the image
+type FileAttr struct {
+	Path         string   `json:"path"`
+	Mode         int64    `json:"mode"`
+	UID          int      `json:"uid"`
+	GID          int      `json:"gid"`
+	Dir          bool     `json:"dir,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Setuid executable running with the privileges of its owner
+func (f FileAttr) Setuid() bool {
+	return !f.Dir && f.Mode&modeSetuid != 0 && f.Mode&modeExec != 0
+}
+
+// Setgid executable running with the privileges of its group
+func (f FileAttr) Setgid() bool {
+	return !f.Dir && f.Mode&modeSetgid != 0 && f.Mode&modeExec != 0
+}
+
+// WorldWritable file, or directory without the sticky bit, anyone can modify
+func (f FileAttr) WorldWritable() bool {
+	return f.Mode&modeWorldWritable != 0 && !(f.Dir && f.Mode&modeSticky != 0)
+}
+
+// Risks lists why the file is risky
+func (f FileAttr) Risks() []string {
+	var risks []string
+	if f.Setuid() {
+		risks = append(risks, "setuid")
+	}
+	if f.Setgid() {
+		risks = append(risks, "setgid")
+	}
+	if f.WorldWritable() {
+		risks = append(risks, "world-writable")
+	}
+	if len(f.Capabilities) > 0 {
+		risks = append(risks, "capabilities")
+	}
+	return risks
+}
+
+func (f FileAttr) String() string {
+	s := fmt.Sprintf("%s (%04o %d:%d %s", f.Path, f.Mode&07777, f.UID, f.GID, strings.Join(f.Risks(), ","))
+	if len(f.Capabilities) > 0 {
+		s += " " + strings.Join(f.Capabilities, ",")
+	}
+	return s + ")"
+}
+
+// decodeCapabilities decodes the permitted capabilities of a vfs_cap_data xattr
+func decodeCapabilities(data []byte) []string {
+	if len(data) < 12 {
+		return nil
+	}
+	le := binary.LittleEndian
+	permitted := uint64(le.Uint32(data[4:8]))
+	// revision 2 and 3 hold the upper 32 capabilities in a second set
+	if len(data) >= 20 {
+		permitted |= uint64(le.Uint32(data[12:16])) << 32
+	}
+	var caps []string
+	for i := 0; i < 64; i++ {
+		if permitted&(1<<i) == 0 {
+			continue
+		}
+		if i < len(capNames) {
+			caps = append(caps, "cap_"+capNames[i])
+		} else {
+			caps = append(caps, fmt.Sprintf("cap_%d", i))
+		}
+	}
+	return caps
+}
+
+// FileAttrOf returns the attributes of a layer entry if it is risky:
+// setuid/setgid executables, world-writable files and directories and
+// executables with file capabilities
+func FileAttrOf(hdr *tar.Header) (FileAttr, bool) {
+	if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+		return FileAttr{}, false
+	}
+	attr := FileAttr{
+		Mode: hdr.Mode & 07777,
+		UID:  hdr.Uid,
+		GID:  hdr.Gid,
+		Dir:  hdr.Typeflag == tar.TypeDir,
+	}
+	if xattr, ok := hdr.PAXRecords["SCHILY.xattr."+capabilityXattr]; ok {
+		attr.Capabilities = decodeCapabilities([]byte(xattr))
+	}
+	return attr, len(attr.Risks()) > 0
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+)
+
+// DefaultNodeSelector selects every linux node, used when no node selector is given
+var DefaultNodeSelector = LabelMap{"kubernetes.io/os": "linux"}
+
+func addHostPolicyRule(policy *pol.KubeArmorHostPolicy, r pol.KubeArmorPolicySpec) {
+	if len(r.File.MatchDirectories) != 0 || len(r.File.MatchPaths) != 0 {
+		policy.Spec.File = r.File
+	}
+	if len(r.Process.MatchDirectories) != 0 || len(r.Process.MatchPaths) != 0 {
+		policy.Spec.Process = r.Process
+	}
+	for _, p := range r.Network.MatchProtocols {
+		policy.Spec.Network.MatchProtocols = append(policy.Spec.Network.MatchProtocols, pol.MatchHostNetworkProtocolType{
+			Protocol:   p.Protocol,
+			FromSource: p.FromSource,
+			Severity:   p.Severity,
+			Tags:       p.Tags,
+			Message:    p.Message,
+			Action:     p.Action,
+		})
+	}
+}
+
+// createHostPolicy creates a cluster wide host policy selecting the nodes by
+// their labels, the node root filesystem being analyzed like an image
+func (img *Info) createHostPolicy(ms common.MatchSpec, options common.Options) (pol.KubeArmorHostPolicy, error) {
+	policy := pol.KubeArmorHostPolicy{
+		Spec: pol.KubeArmorHostPolicySpec{
+			Severity: 1, // by default
+			NodeSelector: pol.NodeSelectorType{
+				MatchLabels: map[string]string{}},
+		},
+	}
+	policy.APIVersion = "security.kubearmor.com/v1"
+	policy.Kind = common.KindKubeArmorHostPolicy
+
+	policy.ObjectMeta.Name = img.getPolicyName(ms.Name)
+	policy.ObjectMeta.Annotations = evidenceAnnotations(ms)
+
+	policy.Spec.Action = pol.ActionType(options.EffectiveAction(ms))
+	policy.Spec.Severity = ms.Spec.Severity
+	if ms.Spec.Message != "" {
+		policy.Spec.Message = ms.Spec.Message
+	}
+	if len(ms.Spec.Tags) > 0 {
+		policy.Spec.Tags = ms.Spec.Tags
+	}
+
+	if len(img.Labels) > 0 {
+		policy.Spec.NodeSelector.MatchLabels = img.Labels
+	} else {
+		policy.Spec.NodeSelector.MatchLabels = DefaultNodeSelector
+	}
+
+	addHostPolicyRule(&policy, ms.Spec)
+	return policy, nil
+}
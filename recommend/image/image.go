@@ -11,11 +11,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/clarketm/json"
 
 	"github.com/fatih/color"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
 	"github.com/kubearmor/kubearmor-client/hacks"
 	"github.com/kubearmor/kubearmor-client/recommend/common"
@@ -40,19 +42,39 @@ type Info struct {
 	Name       string
 	Namespace  string
 	Labels     LabelMap
+	Kind       string
 	Deployment string
 	Image      string
 
 	RepoTags []string
+	// Platform requested variant of a multi-platform image, e.g. linux/arm64
+	Platform string
 	Arch     string
 	Distro   string
 	OS       string
 	FileList []string
 	DirList  []string
+	Config   Config
+	Packages []Package
+	// RiskyFiles setuid/setgid, world-writable and capability-bearing files
+	RiskyFiles []FileAttr
+	// Ports container and Service ports of the workload running the image
+	Ports []Port
+	// Platforms variants merged into this analysis, with --all-platforms
+	Platforms []PlatformInfo
 
 	TempDir string
 }
 
+// Config runtime configuration of the image
+type Config struct {
+	Entrypoint   []string `json:"entrypoint,omitempty"`
+	Cmd          []string `json:"cmd,omitempty"`
+	User         string   `json:"user,omitempty"`
+	ExposedPorts []string `json:"exposedPorts,omitempty"`
+	Env          []string `json:"env,omitempty"`
+}
+
 // LabelMap is an alias for map[string]string
 type LabelMap = map[string]string
 
@@ -165,11 +187,16 @@ func (img *Info) readManifest(manifest string) {
 	}
 	img.Arch = cfgres["architecture"].(string)
 	img.OS = cfgres["os"].(string)
+	var cfg v1.ConfigFile
+	if err := json.Unmarshal(barr, &cfg); err != nil {
+		log.WithError(err).Warn("failed to read image runtime config")
+	}
+	img.setConfig(cfg.Config)
 
 	if man["RepoTags"] == nil {
 		// If the image name contains sha256 digest,
 		// then manifest["RepoTags"] will be `nil`.
-		img.RepoTags = append(img.RepoTags, shortenImageNameWithSha256(img.Name))
+		img.RepoTags = append(img.RepoTags, img.DefaultRepoTag())
 	} else {
 		for _, tag := range man["RepoTags"].([]interface{}) {
 			img.RepoTags = append(img.RepoTags, tag.(string))
@@ -177,6 +204,42 @@ func (img *Info) readManifest(manifest string) {
 	}
 }
 
+// DefaultRepoTag name used for the image when the image itself carries no repo tags
+func (img *Info) DefaultRepoTag() string {
+	if img.Image != "" && !strings.Contains(img.Image, "@sha256:") {
+		// image pinned to the running digest, keep naming by the workload's image
+		return img.Image
+	}
+	return shortenImageNameWithSha256(img.Name)
+}
+
+// ReadConfigFile fills the image information from the image config,
+// for images fetched without the docker daemon
+func (img *Info) ReadConfigFile(cfg *v1.ConfigFile, repoTags []string) {
+	img.Arch = cfg.Architecture
+	img.OS = cfg.OS
+	img.setConfig(cfg.Config)
+	if len(repoTags) == 0 {
+		repoTags = []string{img.DefaultRepoTag()}
+	}
+	img.RepoTags = append(img.RepoTags, repoTags...)
+
+	img.GetDistro()
+}
+
+func (img *Info) setConfig(cfg v1.Config) {
+	img.Config = Config{
+		Entrypoint: cfg.Entrypoint,
+		Cmd:        cfg.Cmd,
+		User:       cfg.User,
+		Env:        cfg.Env,
+	}
+	for port := range cfg.ExposedPorts {
+		img.Config.ExposedPorts = append(img.Config.ExposedPorts, port)
+	}
+	sort.Strings(img.Config.ExposedPorts)
+}
+
 // shortenImageNameWithSha256 truncates the sha256 digest in image name
 func shortenImageNameWithSha256(name string) string {
 	if strings.Contains(name, "@sha256:") {
@@ -265,7 +328,7 @@ func addPolicyRule(policy *pol.KubeArmorPolicy, r pol.KubeArmorPolicySpec) {
 	}
 }
 
-func (img *Info) createPolicy(ms common.MatchSpec) (pol.KubeArmorPolicy, error) {
+func (img *Info) createPolicy(ms common.MatchSpec, options common.Options) (pol.KubeArmorPolicy, error) {
 	policy := pol.KubeArmorPolicy{
 		Spec: pol.KubeArmorPolicySpec{
 			Severity: 1, // by default
@@ -277,12 +340,13 @@ func (img *Info) createPolicy(ms common.MatchSpec) (pol.KubeArmorPolicy, error)
 	policy.Kind = "KubeArmorPolicy"
 
 	policy.ObjectMeta.Name = img.getPolicyName(ms.Name)
+	policy.ObjectMeta.Annotations = evidenceAnnotations(ms)
 
 	if img.Namespace != "" {
 		policy.ObjectMeta.Namespace = img.Namespace
 	}
 
-	policy.Spec.Action = ms.Spec.Action
+	policy.Spec.Action = pol.ActionType(options.EffectiveAction(ms))
 	policy.Spec.Severity = ms.Spec.Severity
 	if ms.Spec.Message != "" {
 		policy.Spec.Message = ms.Spec.Message
@@ -302,21 +366,69 @@ func (img *Info) createPolicy(ms common.MatchSpec) (pol.KubeArmorPolicy, error)
 	return policy, nil
 }
 
+// Annotations explaining why a policy was recommended
+const (
+	EvidenceAnnotation        = "karmor.kubearmor.io/evidence"
+	TemplateVersionAnnotation = "karmor.kubearmor.io/template-version"
+)
+
+// maxAnnotatedMatches matches per precondition kept in the evidence
+// annotation, annotations are limited in size
+const maxAnnotatedMatches = 10
+
+// evidenceAnnotations records what the preconditions of the rule matched in
+// the image and the policy-templates release it comes from
+func evidenceAnnotations(ms common.MatchSpec) map[string]string {
+	annotations := map[string]string{}
+	if len(ms.Evidence) > 0 {
+		var evidence []common.Evidence
+		for _, e := range ms.Evidence {
+			if n := len(e.Matches); n > maxAnnotatedMatches {
+				e.Matches = append(e.Matches[:maxAnnotatedMatches:maxAnnotatedMatches], fmt.Sprintf("... (+%d more)", n-maxAnnotatedMatches))
+			}
+			evidence = append(evidence, e)
+		}
+		if data, err := json.Marshal(evidence); err == nil {
+			annotations[EvidenceAnnotation] = string(data)
+		}
+	}
+	if ms.TemplateVersion != "" {
+		annotations[TemplateVersionAnnotation] = ms.TemplateVersion
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
 // GetPolicy - creates policy and return back
 func (img *Info) GetPolicy(ms common.MatchSpec, options common.Options) ([]byte, string) {
-	policy, err := img.createPolicy(ms)
+	var arr []byte
+	var err error
+	if options.HostPolicy() {
+		var policy pol.KubeArmorHostPolicy
+		policy, err = img.createHostPolicy(ms, options)
+		arr, _ = json.Marshal(policy)
+	} else {
+		var policy pol.KubeArmorPolicy
+		policy, err = img.createPolicy(ms, options)
+		arr, _ = json.Marshal(policy)
+	}
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"image": img, "spec": ms,
 		}).Error("create policy failed, skipping")
 	}
 
-	arr, _ := json.Marshal(policy)
 	outFile := img.getPolicyFile(ms.Name, options.OutDir)
 	err = os.MkdirAll(filepath.Dir(outFile), 0750)
 	if err != nil {
 		log.WithError(err).Error("failed to create directory")
 	}
+	if options.Consolidate {
+		// rules are merged into per workload policies instead
+		return arr, outFile
+	}
 	_, err = os.Create(filepath.Clean(outFile))
 	if err != nil {
 		log.WithError(err).Error(fmt.Sprintf("create file %s failed", outFile))
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// port sources other than Services
+const (
+	PortSourceImage     = "image"
+	PortSourceContainer = "container"
+)
+
+// Port a port the workload listens on
+type Port struct {
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol"`
+	// Source where the port was found: image, container or service/<name>
+	Source string `json:"source,omitempty"`
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+}
+
+// ParsePort parses a port of the image config such as 80/tcp, the protocol defaults to tcp
+func ParsePort(s string) (Port, error) {
+	port, proto, _ := strings.Cut(s, "/")
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || n == 0 {
+		return Port{}, fmt.Errorf("invalid port %q", s)
+	}
+	if proto == "" {
+		proto = "tcp"
+	}
+	return Port{Port: int32(n), Protocol: strings.ToLower(proto)}, nil
+}
+
+// NetworkPorts returns the ports exposed by the image along with the container
+// and Service ports of the workload, one entry per port and protocol
+func (img *Info) NetworkPorts() []Port {
+	var ports []Port
+	for _, p := range img.Config.ExposedPorts {
+		port, err := ParsePort(p)
+		if err != nil {
+			continue
+		}
+		port.Source = PortSourceImage
+		ports = append(ports, port)
+	}
+	return MergePorts(append(ports, img.Ports...))
+}
+
+// MergePorts removes duplicate ports, joining their sources, and sorts them
+func MergePorts(ports []Port) []Port {
+	var merged []Port
+	idx := map[string]int{}
+	for _, p := range ports {
+		p.Protocol = strings.ToLower(p.Protocol)
+		if i, ok := idx[p.String()]; ok {
+			switch {
+			case merged[i].Source == "":
+				merged[i].Source = p.Source
+			case p.Source != "" && !containsSource(merged[i].Source, p.Source):
+				merged[i].Source += "," + p.Source
+			}
+			continue
+		}
+		idx[p.String()] = len(merged)
+		merged = append(merged, p)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Port != merged[j].Port {
+			return merged[i].Port < merged[j].Port
+		}
+		return merged[i].Protocol < merged[j].Protocol
+	})
+	return merged
+}
+
+func containsSource(sources, source string) bool {
+	for _, s := range strings.Split(sources, ",") {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Package manager names
+const (
+	ManagerDpkg = "dpkg"
+	ManagerApk  = "apk"
+	ManagerRpm  = "rpm"
+)
+
+// Package installed in the image
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Manager string `json:"manager"`
+}
+
+var rpmDatabases = []string{
+	"/var/lib/rpm/rpmdb.sqlite",
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"/var/lib/rpm/Packages.db",
+	"/usr/lib/sysimage/rpm/Packages.db",
+	"/var/lib/rpm/Packages",
+}
+
+// ReadPackages reads the dpkg, apk and rpm package databases of the rootfs
+// extracted in TempDir
+func (img *Info) ReadPackages() {
+	var pkgs []Package
+	for _, read := range []func(string) ([]Package, error){readDpkg, readApk, readRpm} {
+		p, err := read(img.TempDir)
+		if err != nil {
+			log.WithError(err).WithField("image", img.Name).Warn("failed to read package database")
+			continue
+		}
+		pkgs = append(pkgs, p...)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].Name != pkgs[j].Name {
+			return pkgs[i].Name < pkgs[j].Name
+		}
+		return pkgs[i].Manager < pkgs[j].Manager
+	})
+	img.Packages = pkgs
+}
+
+// parseStanzas splits "Key: value" records separated by empty lines
+func parseStanzas(data []byte, sep string) []map[string]string {
+	var stanzas []map[string]string
+	cur := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(cur) > 0 {
+				stanzas = append(stanzas, cur)
+				cur = map[string]string{}
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// continuation line
+			continue
+		}
+		if k, v, ok := strings.Cut(line, sep); ok {
+			cur[k] = strings.TrimSpace(v)
+		}
+	}
+	if len(cur) > 0 {
+		stanzas = append(stanzas, cur)
+	}
+	return stanzas
+}
+
+func readDpkg(rootfs string) ([]Package, error) {
+	files := []string{filepath.Join(rootfs, "var/lib/dpkg/status")}
+	// distroless images keep one status file per package
+	extra, err := filepath.Glob(filepath.Join(rootfs, "var/lib/dpkg/status.d/*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range extra {
+		if !strings.HasSuffix(f, ".md5sums") {
+			files = append(files, f)
+		}
+	}
+
+	var pkgs []Package
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Clean(f))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, s := range parseStanzas(data, ":") {
+			if s["Package"] == "" {
+				continue
+			}
+			if status, ok := s["Status"]; ok && !strings.HasSuffix(status, " installed") {
+				continue
+			}
+			pkgs = append(pkgs, Package{Name: s["Package"], Version: s["Version"], Manager: ManagerDpkg})
+		}
+	}
+	return pkgs, nil
+}
+
+func readApk(rootfs string) ([]Package, error) {
+	data, err := os.ReadFile(filepath.Join(rootfs, "lib/apk/db/installed"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pkgs []Package
+	for _, s := range parseStanzas(data, ":") {
+		if s["P"] != "" {
+			pkgs = append(pkgs, Package{Name: s["P"], Version: s["V"], Manager: ManagerApk})
+		}
+	}
+	return pkgs, nil
+}
+
+func readRpm(rootfs string) ([]Package, error) {
+	for _, db := range rpmDatabases {
+		file := filepath.Join(rootfs, db)
+		if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var blobs [][]byte
+		var err error
+		switch filepath.Base(db) {
+		case "rpmdb.sqlite":
+			blobs, err = readSqliteBlobs(file, "Packages", 1)
+		case "Packages.db":
+			blobs, err = readNdbBlobs(file)
+		default:
+			log.WithField("database", db).Debug("Berkeley DB rpm databases are not supported, skipping")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var pkgs []Package
+		for _, blob := range blobs {
+			pkg, err := parseRpmHeader(blob)
+			if err != nil {
+				log.WithError(err).Debug("skipping invalid rpm header")
+				continue
+			}
+			pkgs = append(pkgs, pkg)
+		}
+		return pkgs, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,132 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const dpkgStatus = `Package: openssl
+Status: install ok installed
+Version: 3.0.11-1~deb12u2
+Description: Secure Sockets Layer toolkit
+ continuation line: not a field
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+`
+
+const apkInstalled = `C:Q1abc=
+P:busybox
+V:1.36.1-r5
+
+P:ca-certificates-bundle
+V:20230506-r0
+`
+
+func writeFile(t *testing.T, file string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+// rpmHeader builds an rpm header blob with the name, version and release tags
+func rpmHeader(name, version, release string) []byte {
+	var data bytes.Buffer
+	var index bytes.Buffer
+	for i, s := range []string{name, version, release} {
+		for _, v := range []uint32{uint32(rpmTagName + i), rpmTypeString, uint32(data.Len()), 1} {
+			_ = binary.Write(&index, binary.BigEndian, v)
+		}
+		data.WriteString(s + "\x00")
+	}
+	var blob bytes.Buffer
+	_ = binary.Write(&blob, binary.BigEndian, []uint32{3, uint32(data.Len())})
+	blob.Write(index.Bytes())
+	blob.Write(data.Bytes())
+	return blob.Bytes()
+}
+
+// ndbDatabase builds a Packages.db with a single slot page
+func ndbDatabase(blobs ...[]byte) []byte {
+	le := binary.LittleEndian
+	db := make([]byte, ndbPageSize)
+	le.PutUint32(db[0:], ndbHeaderMagic)
+	le.PutUint32(db[12:], 1)
+	for i := ndbHeaderSlots; i < ndbPageSize/16; i++ {
+		le.PutUint32(db[i*16:], ndbSlotMagic)
+	}
+	for i, blob := range blobs {
+		slot := db[(ndbHeaderSlots+i)*16:]
+		le.PutUint32(slot[4:], uint32(i+1))
+		le.PutUint32(slot[8:], uint32(len(db)/ndbBlockSize))
+		hdr := make([]byte, 16)
+		le.PutUint32(hdr[0:], ndbBlobMagic)
+		le.PutUint32(hdr[4:], uint32(i+1))
+		le.PutUint32(hdr[12:], uint32(len(blob)))
+		db = append(db, hdr...)
+		db = append(db, blob...)
+		for len(db)%ndbBlockSize != 0 {
+			db = append(db, 0)
+		}
+	}
+	return db
+}
+
+func TestReadPackages(t *testing.T) {
+	rootfs := t.TempDir()
+	writeFile(t, filepath.Join(rootfs, "var/lib/dpkg/status"), []byte(dpkgStatus))
+	writeFile(t, filepath.Join(rootfs, "lib/apk/db/installed"), []byte(apkInstalled))
+	writeFile(t, filepath.Join(rootfs, "var/lib/rpm/Packages.db"),
+		ndbDatabase(rpmHeader("zypper", "1.14.64", "150400.3.32.1")))
+
+	img := &Info{Name: "test", TempDir: rootfs}
+	img.ReadPackages()
+	want := []Package{
+		{Name: "busybox", Version: "1.36.1-r5", Manager: ManagerApk},
+		{Name: "ca-certificates-bundle", Version: "20230506-r0", Manager: ManagerApk},
+		{Name: "openssl", Version: "3.0.11-1~deb12u2", Manager: ManagerDpkg},
+		{Name: "zypper", Version: "1.14.64-150400.3.32.1", Manager: ManagerRpm},
+	}
+	if len(img.Packages) != len(want) {
+		t.Fatalf("expected %v, got %v", want, img.Packages)
+	}
+	for i := range want {
+		if img.Packages[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], img.Packages[i])
+		}
+	}
+}
+
+func TestReadRpmSqlite(t *testing.T) {
+	rootfs := t.TempDir()
+	data, err := os.ReadFile("testdata/rpmdb.sqlite")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	writeFile(t, filepath.Join(rootfs, "var/lib/rpm/rpmdb.sqlite"), data)
+
+	pkgs, err := readRpm(rootfs)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// 60 small packages spread over several pages and a header spilling into overflow pages
+	if len(pkgs) != 62 {
+		t.Fatalf("expected 62 packages, got %d", len(pkgs))
+	}
+	if pkgs[0] != (Package{Name: "openssl", Version: "1:3.0.7-27.el9", Manager: ManagerRpm}) {
+		t.Errorf("unexpected package %v", pkgs[0])
+	}
+	if pkgs[1] != (Package{Name: "bash", Version: "5.1.8-6.el9", Manager: ManagerRpm}) {
+		t.Errorf("unexpected package %v", pkgs[1])
+	}
+	if pkgs[61].Name != "pkg59" {
+		t.Errorf("unexpected package %v", pkgs[61])
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxMissingShown number of missing files listed by PlatformInfo.String
+const maxMissingShown = 5
+
+// PlatformInfo analysis of one variant of a multi-platform image
+type PlatformInfo struct {
+	Platform string `json:"platform"`
+	Distro   string `json:"distro,omitempty"`
+	Files    int    `json:"files"`
+	// Missing files found in other variants but not in this one
+	Missing []string `json:"missing,omitempty"`
+}
+
+func (p PlatformInfo) String() string {
+	s := fmt.Sprintf("%s: %d files", p.Platform, p.Files)
+	if len(p.Missing) == 0 {
+		return s
+	}
+	shown := p.Missing
+	if len(shown) > maxMissingShown {
+		shown = shown[:maxMissingShown]
+	}
+	s += fmt.Sprintf(", %d missing (%s", len(p.Missing), strings.Join(shown, ", "))
+	if len(p.Missing) > len(shown) {
+		s += ", ..."
+	}
+	return s + ")"
+}
+
+// Diverges checks whether the file lists of the platform variants differ
+func (img *Info) Diverges() bool {
+	for _, p := range img.Platforms {
+		if len(p.Missing) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func union(lists ...[]string) []string {
+	seen := map[string]bool{}
+	var merged []string
+	for _, l := range lists {
+		for _, s := range l {
+			if !seen[s] {
+				seen[s] = true
+				merged = append(merged, s)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// MergePlatforms combines the analysis of the platform variants of an image,
+// so that the policies recommended from it hold on every platform. Files,
+// packages and risky files of all the variants are kept and the files
+// missing from each variant are recorded in Platforms.
+func MergePlatforms(variants []Info) Info {
+	if len(variants) == 0 {
+		return Info{}
+	}
+	merged := variants[0]
+	merged.Platform = ""
+	merged.Platforms = nil
+
+	var files, dirs, archs, ports []string
+	present := make([]map[string]bool, len(variants))
+	pkgs := map[Package]bool{}
+	risky := map[string]FileAttr{}
+	merged.Packages = nil
+	for i, v := range variants {
+		files = union(files, v.FileList)
+		dirs = union(dirs, v.DirList)
+		archs = append(archs, v.Arch)
+		ports = union(ports, v.Config.ExposedPorts)
+		present[i] = map[string]bool{}
+		for _, f := range v.FileList {
+			present[i][f] = true
+		}
+		for _, p := range v.Packages {
+			if !pkgs[p] {
+				pkgs[p] = true
+				merged.Packages = append(merged.Packages, p)
+			}
+		}
+		for _, f := range v.RiskyFiles {
+			risky[f.Path] = f
+		}
+	}
+	merged.FileList = files
+	merged.DirList = dirs
+	merged.Arch = strings.Join(union(archs), ",")
+	merged.Config.ExposedPorts = ports
+	merged.RiskyFiles = nil
+	for _, f := range risky {
+		merged.RiskyFiles = append(merged.RiskyFiles, f)
+	}
+	sort.Slice(merged.RiskyFiles, func(i, j int) bool { return merged.RiskyFiles[i].Path < merged.RiskyFiles[j].Path })
+
+	for i, v := range variants {
+		p := PlatformInfo{Platform: v.Platform, Distro: v.Distro, Files: len(v.FileList)}
+		for _, f := range files {
+			if !present[i][f] {
+				p.Missing = append(p.Missing, f)
+			}
+		}
+		merged.Platforms = append(merged.Platforms, p)
+	}
+	return merged
+}
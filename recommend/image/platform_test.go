@@ -0,0 +1,46 @@
+package image
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergePlatforms(t *testing.T) {
+	amd64 := Info{
+		Platform: "linux/amd64",
+		Arch:     "amd64",
+		FileList: []string{"/bin/sh", "/lib/ld-linux-x86-64.so.2"},
+		Packages: []Package{{Name: "musl", Version: "1.2.4", Manager: ManagerApk}},
+	}
+	arm64 := Info{
+		Platform:   "linux/arm64",
+		Arch:       "arm64",
+		FileList:   []string{"/bin/sh", "/lib/ld-linux-aarch64.so.1", "/usr/bin/qemu"},
+		Packages:   []Package{{Name: "musl", Version: "1.2.4", Manager: ManagerApk}},
+		RiskyFiles: []FileAttr{{Path: "/bin/su", Mode: 04755}},
+	}
+
+	merged := MergePlatforms([]Info{amd64, arm64})
+	if merged.Arch != "amd64,arm64" || merged.Platform != "" {
+		t.Errorf("unexpected arch %s", merged.Arch)
+	}
+	if got := strings.Join(merged.FileList, ","); got != "/bin/sh,/lib/ld-linux-aarch64.so.1,/lib/ld-linux-x86-64.so.2,/usr/bin/qemu" {
+		t.Errorf("unexpected files %s", got)
+	}
+	if len(merged.Packages) != 1 || len(merged.RiskyFiles) != 1 {
+		t.Errorf("unexpected packages %v or risky files %v", merged.Packages, merged.RiskyFiles)
+	}
+	if !merged.Diverges() || len(merged.Platforms) != 2 {
+		t.Fatalf("expected diverging platforms, got %+v", merged.Platforms)
+	}
+	if got := merged.Platforms[0].String(); got != "linux/amd64: 2 files, 2 missing (/lib/ld-linux-aarch64.so.1, /usr/bin/qemu)" {
+		t.Errorf("unexpected platform summary %s", got)
+	}
+	if got := merged.Platforms[1].Missing; len(got) != 1 || got[0] != "/lib/ld-linux-x86-64.so.2" {
+		t.Errorf("unexpected missing files %v", got)
+	}
+
+	if same := MergePlatforms([]Info{amd64, amd64}); same.Diverges() {
+		t.Errorf("identical variants should not diverge, got %+v", same.Platforms)
+	}
+}
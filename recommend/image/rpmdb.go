@@ -0,0 +1,352 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rpm header tags
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+
+	rpmTypeInt32  = 4
+	rpmTypeString = 6
+)
+
+// parseRpmHeader reads name, epoch, version and release from an rpm header blob
+// as stored in the rpm database
+func parseRpmHeader(blob []byte) (Package, error) {
+	if len(blob) < 8 {
+		return Package{}, errors.New("rpm header too short")
+	}
+	il := binary.BigEndian.Uint32(blob[0:4])
+	dl := binary.BigEndian.Uint32(blob[4:8])
+	start := 8 + 16*uint64(il)
+	if il > 0xffff || start+uint64(dl) > uint64(len(blob)) {
+		return Package{}, errors.New("invalid rpm header size")
+	}
+	data := blob[start : start+uint64(dl)]
+
+	str := func(off uint32) string {
+		if uint64(off) >= uint64(len(data)) {
+			return ""
+		}
+		s := data[off:]
+		if i := bytes.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+		return string(s)
+	}
+	var name, version, release string
+	epoch := -1
+	for i := uint64(0); i < uint64(il); i++ {
+		e := blob[8+16*i : 8+16*(i+1)]
+		tag := binary.BigEndian.Uint32(e[0:4])
+		typ := binary.BigEndian.Uint32(e[4:8])
+		off := binary.BigEndian.Uint32(e[8:12])
+		switch {
+		case tag == rpmTagName && typ == rpmTypeString:
+			name = str(off)
+		case tag == rpmTagVersion && typ == rpmTypeString:
+			version = str(off)
+		case tag == rpmTagRelease && typ == rpmTypeString:
+			release = str(off)
+		case tag == rpmTagEpoch && typ == rpmTypeInt32 && uint64(off)+4 <= uint64(len(data)):
+			epoch = int(binary.BigEndian.Uint32(data[off : off+4]))
+		}
+	}
+	if name == "" {
+		return Package{}, errors.New("rpm header without name")
+	}
+	if release != "" {
+		version += "-" + release
+	}
+	if epoch > 0 {
+		version = fmt.Sprintf("%d:%s", epoch, version)
+	}
+	return Package{Name: name, Version: version, Manager: ManagerRpm}, nil
+}
+
+// ndb, the rpm database format used by SUSE
+const (
+	ndbHeaderMagic = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	ndbSlotMagic   = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	ndbBlobMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+	ndbPageSize    = 4096
+	ndbBlockSize   = 16
+	// the header uses the first two slots
+	ndbHeaderSlots = 2
+)
+
+// readNdbBlobs returns the package header blobs of an ndb Packages.db
+func readNdbBlobs(file string) ([][]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	if len(data) < 32 || le.Uint32(data[0:4]) != ndbHeaderMagic {
+		return nil, errors.New("not an ndb rpm database")
+	}
+	slotPages := uint64(le.Uint32(data[12:16]))
+	if slotPages*ndbPageSize > uint64(len(data)) {
+		return nil, errors.New("invalid ndb slot pages")
+	}
+	var blobs [][]byte
+	for i := uint64(ndbHeaderSlots); i < slotPages*ndbPageSize/16; i++ {
+		slot := data[i*16 : (i+1)*16]
+		if le.Uint32(slot[0:4]) != ndbSlotMagic {
+			return nil, errors.New("invalid ndb slot")
+		}
+		pkgIdx := le.Uint32(slot[4:8])
+		if pkgIdx == 0 {
+			continue
+		}
+		off := uint64(le.Uint32(slot[8:12])) * ndbBlockSize
+		if off+16 > uint64(len(data)) {
+			return nil, errors.New("invalid ndb blob offset")
+		}
+		hdr := data[off : off+16]
+		if le.Uint32(hdr[0:4]) != ndbBlobMagic || le.Uint32(hdr[4:8]) != pkgIdx {
+			return nil, errors.New("invalid ndb blob header")
+		}
+		blobLen := uint64(le.Uint32(hdr[12:16]))
+		if off+16+blobLen > uint64(len(data)) {
+			return nil, errors.New("invalid ndb blob length")
+		}
+		blobs = append(blobs, data[off+16:off+16+blobLen])
+	}
+	return blobs, nil
+}
+
+// sqliteDB minimal reader of sqlite table b-trees, enough to read the rpm database
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	off := (int(n) - 1) * db.pageSize
+	if n == 0 || off+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("invalid sqlite page %d", n)
+	}
+	return db.data[off : off+db.pageSize], nil
+}
+
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// payload returns the full record of a table leaf cell, following overflow pages
+func (db *sqliteDB) payload(cell []byte) ([]byte, error) {
+	size, n := sqliteVarint(cell)
+	_, m := sqliteVarint(cell[n:])
+	cell = cell[n+m:]
+
+	u := uint64(db.usable)
+	maxLocal := u - 35
+	if size <= maxLocal {
+		if size > uint64(len(cell)) {
+			return nil, errors.New("invalid sqlite cell")
+		}
+		return cell[:size], nil
+	}
+	minLocal := (u-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(u-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	if local+4 > uint64(len(cell)) {
+		return nil, errors.New("invalid sqlite cell")
+	}
+	out := make([]byte, 0, size)
+	out = append(out, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local : local+4])
+	for uint64(len(out)) < size {
+		p, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := p[4:db.usable]
+		if rest := size - uint64(len(out)); rest < uint64(len(chunk)) {
+			chunk = chunk[:rest]
+		}
+		out = append(out, chunk...)
+		next = binary.BigEndian.Uint32(p[0:4])
+	}
+	return out, nil
+}
+
+// walk calls fn with the record of every row of the table b-tree rooted at root
+func (db *sqliteDB) walk(root uint32, depth int, fn func([]byte) error) error {
+	if depth > 32 {
+		return errors.New("sqlite b-tree too deep")
+	}
+	p, err := db.page(root)
+	if err != nil {
+		return err
+	}
+	hdr := 0
+	if root == 1 {
+		hdr = 100
+	}
+	typ := p[hdr]
+	ncells := int(binary.BigEndian.Uint16(p[hdr+3 : hdr+5]))
+	ptrs := hdr + 8
+	if typ == 0x05 {
+		ptrs = hdr + 12
+	} else if typ != 0x0d {
+		return fmt.Errorf("unexpected sqlite page type %d", typ)
+	}
+	for i := 0; i < ncells; i++ {
+		off := int(binary.BigEndian.Uint16(p[ptrs+2*i : ptrs+2*i+2]))
+		if off >= len(p) {
+			return errors.New("invalid sqlite cell pointer")
+		}
+		if typ == 0x05 {
+			if err := db.walk(binary.BigEndian.Uint32(p[off:off+4]), depth+1, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		record, err := db.payload(p[off:])
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if typ == 0x05 {
+		return db.walk(binary.BigEndian.Uint32(p[hdr+8:hdr+12]), depth+1, fn)
+	}
+	return nil
+}
+
+// sqliteColumns decodes the text, blob and integer columns of a record
+func sqliteColumns(record []byte) ([]interface{}, error) {
+	hdrLen, n := sqliteVarint(record)
+	if hdrLen > uint64(len(record)) {
+		return nil, errors.New("invalid sqlite record")
+	}
+	var cols []interface{}
+	body := record[hdrLen:]
+	for pos := uint64(n); pos < hdrLen; {
+		st, m := sqliteVarint(record[pos:])
+		pos += uint64(m)
+		var size uint64
+		switch {
+		case st == 0, st == 8, st == 9:
+			size = 0
+		case st <= 4:
+			size = st
+		case st == 5:
+			size = 6
+		case st == 6, st == 7:
+			size = 8
+		case st >= 12:
+			size = (st - 12) / 2
+		default:
+			return nil, errors.New("invalid sqlite serial type")
+		}
+		if size > uint64(len(body)) {
+			return nil, errors.New("invalid sqlite record body")
+		}
+		v := body[:size]
+		body = body[size:]
+		switch {
+		case st == 0:
+			cols = append(cols, nil)
+		case st == 8, st == 9:
+			cols = append(cols, int64(st-8))
+		case st >= 1 && st <= 6:
+			var i int64
+			for _, b := range v {
+				i = i<<8 | int64(b)
+			}
+			// sign extend
+			shift := 64 - 8*len(v)
+			cols = append(cols, i<<shift>>shift)
+		case st >= 12 && st%2 == 0:
+			cols = append(cols, v)
+		case st >= 13:
+			cols = append(cols, string(v))
+		default:
+			cols = append(cols, nil)
+		}
+	}
+	return cols, nil
+}
+
+// readSqliteBlobs returns the blob column col of all the rows of table
+func readSqliteBlobs(file, table string, col int) ([][]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 100 || string(data[:16]) != "SQLite format 3\x00" {
+		return nil, errors.New("not an sqlite database")
+	}
+	db := &sqliteDB{data: data, pageSize: int(binary.BigEndian.Uint16(data[16:18]))}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	db.usable = db.pageSize - int(data[20])
+
+	// sqlite_master: type, name, tbl_name, rootpage, sql
+	var root uint32
+	err = db.walk(1, 0, func(record []byte) error {
+		cols, err := sqliteColumns(record)
+		if err != nil {
+			return err
+		}
+		if len(cols) >= 4 && cols[0] == "table" && cols[1] == table {
+			if r, ok := cols[3].(int64); ok {
+				root = uint32(r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+
+	var blobs [][]byte
+	err = db.walk(root, 0, func(record []byte) error {
+		cols, err := sqliteColumns(record)
+		if err != nil {
+			return err
+		}
+		if len(cols) > col {
+			if b, ok := cols[col].([]byte); ok {
+				blobs = append(blobs, b)
+			}
+		}
+		return nil
+	})
+	return blobs, err
+}
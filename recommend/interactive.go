@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/report"
+	"github.com/kubearmor/kubearmor-client/recommend/review"
+	log "github.com/sirupsen/logrus"
+)
+
+// policies proposed for the interactive review
+var proposals []review.Proposal
+
+// propose adds the generated policy to the interactive review
+func propose(outFile string, policy []byte, in interface{}) {
+	if !options.Interactive {
+		return
+	}
+	ms, _ := in.(common.MatchSpec)
+	// policies are written to a directory per workload or image
+	workload := filepath.Base(filepath.Dir(outFile))
+	p, err := review.NewProposal(workload, outFile, ms.Name, ms.Description.Tldr, policy)
+	if err != nil {
+		log.WithError(err).WithField("policy", outFile).Error("failed to read policy for review")
+		return
+	}
+	proposals = append(proposals, p)
+}
+
+// reviewPolicies lets the generated policies be accepted, rejected or edited,
+// keeping only the accepted ones for --diff and --apply
+func reviewPolicies() error {
+	if len(proposals) == 0 {
+		return replayReview(nil)
+	}
+	decisionsFile := filepath.Join(options.OutDir, review.DecisionsFile)
+	previous, err := review.LoadDecisions(decisionsFile)
+	if err != nil {
+		log.WithError(err).WithField("file", decisionsFile).Warn("failed to read the previous review decisions")
+	}
+	review.Restore(proposals, previous)
+
+	reviewed, write, err := review.Run(proposals)
+	if err != nil {
+		return err
+	}
+	if !write {
+		color.Yellow("review quit, all the recommended policies are kept")
+		return replayReview(nil)
+	}
+	kept, err := review.Write(reviewed, decisionsFile)
+	if err != nil {
+		return err
+	}
+	generated = kept
+	color.Green("kept %d of %d policies, decisions recorded in %s", len(kept), len(reviewed), decisionsFile)
+	return replayReview(reviewed)
+}
+
+// replayReview records the report and the coverage of the reviewed policies,
+// with their edited action and severity. Without a review all the policies are
+// recorded as recommended.
+func replayReview(reviewed []review.Proposal) error {
+	byFile := map[string]review.Proposal{}
+	for _, p := range reviewed {
+		byFile[p.File] = p
+	}
+	recordReviewedCoverage()
+	return report.Replay(func(policyName string, ms *common.MatchSpec) bool {
+		p, ok := byFile[policyName]
+		if !ok {
+			return true
+		}
+		if !p.Accepted {
+			return false
+		}
+		ms.Spec.Action = pol.ActionType(p.Action)
+		ms.Spec.Severity = pol.SeverityType(p.Severity)
+		return true
+	})
+}
+
+// validateInteractive checks the options the review can be combined with
+func validateInteractive(o common.Options) error {
+	if o.Interactive && o.Consolidate {
+		return errors.New("--interactive reviews the policy of every rule, it cannot be combined with --consolidate")
+	}
+	return nil
+}
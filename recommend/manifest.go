@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+func isKustomization(dir string) bool {
+	for _, f := range kustomizationFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeBuild renders a kustomize directory, same as `kustomize build <dir>`
+func kustomizeBuild(dir string) ([]byte, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, err
+	}
+	return resMap.AsYaml()
+}
+
+// readManifests reads k8s yaml from a file, a directory, a kustomize directory or stdin ("-")
+func readManifests(path string) ([][]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
+	}
+
+	if isKustomization(path) {
+		data, err := kustomizeBuild(path)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
+	}
+
+	var manifests [][]byte
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(p) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, data)
+		return nil
+	})
+	return manifests, err
+}
+
+// splitDocuments splits multi-document yaml into individual documents
+func splitDocuments(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// objectToWorkload converts typed k8s objects to workloads, expanding lists
+func objectToWorkload(obj runtime.Object) []workload {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return []workload{{"Deployment", o.Name, o.Namespace, o.Spec.Selector, o.Spec.Template.Spec, o.Spec.Template.Labels}}
+	case *appsv1.StatefulSet:
+		return []workload{{"StatefulSet", o.Name, o.Namespace, o.Spec.Selector, o.Spec.Template.Spec, o.Spec.Template.Labels}}
+	case *appsv1.DaemonSet:
+		return []workload{{"DaemonSet", o.Name, o.Namespace, o.Spec.Selector, o.Spec.Template.Spec, o.Spec.Template.Labels}}
+	case *appsv1.ReplicaSet:
+		return []workload{{"ReplicaSet", o.Name, o.Namespace, o.Spec.Selector, o.Spec.Template.Spec, o.Spec.Template.Labels}}
+	case *batchv1.Job:
+		return []workload{{"Job", o.Name, o.Namespace, o.Spec.Selector, o.Spec.Template.Spec, o.Spec.Template.Labels}}
+	case *batchv1.CronJob:
+		tmpl := o.Spec.JobTemplate.Spec.Template
+		return []workload{{"CronJob", o.Name, o.Namespace, nil, tmpl.Spec, tmpl.Labels}}
+	case *corev1.Pod:
+		return []workload{{"Pod", o.Name, o.Namespace, nil, o.Spec, o.Labels}}
+	case *corev1.List:
+		var wls []workload
+		for _, item := range o.Items {
+			itemObj, _, err := scheme.Codecs.UniversalDeserializer().Decode(item.Raw, nil, nil)
+			if err != nil {
+				continue
+			}
+			wls = append(wls, objectToWorkload(itemObj)...)
+		}
+		return wls
+	}
+	return nil
+}
+
+// objectToServices returns the Services of typed k8s objects, expanding lists
+func objectToServices(obj runtime.Object) []corev1.Service {
+	switch o := obj.(type) {
+	case *corev1.Service:
+		return []corev1.Service{*o}
+	case *corev1.ServiceList:
+		return o.Items
+	case *corev1.List:
+		var svcs []corev1.Service
+		for _, item := range o.Items {
+			itemObj, _, err := scheme.Codecs.UniversalDeserializer().Decode(item.Raw, nil, nil)
+			if err != nil {
+				continue
+			}
+			svcs = append(svcs, objectToServices(itemObj)...)
+		}
+		return svcs
+	}
+	return nil
+}
+
+// parseWorkloads extracts workloads and the Services selecting them from k8s manifests
+func parseWorkloads(data []byte) ([]workload, []corev1.Service, error) {
+	docs, err := splitDocuments(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var wls []workload
+	var svcs []corev1.Service
+	for _, doc := range docs {
+		obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+		if err != nil {
+			// CRDs and other kinds unknown to the scheme are not workloads
+			log.WithError(err).Debug("skipping manifest document")
+			continue
+		}
+		found := objectToWorkload(obj)
+		services := objectToServices(obj)
+		if len(found) == 0 && len(services) == 0 && gvk != nil {
+			log.WithField("kind", gvk.Kind).Debug("skipping non-workload manifest")
+		}
+		wls = append(wls, found...)
+		svcs = append(svcs, services...)
+	}
+	return wls, svcs, nil
+}
+
+// manifestNamespace returns the namespace of a manifest object, which
+// defaults to the requested namespace
+func manifestNamespace(ns, namespace string) string {
+	if ns != "" {
+		return ns
+	}
+	if namespace != "" {
+		return namespace
+	}
+	return v1.NamespaceDefault
+}
+
+// getWorkloadsFromManifests collects workloads from local manifests, the same way
+// getWorkloads does for a live cluster
+func getWorkloadsFromManifests(path, namespace string, labelMap LabelMap) ([]Deployment, error) {
+	manifests, err := readManifests(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wls []workload
+	var services []corev1.Service
+	for _, data := range manifests {
+		found, svcs, err := parseWorkloads(data)
+		if err != nil {
+			return nil, err
+		}
+		wls = append(wls, found...)
+		services = append(services, svcs...)
+	}
+	for i := range services {
+		services[i].Namespace = manifestNamespace(services[i].Namespace, namespace)
+	}
+
+	var deployments []Deployment
+	for _, wl := range wls {
+		wl.namespace = manifestNamespace(wl.namespace, namespace)
+		if namespace != "" && !strings.EqualFold(wl.namespace, namespace) {
+			continue
+		}
+		if !matchLabels(labelMap, wl.labels) {
+			continue
+		}
+		deployments = append(deployments, toDeployment(wl, nil, services))
+	}
+	return deployments, nil
+}
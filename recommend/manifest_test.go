@@ -0,0 +1,154 @@
+package recommend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+const deployment = `---
+# Source: wordpress/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wordpress
+spec:
+  selector:
+    matchLabels:
+      app: wordpress
+  template:
+    metadata:
+      labels:
+        app: wordpress
+    spec:
+      containers:
+      - name: wordpress
+        image: wordpress:4.8-apache
+        ports:
+        - name: http
+          containerPort: 80
+`
+
+const manifests = deployment + `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: wordpress
+spec:
+  selector:
+    app: wordpress
+  ports:
+  - port: 8080
+    targetPort: http
+  - port: 443
+    protocol: UDP
+---
+apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: ksp-wordpress
+spec:
+  selector:
+    matchLabels:
+      app: wordpress
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  metadata:
+    name: mysql
+    namespace: wordpress-mysql
+  spec:
+    selector:
+      matchLabels:
+        app: mysql
+    template:
+      metadata:
+        labels:
+          app: mysql
+      spec:
+        containers:
+        - name: mysql
+          image: mysql:5.6
+`
+
+const kustomization = `namespace: wordpress-mysql
+resources:
+- deployment.yaml
+`
+
+func TestGetWorkloadsFromManifests(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "wordpress.yaml")
+	if err := os.WriteFile(file, []byte(manifests), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deployments, err := getWorkloadsFromManifests(file, "", LabelMap{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deployments) != 2 {
+		t.Fatalf("expected 2 workloads, got %+v", deployments)
+	}
+	for _, dp := range deployments {
+		switch dp.Kind {
+		case "Deployment":
+			if dp.Namespace != "default" || len(dp.Images) != 1 || dp.Images[0] != "wordpress:4.8-apache" {
+				t.Errorf("unexpected deployment %+v", dp)
+			}
+			want := []image.Port{
+				{Port: 80, Protocol: "tcp", Source: "container,service/wordpress"},
+				{Port: 443, Protocol: "udp", Source: "service/wordpress"},
+			}
+			if !reflect.DeepEqual(dp.Ports, want) {
+				t.Errorf("expected ports %v, got %v", want, dp.Ports)
+			}
+		case "StatefulSet":
+			if dp.Namespace != "wordpress-mysql" || len(dp.Ports) != 0 {
+				t.Errorf("unexpected statefulset %+v", dp)
+			}
+		default:
+			t.Errorf("unexpected workload %+v", dp)
+		}
+	}
+
+	deployments, err = getWorkloadsFromManifests(dir, "wordpress-mysql", LabelMap{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deployments) != 2 {
+		t.Errorf("namespace not applied to manifests without one, got %+v", deployments)
+	}
+
+	deployments, err = getWorkloadsFromManifests(file, "", LabelMap{"app": "mysql"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deployments) != 1 || deployments[0].Kind != "StatefulSet" {
+		t.Errorf("label filter not applied, got %+v", deployments)
+	}
+}
+
+func TestGetWorkloadsFromKustomization(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte(deployment), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deployments, err := getWorkloadsFromManifests(dir, "", LabelMap{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deployments) != 1 || deployments[0].Namespace != "wordpress-mysql" {
+		t.Errorf("unexpected kustomize build result %+v", deployments)
+	}
+}
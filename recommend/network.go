@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/policy/api"
+	"github.com/fatih/color"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// NetworkPolicyCilium generates a CiliumNetworkPolicy per workload
+const NetworkPolicyCilium = "cilium"
+
+func ciliumProtocol(proto string) (api.L4Proto, bool) {
+	switch strings.ToLower(proto) {
+	case "tcp":
+		return api.ProtoTCP, true
+	case "udp":
+		return api.ProtoUDP, true
+	case "sctp":
+		return api.ProtoSCTP, true
+	}
+	return "", false
+}
+
+// ciliumNetworkPolicy builds the ingress baseline of a workload: ingress is
+// allowed only on the ports it listens on, workloads without known ports get
+// an ingress default deny. The outbound dependencies of a workload are not
+// known from its ports, egress is left unrestricted.
+func ciliumNetworkPolicy(name, namespace string, selector LabelMap, ports []image.Port) v2.CiliumNetworkPolicy {
+	var toPorts []api.PortProtocol
+	for _, p := range image.MergePorts(ports) {
+		proto, ok := ciliumProtocol(p.Protocol)
+		if !ok {
+			continue
+		}
+		toPorts = append(toPorts, api.PortProtocol{Port: strconv.Itoa(int(p.Port)), Protocol: proto})
+	}
+
+	ingress := api.IngressRule{}
+	if len(toPorts) > 0 {
+		ingress.ToPorts = api.PortRules{{Ports: toPorts}}
+	}
+	matchLabels := map[string]string{}
+	for k, v := range selector {
+		matchLabels[k] = v
+	}
+
+	policy := v2.CiliumNetworkPolicy{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "cilium.io/v2",
+			Kind:       "CiliumNetworkPolicy",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: &api.Rule{
+			EndpointSelector: api.NewESFromMatchRequirements(matchLabels, nil),
+			Ingress:          []api.IngressRule{ingress},
+		},
+	}
+	return policy
+}
+
+// writeNetworkPolicy writes the CiliumNetworkPolicy of the deployment next to its KubeArmor policies
+func writeNetworkPolicy(deployment Deployment, img *image.Info, ports []image.Port) {
+	if len(deployment.Labels) == 0 {
+		log.WithField("image", img.Name).Warn("no labels to select the workload, skipping network policy. Use --labels")
+		return
+	}
+	if deployment.Name == "" && len(img.RepoTags) == 0 {
+		return
+	}
+	policyDir := img.GetPolicyDir(options.OutDir)
+	name := deployment.Name
+	if name == "" {
+		name = filepath.Base(policyDir)
+	}
+
+	policy := ciliumNetworkPolicy(name+"-network-baseline", deployment.Namespace, deployment.Labels, ports)
+	arr, err := json.Marshal(policy)
+	if err != nil {
+		log.WithError(err).Error("failed to marshal network policy")
+		return
+	}
+	yamlPolicy, _ := yaml.JSONToYAML(arr)
+	if err := os.MkdirAll(policyDir, 0750); err != nil {
+		log.WithError(err).Error("failed to create directory")
+	}
+	outFile := filepath.Join(policyDir, "cilium-network-baseline.yaml")
+	if err := os.WriteFile(filepath.Clean(outFile), yamlPolicy, 0600); err != nil {
+		log.WithError(err).Error(fmt.Sprintf("create file %s failed", outFile))
+		return
+	}
+	color.Green("created policy %s ...", outFile)
+}
@@ -0,0 +1,50 @@
+package recommend
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCiliumNetworkPolicy(t *testing.T) {
+	policy := ciliumNetworkPolicy("wordpress-network-baseline", "default", LabelMap{"app": "wordpress"}, []image.Port{
+		{Port: 80, Protocol: "tcp", Source: image.PortSourceImage},
+		{Port: 80, Protocol: "tcp", Source: "service/wordpress"},
+		{Port: 53, Protocol: "udp"},
+	})
+	if err := policy.Spec.Sanitize(); err != nil {
+		t.Fatalf("invalid policy: %s", err)
+	}
+	arr, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := yaml.JSONToYAML(arr)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, want := range []string{
+		"kind: CiliumNetworkPolicy",
+		"any:app: wordpress",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in\n%s", want, data)
+		}
+	}
+	if len(policy.Spec.Egress) != 0 || strings.Contains(string(data), "egress") {
+		t.Errorf("egress should be left unrestricted\n%s", data)
+	}
+
+	ports := policy.Spec.Ingress[0].ToPorts[0].Ports
+	if len(ports) != 2 || ports[0].Port != "53" || ports[0].Protocol != "UDP" || ports[1].Port != "80" || ports[1].Protocol != "TCP" {
+		t.Errorf("unexpected ingress ports %+v", ports)
+	}
+
+	policy = ciliumNetworkPolicy("job-network-baseline", "default", LabelMap{"app": "job"}, nil)
+	if len(policy.Spec.Ingress) != 1 || len(policy.Spec.Ingress[0].ToPorts) != 0 {
+		t.Errorf("expected ingress default deny, got %+v", policy.Spec.Ingress)
+	}
+}
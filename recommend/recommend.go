@@ -5,14 +5,16 @@
 package recommend
 
 import (
-	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
 	"github.com/kubearmor/kubearmor-client/k8s"
 	"github.com/kubearmor/kubearmor-client/recommend/common"
 	"github.com/kubearmor/kubearmor-client/recommend/engines"
@@ -22,17 +24,21 @@ import (
 	"sigs.k8s.io/yaml"
 
 	log "github.com/sirupsen/logrus"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var options common.Options
 
-// Deployment contains brief information about a k8s deployment
+// Deployment contains brief information about a k8s workload
 type Deployment struct {
+	Kind      string
 	Name      string
 	Namespace string
 	Labels    LabelMap
 	Images    []string
+	// Digests maps an image to the digest reference actually running, if known
+	Digests map[string]string
+	// Ports container ports and target ports of the Services selecting the workload
+	Ports []image.Port
 }
 
 // LabelMap is an alias for map[string]string
@@ -100,7 +106,7 @@ func finalReport() {
 		log.WithError(err).Error("report render failed")
 	}
 	color.Green("output report in %s ...", repFile)
-	if strings.Contains(repFile, ".html") {
+	if f := report.Format(); options.Check || options.Interactive || (f != report.FormatText && f != report.FormatMarkdown) {
 		return
 	}
 	data, err := os.ReadFile(repFile)
@@ -128,6 +134,8 @@ func writePolicyFile(policMap map[string][]byte, msMap map[string]interface{}) {
 		if err = f.Close(); err != nil {
 			log.WithError(err).Error("file close failed")
 		}
+		generated[outFile] = policy
+		propose(outFile, policy, msMap[outFile])
 		if err = report.Record(msMap[outFile], outFile); err != nil {
 			log.WithError(err).Error("report record failed")
 		}
@@ -136,6 +144,139 @@ func writePolicyFile(policMap map[string][]byte, msMap map[string]interface{}) {
 	}
 }
 
+// writeConsolidated merges the policies of the image into the policies of its workload.
+// The consolidated policies are rewritten for every image of the workload.
+func writeConsolidated(merged *image.Consolidated, img *image.Info, policyMap map[string][]byte, msMap map[string]interface{}) {
+	var outFiles []string
+	for outFile := range policyMap {
+		outFiles = append(outFiles, outFile)
+	}
+	sort.Strings(outFiles)
+
+	consolidatedFiles := map[string]string{}
+	for _, outFile := range outFiles {
+		ms, ok := msMap[outFile].(common.MatchSpec)
+		if !ok {
+			continue
+		}
+		var policy pol.KubeArmorPolicy
+		if err := json.Unmarshal(policyMap[outFile], &policy); err != nil {
+			log.WithError(err).Error(fmt.Sprintf("failed to parse policy %s", outFile))
+			continue
+		}
+		consolidatedFiles[outFile] = merged.Add(img, policy, ms, options.OutDir)
+	}
+
+	policies, err := merged.Policies()
+	if err != nil {
+		log.WithError(err).Error("failed to consolidate policies")
+		return
+	}
+	for outFile, policy := range policies {
+		if err := os.MkdirAll(filepath.Dir(outFile), 0750); err != nil {
+			log.WithError(err).Error("failed to create directory")
+		}
+		generated[outFile] = policy
+		yamlPolicy, _ := yaml.JSONToYAML(policy)
+		if err := os.WriteFile(filepath.Clean(outFile), yamlPolicy, 0600); err != nil {
+			log.WithError(err).Error(fmt.Sprintf("create file %s failed", outFile))
+			continue
+		}
+		color.Green("created policy %s ...", outFile)
+	}
+	for _, outFile := range outFiles {
+		if err := report.Record(msMap[outFile], consolidatedFiles[outFile]); err != nil {
+			log.WithError(err).Error("report record failed")
+		}
+	}
+}
+
+func newImageInfo(deployment Deployment, i string) image.Info {
+	img := image.Info{
+		Name:       i,
+		Namespace:  deployment.Namespace,
+		Labels:     deployment.Labels,
+		Image:      i,
+		Kind:       deployment.Kind,
+		Deployment: deployment.Name,
+		Ports:      deployment.Ports,
+	}
+	if pinned, ok := deployment.Digests[i]; ok {
+		// scan exactly what is running
+		img.Name = pinned
+	}
+	return img
+}
+
+// imageVariants returns the images to analyze, one per linux platform of
+// multi-platform images with --all-platforms
+func imageVariants(reg *registry.Scanner, img image.Info) []image.Info {
+	if !options.AllPlatforms {
+		return []image.Info{img}
+	}
+	platforms, err := reg.Platforms(img.Name)
+	if err != nil {
+		log.WithError(err).WithField("image", img.Name).Warn("failed to list the platforms of the image")
+		return []image.Info{img}
+	}
+	var variants []image.Info
+	for _, p := range platforms {
+		if !strings.HasPrefix(p, "linux/") {
+			log.WithFields(log.Fields{
+				"image":    img.Name,
+				"platform": p,
+			}).Info("skipping non-linux platform")
+			continue
+		}
+		v := img
+		v.Platform = p
+		variants = append(variants, v)
+	}
+	if len(variants) == 0 {
+		return []image.Info{img}
+	}
+	return variants
+}
+
+// analyzeImage analyzes the image, merging the analysis of its platform
+// variants so that the policies hold on every node of mixed clusters
+func analyzeImage(reg *registry.Scanner, img *image.Info) {
+	variants := imageVariants(reg, *img)
+	if len(variants) == 1 && variants[0].Platform == "" {
+		reg.Analyze(img)
+		return
+	}
+	for i := range variants {
+		reg.Analyze(&variants[i])
+	}
+	*img = image.MergePlatforms(variants)
+	if img.Diverges() {
+		log.WithFields(log.Fields{
+			"image": img.Name,
+			"arch":  img.Arch,
+		}).Warn("file lists differ between the platforms of the image, see the report")
+	}
+}
+
+// hostDeployment analyzes the node root filesystems and node images for host
+// policies selecting the nodes by their labels
+func hostDeployment(o common.Options) Deployment {
+	nodeSelector := labelArrayToLabelMap(o.NodeSelector)
+	if len(nodeSelector) == 0 {
+		log.WithField("nodeSelector", image.DefaultNodeSelector).Warn("no --node-selector given, host policies will apply to every node")
+		nodeSelector = image.DefaultNodeSelector
+	}
+	dp := Deployment{
+		Kind:   "Node",
+		Labels: nodeSelector,
+		Images: append([]string{}, o.Images...),
+	}
+	for _, rootfs := range o.Rootfs {
+		dp.Images = append(dp.Images, registry.RootfsPrefix+rootfs)
+	}
+	return dp
+}
+
 // Recommend handler for karmor cli tool
 func Recommend(c *k8s.Client, o common.Options, policyGenerators ...engines.Engine) error {
 	var policyMap map[string][]byte
@@ -143,34 +284,75 @@ func Recommend(c *k8s.Client, o common.Options, policyGenerators ...engines.Engi
 	var err error
 	deployments := []Deployment{}
 
-	labelMap := labelArrayToLabelMap(o.Labels)
-	if len(o.Images) == 0 {
-		// recommendation based on k8s manifest
-		dps, err := c.K8sClientset.AppsV1().Deployments(o.Namespace).List(context.TODO(), v1.ListOptions{})
-		if err != nil {
+	if err := o.ValidateActions(); err != nil {
+		return err
+	}
+	if o.CoverageReport != "" {
+		if _, err := report.CoverageFormatOf(o.CoverageReport); err != nil {
 			return err
 		}
-		for _, dp := range dps.Items {
+	}
+	if err := validateInteractive(o); err != nil {
+		return err
+	}
+	if o.Check && len(o.RequireTags) == 0 {
+		return errors.New("--check needs the tags to require, use --require-tags")
+	}
+	if o.NetworkPolicy != "" && o.NetworkPolicy != NetworkPolicyCilium {
+		return fmt.Errorf("unsupported network policy %q, supported: %s", o.NetworkPolicy, NetworkPolicyCilium)
+	}
+	if o.Platform != "" && o.AllPlatforms {
+		return errors.New("use either --platform or --all-platforms")
+	}
+	if o.Platform != "" && !strings.HasPrefix(o.Platform, "linux/") {
+		return fmt.Errorf("unsupported platform %s, only linux platforms can be analyzed", o.Platform)
+	}
+	if (o.Diff || o.Apply) && c == nil {
+		return errors.New("--diff and --apply need a k8s cluster connection")
+	}
+	if (o.Diff || o.Apply) && len(o.Images) > 0 && o.Namespace == "" {
+		return errors.New("--diff and --apply need the --namespace of the policies recommended for --image")
+	}
 
-			if !matchLabels(labelMap, dp.Spec.Template.Labels) {
-				continue
-			}
-			images := []string{}
-			for _, container := range dp.Spec.Template.Spec.Containers {
-				images = append(images, container.Image)
-			}
+	for _, kind := range o.Policy {
+		if kind != common.KindKubeArmorPolicy && kind != common.KindKubeArmorHostPolicy {
+			return fmt.Errorf("unsupported policy kind %q, supported: %s, %s", kind, common.KindKubeArmorPolicy, common.KindKubeArmorHostPolicy)
+		}
+	}
+	if o.HostPolicy() && len(o.Policy) > 1 {
+		return errors.New("host and pod policies are recommended separately, use a single --kind")
+	}
 
-			deployments = append(deployments, Deployment{
-				Name:      dp.Name,
-				Namespace: dp.Namespace,
-				Labels:    dp.Spec.Template.Labels,
-				Images:    images,
-			})
+	for _, sbom := range o.SBOMs {
+		o.Images = append(o.Images, registry.SBOMPrefix+sbom)
+	}
+
+	labelMap := labelArrayToLabelMap(o.Labels)
+	if o.HostPolicy() {
+		if o.Consolidate || o.NetworkBaseline || o.NetworkPolicy != "" || o.Diff || o.Apply || o.Check {
+			return errors.New("--consolidate, --network-baseline, --network-policy, --diff, --apply and --check are not supported for host policies")
+		}
+		if len(o.Images) == 0 && len(o.Rootfs) == 0 {
+			return errors.New("use --rootfs or --image to analyze the node root filesystem")
+		}
+		deployments = append(deployments, hostDeployment(o))
+	} else if len(o.Images) == 0 {
+		if o.File != "" {
+			// recommendation based on local k8s manifests
+			deployments, err = getWorkloadsFromManifests(o.File, o.Namespace, labelMap)
+		} else if c == nil {
+			return errors.New("no k8s cluster connection, use --image or --file to recommend offline")
+		} else {
+			// recommendation based on k8s manifest
+			deployments, err = getWorkloads(c, o.Namespace, labelMap)
+		}
+		if err != nil {
+			return err
 		}
 		if len(deployments) == 0 {
 			log.WithFields(log.Fields{
 				"namespace": o.Namespace,
-			}).Error("no k8s deployments found, hence nothing to recommend!")
+			}).Error("no k8s workloads found, hence nothing to recommend!")
 			return nil
 		}
 	} else {
@@ -181,9 +363,26 @@ func Recommend(c *k8s.Client, o common.Options, policyGenerators ...engines.Engi
 		})
 	}
 
+	var existing []pol.KubeArmorPolicy
+	if o.Check {
+		// read before the recommended policies are written, the directory may be the output one
+		if existing, err = loadExistingPolicies(c, o.PolicyDir); err != nil {
+			return err
+		}
+	}
+
 	o.Tags = unique(o.Tags)
+	o.RequireTags = unique(o.RequireTags)
 	options = o
-	reg := registry.New(o.Config)
+	generated = map[string][]byte{}
+	coverage = nil
+	checked = nil
+	proposals = nil
+	pendingCoverages = nil
+	if o.CoverageReport != "" {
+		coverage = report.NewCoverage()
+	}
+	var reg *registry.Scanner
 
 	if err = createOutDir(o.OutDir); err != nil {
 		return err
@@ -191,33 +390,90 @@ func Recommend(c *k8s.Client, o common.Options, policyGenerators ...engines.Engi
 
 	for _, gen := range policyGenerators {
 		if o.ReportFile != "" {
-			report.Init(o.ReportFile)
+			if err := report.Init(o.ReportFile, o.ReportFormat); err != nil {
+				return err
+			}
+		}
+		if o.Interactive {
+			// the report lists the reviewed policies only
+			report.Defer()
 		}
 		if err := gen.Init(); err != nil {
 			log.WithError(err).Error("policy generator init failed")
+			return err
+		}
+		analyze := true
+		if ii, ok := gen.(engines.ImageIndependent); ok && ii.ImageIndependent() {
+			analyze = false
+		}
+		if analyze && reg == nil {
+			reg = registry.New(o)
+			var imgs []image.Info
+			for _, deployment := range deployments {
+				for _, i := range deployment.Images {
+					imgs = append(imgs, imageVariants(reg, newImageInfo(deployment, i))...)
+				}
+			}
+			reg.Prefetch(imgs)
 		}
 		for _, deployment := range deployments {
+			var merged *image.Consolidated
+			var ports []image.Port
+			var img image.Info
 			for _, i := range deployment.Images {
-				img := image.Info{
-					Name:       i,
-					Namespace:  deployment.Namespace,
-					Labels:     deployment.Labels,
-					Image:      i,
-					Deployment: deployment.Name,
+				img = newImageInfo(deployment, i)
+				if analyze {
+					analyzeImage(reg, &img)
 				}
-				reg.Analyze(&img)
+				ports = append(ports, img.NetworkPorts()...)
 				if policyMap, msMap, err = gen.Scan(&img, o); err != nil {
 					log.WithError(err).Error("policy generator scan failed")
 				}
-				writePolicyFile(policyMap, msMap)
+				recordCoverage(deployment, i, msMap)
+				recordCheck(deployment, i, msMap)
+				if o.Consolidate {
+					if merged == nil || deployment.Name == "" {
+						// images without a workload have their own selectors
+						merged = image.NewConsolidated()
+					}
+					writeConsolidated(merged, &img, policyMap, msMap)
+				} else {
+					writePolicyFile(policyMap, msMap)
+				}
 				if err := report.SectEnd(); err != nil {
 					log.WithError(err).Error("report section end failed")
 					return err
 				}
 			}
+			if o.NetworkPolicy == NetworkPolicyCilium && len(deployment.Images) > 0 {
+				writeNetworkPolicy(deployment, &img, ports)
+			}
+		}
+		if !o.Interactive {
+			finalReport()
+		}
+	}
+	if o.Interactive {
+		if err := reviewPolicies(); err != nil {
+			return err
 		}
 		finalReport()
 	}
 
+	live := map[string][]pol.KubeArmorPolicy{}
+	for _, deployment := range deployments {
+		recordAppliedCoverage(c, deployment, live)
+	}
+	writeCoverage()
+
+	if o.Check {
+		if err := checkPolicies(os.Stdout, existing); err != nil {
+			return err
+		}
+	}
+
+	if o.Diff || o.Apply {
+		return syncPolicies(c, o.Diff, o.Apply, o.DryRun)
+	}
 	return nil
 }
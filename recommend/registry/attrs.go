@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package registry
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+// fileAttrs risky files of the layers extracted so far, by path in the rootfs
+type fileAttrs map[string]image.FileAttr
+
+// record the entry of a layer, replacing what lower layers had at the path
+func (a fileAttrs) record(hdr *tar.Header, path string) {
+	if a == nil {
+		return
+	}
+	if attr, ok := image.FileAttrOf(hdr); ok {
+		attr.Path = path
+		a[path] = attr
+		return
+	}
+	delete(a, path)
+}
+
+// remove the path and everything below it, for whiteouts
+func (a fileAttrs) remove(path string, keepSelf bool) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for p := range a {
+		if strings.HasPrefix(p, prefix) || (!keepSelf && p == path) {
+			delete(a, p)
+		}
+	}
+}
+
+// list the risky files still present in the extracted rootfs
+func (a fileAttrs) list(rootfs string) []image.FileAttr {
+	var attrs []image.FileAttr
+	for p, attr := range a {
+		if _, err := os.Lstat(filepath.Join(rootfs, p)); err != nil {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Path < attrs[j].Path })
+	return attrs
+}
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultCacheMaxSize default size limit of the image analysis cache in MB
+const DefaultCacheMaxSize = 1024
+
+// DefaultCacheDir returns the directory of the image analysis cache
+func DefaultCacheDir() string {
+	return filepath.Join(common.UserHome(), ".cache", "karmor", "images")
+}
+
+// Cache persistent image analysis results keyed by image ID, the digest of the
+// image config, so that both backends share the entries of an image
+type Cache struct {
+	Dir string
+	// MaxSize in bytes, least recently used entries are evicted beyond it. 0 disables the limit
+	MaxSize int64
+}
+
+// cacheVersion format of the cache entries, older entries are analyzed again
+const cacheVersion = 3
+
+// cacheEntry analysis results of a single image
+type cacheEntry struct {
+	Version  int                 `json:"version"`
+	Digest   string              `json:"digest"`
+	Arch     string              `json:"arch"`
+	OS       string              `json:"os"`
+	Distro   string              `json:"distro"`
+	RepoTags map[string][]string `json:"repoTags"` // by image name
+	FileList []string            `json:"fileList"`
+	DirList  []string            `json:"dirList"`
+	Config   image.Config        `json:"config"`
+	Packages []image.Package     `json:"packages"`
+	Risky    []image.FileAttr    `json:"riskyFiles"`
+	Analyzed time.Time           `json:"analyzed"`
+}
+
+// NewCache creates an image analysis cache in dir, limited to maxSize MB
+func NewCache(dir string, maxSize int64) *Cache {
+	return &Cache{
+		Dir:     dir,
+		MaxSize: maxSize * 1024 * 1024,
+	}
+}
+
+func (c *Cache) path(digest string) string {
+	return filepath.Join(c.Dir, strings.ReplaceAll(digest, ":", "-")+".json")
+}
+
+func (c *Cache) read(digest string) (*cacheEntry, error) {
+	data, err := os.ReadFile(filepath.Clean(c.path(digest)))
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Get fills img with the cached analysis of the digest, if any
+func (c *Cache) Get(digest string, img *image.Info) bool {
+	if c == nil || digest == "" {
+		return false
+	}
+	entry, err := c.read(digest)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.WithError(err).WithField("digest", digest).Warn("ignoring corrupt image cache entry")
+		}
+		return false
+	}
+	if entry.Version != cacheVersion {
+		log.WithField("digest", digest).Debug("ignoring image cache entry of an older format")
+		return false
+	}
+
+	img.Arch = entry.Arch
+	img.OS = entry.OS
+	img.Distro = entry.Distro
+	img.FileList = entry.FileList
+	img.DirList = entry.DirList
+	img.Config = entry.Config
+	img.Packages = entry.Packages
+	img.RiskyFiles = entry.Risky
+	if tags, ok := entry.RepoTags[img.Name]; ok {
+		img.RepoTags = append(img.RepoTags, tags...)
+	} else {
+		img.RepoTags = append(img.RepoTags, img.DefaultRepoTag())
+	}
+
+	// mark as recently used
+	now := time.Now()
+	if err := os.Chtimes(c.path(digest), now, now); err != nil {
+		log.WithError(err).Debug("failed to update image cache entry time")
+	}
+	log.WithFields(log.Fields{
+		"image":  img.Name,
+		"digest": digest,
+	}).Info("using cached analysis for image")
+	return true
+}
+
+// Put stores the analysis of img under its digest
+func (c *Cache) Put(digest string, img *image.Info) error {
+	if c == nil || digest == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0750); err != nil {
+		return err
+	}
+
+	entry, err := c.read(digest)
+	if err != nil || entry.Version != cacheVersion {
+		entry = &cacheEntry{RepoTags: map[string][]string{}}
+	}
+	entry.Version = cacheVersion
+	entry.Digest = digest
+	entry.Arch = img.Arch
+	entry.OS = img.OS
+	entry.Distro = img.Distro
+	entry.FileList = img.FileList
+	entry.DirList = img.DirList
+	entry.Config = img.Config
+	entry.Packages = img.Packages
+	entry.Risky = img.RiskyFiles
+	entry.RepoTags[img.Name] = img.RepoTags
+	entry.Analyzed = time.Now()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// write and rename, concurrent scans of the same digest never see partial entries
+	tmp, err := os.CreateTemp(c.Dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(digest)); err != nil {
+		return err
+	}
+
+	if c.MaxSize > 0 {
+		_, _, err = c.Prune(PruneOptions{MaxSize: c.MaxSize})
+	}
+	return err
+}
+
+// PruneOptions selects the cache entries to remove
+type PruneOptions struct {
+	// All removes every entry
+	All bool
+	// MaxAge removes entries not used for longer than this
+	MaxAge time.Duration
+	// MaxSize in bytes, removes the least recently used entries beyond it
+	MaxSize int64
+}
+
+// Prune removes cache entries, returning the number of entries removed and bytes freed
+func (c *Cache) Prune(opts PruneOptions) (int, int64, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	var files []os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	// most recently used first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	removed := 0
+	var freed, kept int64
+	for _, f := range files {
+		remove := opts.All ||
+			(opts.MaxAge > 0 && time.Since(f.ModTime()) > opts.MaxAge) ||
+			(opts.MaxSize > 0 && kept+f.Size() > opts.MaxSize)
+		if !remove {
+			kept += f.Size()
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, freed, err
+		}
+		removed++
+		freed += f.Size()
+	}
+	return removed, freed, nil
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(t.TempDir(), 0)
+	analyzed := &image.Info{
+		Name:     "nginx:1.25",
+		Arch:     "amd64",
+		OS:       "linux",
+		Distro:   "debian",
+		RepoTags: []string{"nginx:1.25"},
+		FileList: []string{"/usr/sbin/nginx"},
+		DirList:  []string{"/usr/sbin"},
+	}
+	if err := cache.Put("sha256:aaaa", analyzed); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	img := &image.Info{Name: "nginx:1.25"}
+	if !cache.Get("sha256:aaaa", img) {
+		t.Fatal("cached entry not found")
+	}
+	if img.Distro != "debian" || len(img.FileList) != 1 || img.RepoTags[0] != "nginx:1.25" {
+		t.Errorf("unexpected cached analysis %+v", img)
+	}
+	// same digest referenced by another tag
+	img = &image.Info{Name: "nginx:stable", Image: "nginx:stable"}
+	if !cache.Get("sha256:aaaa", img) || img.RepoTags[0] != "nginx:stable" {
+		t.Errorf("unexpected repo tags %v", img.RepoTags)
+	}
+	if cache.Get("sha256:bbbb", &image.Info{}) {
+		t.Error("unexpected cache hit")
+	}
+
+	if err := cache.Put("sha256:bbbb", analyzed); err != nil {
+		t.Fatal(err.Error())
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(cache.Dir, "sha256-aaaa.json"), old, old); err != nil {
+		t.Fatal(err.Error())
+	}
+	removed, _, err := cache.Prune(PruneOptions{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if removed != 1 || cache.Get("sha256:aaaa", &image.Info{}) || !cache.Get("sha256:bbbb", &image.Info{}) {
+		t.Errorf("least recently used entry not pruned, removed %d", removed)
+	}
+
+	if _, _, err := cache.Prune(PruneOptions{MaxSize: 1}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if cache.Get("sha256:bbbb", &image.Info{}) {
+		t.Error("size limit not applied")
+	}
+}
+
+func TestSessionCacheKeepsWorkload(t *testing.T) {
+	s := &Scanner{cache: map[string]image.Info{
+		"wordpress:4.8-apache": {
+			Name:       "wordpress:4.8-apache",
+			Namespace:  "wordpress-mysql",
+			Labels:     image.LabelMap{"app": "wordpress"},
+			Deployment: "wordpress",
+			OS:         "linux",
+			FileList:   []string{"/usr/sbin/apache2"},
+		},
+	}}
+	img := &image.Info{
+		Name:       "wordpress:4.8-apache",
+		Namespace:  "blog",
+		Labels:     image.LabelMap{"app": "blog"},
+		Deployment: "blog",
+	}
+	s.Analyze(img)
+	if img.Namespace != "blog" || img.Labels["app"] != "blog" || img.Deployment != "blog" {
+		t.Errorf("workload of the cached image copied to %+v", img)
+	}
+	if img.OS != "linux" || len(img.FileList) != 1 {
+		t.Errorf("image analysis not copied from the session cache %+v", img)
+	}
+}
@@ -0,0 +1,441 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+	log "github.com/sirupsen/logrus"
+)
+
+// Image sources handled without the docker daemon
+const (
+	// OCILayoutPrefix oci-layout:<dir>[@sha256:<digest>|:<ref name>]
+	OCILayoutPrefix = "oci-layout:"
+	// DockerArchivePrefix docker-archive:<file>[:<repo:tag>], as written by `docker save`
+	DockerArchivePrefix = "docker-archive:"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+
+	// annotation holding the tag of images in an OCI layout
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+func isLocalImage(imageName string) bool {
+	return strings.HasPrefix(imageName, OCILayoutPrefix) || strings.HasPrefix(imageName, DockerArchivePrefix)
+}
+
+// splitLocalRef splits a local image reference into the path and the optional image selector
+func splitLocalRef(ref, sep string) (string, string) {
+	if _, err := os.Stat(ref); err == nil {
+		return ref, ""
+	}
+	if idx := strings.Index(ref, sep); idx > 0 {
+		return ref[:idx], ref[idx+len(sep):]
+	}
+	return ref, ""
+}
+
+// configKeychain resolves credentials from the docker config used by the daemon backend
+type configKeychain struct {
+	auths map[string]authn.AuthConfig
+	// fallback credentials from DOCKER_USERNAME/DOCKER_PASSWORD
+	env authn.AuthConfig
+}
+
+// registryHost normalizes docker config.json auth keys such as https://index.docker.io/v1/
+func registryHost(key string) string {
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	key = strings.SplitN(key, "/", 2)[0]
+	switch key {
+	case "docker.io", "registry-1.docker.io":
+		return name.DefaultRegistry
+	}
+	return key
+}
+
+// Resolve implements authn.Keychain
+func (k configKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k.auths[registryHost(target.RegistryStr())]; ok {
+		return authn.FromConfig(auth), nil
+	}
+	if k.env.Username != "" && k.env.Password != "" {
+		return authn.FromConfig(k.env), nil
+	}
+	return authn.Anonymous, nil
+}
+
+func (r *Scanner) keychain() authn.Keychain {
+	kc := configKeychain{
+		auths: r.authConfiguration.auths,
+		env: authn.AuthConfig{
+			Username: os.Getenv("DOCKER_USERNAME"),
+			Password: os.Getenv("DOCKER_PASSWORD"),
+		},
+	}
+	// the default keychain additionally handles credential helpers
+	return authn.NewMultiKeychain(kc, authn.DefaultKeychain)
+}
+
+// platformFor returns the platform of the image to analyze: the variant
+// requested for the image, the --platform option or linux on the local arch
+func (r *Scanner) platformFor(img *image.Info) v1.Platform {
+	if img.Platform != "" {
+		if p, err := v1.ParsePlatform(img.Platform); err == nil {
+			return *p
+		}
+	}
+	if r.platform != nil {
+		return *r.platform
+	}
+	return v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+}
+
+// Platforms lists the platforms of a multi-platform image in the registry,
+// nil for single platform and local images
+func (r *Scanner) Platforms(imageName string) ([]string, error) {
+	if isLocalImage(imageName) || IsRootfs(imageName) || IsSBOM(imageName) {
+		return nil, nil
+	}
+	r.mu.Lock()
+	platforms, ok := r.platforms[imageName]
+	r.mu.Unlock()
+	if ok {
+		return platforms, nil
+	}
+
+	ref, err := name.ParseReference(imageName)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := remote.Get(ref,
+		remote.WithContext(context.Background()),
+		remote.WithAuthFromKeychain(r.keychain()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, m := range manifest.Manifests {
+			// attestation manifests are listed with an unknown platform
+			if m.Platform == nil || m.Platform.OS == "unknown" || !m.MediaType.IsImage() {
+				continue
+			}
+			if p := m.Platform.String(); !seen[p] {
+				seen[p] = true
+				platforms = append(platforms, p)
+			}
+		}
+		sort.Strings(platforms)
+	}
+
+	r.mu.Lock()
+	r.platforms[imageName] = platforms
+	r.mu.Unlock()
+	return platforms, nil
+}
+
+// fetchImage resolves the image from a local OCI layout, a docker archive or a registry.
+// It also returns the tags found for local images.
+func (r *Scanner) fetchImage(imageName string, platform v1.Platform) (v1.Image, []string, error) {
+	switch {
+	case strings.HasPrefix(imageName, OCILayoutPrefix):
+		return ociLayoutImage(strings.TrimPrefix(imageName, OCILayoutPrefix))
+	case strings.HasPrefix(imageName, DockerArchivePrefix):
+		return dockerArchiveImage(strings.TrimPrefix(imageName, DockerArchivePrefix))
+	}
+
+	ref, err := name.ParseReference(imageName)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.WithFields(log.Fields{
+		"image":    imageName,
+		"platform": platform.String(),
+	}).Info("fetching image from registry")
+	img, err := remote.Image(ref,
+		remote.WithContext(context.Background()),
+		remote.WithAuthFromKeychain(r.keychain()),
+		remote.WithPlatform(platform),
+	)
+	return img, nil, err
+}
+
+func ociLayoutImage(ref string) (v1.Image, []string, error) {
+	path, selector := splitLocalRef(ref, "@")
+	if selector == "" {
+		path, selector = splitLocalRef(ref, ":")
+	}
+	idx, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, desc := range manifest.Manifests {
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+		refName := desc.Annotations[ociRefNameAnnotation]
+		if selector != "" && selector != desc.Digest.String() && selector != refName {
+			continue
+		}
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		if refName == "" {
+			refName = filepath.Base(filepath.Clean(path))
+		}
+		return img, []string{refName}, nil
+	}
+	return nil, nil, fmt.Errorf("no image %q found in oci layout %s", selector, path)
+}
+
+func dockerArchiveImage(ref string) (v1.Image, []string, error) {
+	path, selector := splitLocalRef(ref, ":")
+	var tag *name.Tag
+	if selector != "" {
+		t, err := name.NewTag(selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		tag = &t
+	}
+	img, err := tarball.ImageFromPath(path, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tags []string
+	if selector != "" {
+		tags = append(tags, selector)
+	} else if manifest, err := tarball.LoadManifest(func() (io.ReadCloser, error) {
+		return os.Open(filepath.Clean(path))
+	}); err == nil && len(manifest) > 0 {
+		tags = append(tags, manifest[0].RepoTags...)
+	}
+	if len(tags) == 0 {
+		tags = append(tags, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	return img, tags, nil
+}
+
+// analyzeImage analyzes the image without the docker daemon
+func (r *Scanner) analyzeImage(img *image.Info) error {
+	oci, tags, err := r.fetchImage(img.Name, r.platformFor(img))
+	if err != nil {
+		return err
+	}
+	// keyed by the config digest, the image ID the daemon backend resolves too
+	digest, err := oci.ConfigName()
+	if err != nil {
+		return err
+	}
+	if r.store.Get(digest.String(), img) {
+		return nil
+	}
+	cfg, err := oci.ConfigFile()
+	if err != nil {
+		return err
+	}
+	attrs := fileAttrs{}
+	if err := applyLayers(oci, img.TempDir, attrs); err != nil {
+		return err
+	}
+	if img.FileList, img.DirList, err = listRootfs(img.TempDir); err != nil {
+		return err
+	}
+	img.RiskyFiles = attrs.list(img.TempDir)
+	img.ReadConfigFile(cfg, tags)
+	img.ReadPackages()
+	trimRootfs(img)
+	if err := r.store.Put(digest.String(), img); err != nil {
+		log.WithError(err).Warn("failed to cache image analysis")
+	}
+	return nil
+}
+
+// applyLayers extracts the image layers in order into rootfs, honouring whiteouts
+func applyLayers(img v1.Image, rootfs string, attrs fileAttrs) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for i, layer := range layers {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return err
+		}
+		err = applyLayer(rc, rootfs, attrs)
+		if cerr := rc.Close(); cerr != nil {
+			log.WithError(cerr).Warn("failed to close layer")
+		}
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func removeDirContents(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceWithDir makes sure tgt is a directory, replacing a file of lower layers
+func replaceWithDir(tgt string) error {
+	if fi, err := os.Lstat(tgt); err == nil && !fi.IsDir() {
+		if err := os.Remove(tgt); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(tgt, 0750)
+}
+
+// applyLayer extracts a single uncompressed layer on top of rootfs
+func applyLayer(r io.Reader, rootfs string, attrs fileAttrs) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fname := filepath.Clean("/" + hdr.Name)
+		base := filepath.Base(fname)
+		dir := filepath.Dir(fname)
+
+		if base == whiteoutOpaque {
+			// opaque whiteout, hide everything lower layers put into the directory
+			tgt, err := sanitizeArchivePath(rootfs, dir)
+			if err != nil {
+				return err
+			}
+			if err := removeDirContents(tgt); err != nil {
+				return err
+			}
+			attrs.remove(dir, true)
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			tgt, err := sanitizeArchivePath(rootfs, filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			if err != nil {
+				return err
+			}
+			if err := os.RemoveAll(tgt); err != nil {
+				return err
+			}
+			attrs.remove(filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), false)
+			continue
+		}
+
+		tgt, err := sanitizeArchivePath(rootfs, fname)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"file": hdr.Name,
+			}).Error("ignoring file since it could not be sanitized")
+			continue
+		}
+		if tgt == filepath.Clean(rootfs) {
+			continue
+		}
+		if err := replaceWithDir(filepath.Dir(tgt)); err != nil {
+			return err
+		}
+		attrs.record(hdr, fname)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := replaceWithDir(tgt); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+			if err := os.RemoveAll(tgt); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(filepath.Clean(tgt), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm()|0600)
+			if err != nil {
+				return err
+			}
+			// links are recorded as empty files, following them while
+			// extracting could escape the rootfs
+			if hdr.Typeflag == tar.TypeReg {
+				if _, err := io.CopyN(f, tr, hdr.Size); err != nil {
+					_ = f.Close()
+					return err
+				}
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// listRootfs lists the files and directories of an extracted rootfs
+func listRootfs(rootfs string) ([]string, []string, error) {
+	var fl []string
+	var dl []string
+	root := filepath.Clean(rootfs)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if info.IsDir() {
+			dl = append(dl, path)
+		} else {
+			fl = append(fl, path)
+		}
+		return nil
+	})
+	return fl, dl, err
+}
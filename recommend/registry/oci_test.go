@@ -0,0 +1,299 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcr "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+type tarEntry struct {
+	name string
+	dir  bool
+	mode int64
+	pax  map[string]string
+}
+
+// netRawCapability security.capability xattr granting cap_net_raw
+var netRawCapability = string([]byte{1, 0, 0, 2, 0, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+func layerOf(t *testing.T, entries ...tarEntry) v1.Layer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg}
+		content := "content of " + e.name
+		if e.dir {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		} else {
+			hdr.Size = int64(len(content))
+		}
+		if e.mode != 0 {
+			hdr.Mode = e.mode
+		}
+		hdr.PAXRecords = e.pax
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err.Error())
+		}
+		if !e.dir {
+			if _, err := tw.Write([]byte(content)); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return layer
+}
+
+func testImage(t *testing.T) v1.Image {
+	img, err := mutate.AppendLayers(empty.Image,
+		layerOf(t,
+			tarEntry{name: "etc/", dir: true},
+			tarEntry{name: "sbin/apk"},
+			tarEntry{name: "etc/passwd"},
+			tarEntry{name: "usr/share/doc/", dir: true},
+			tarEntry{name: "usr/share/doc/README"},
+			tarEntry{name: "tmp/build/", dir: true},
+			tarEntry{name: "tmp/build/main.go"},
+			tarEntry{name: "bin/su", mode: 04755},
+			tarEntry{name: "bin/ping", mode: 0755, pax: map[string]string{"SCHILY.xattr.security.capability": netRawCapability}},
+			tarEntry{name: "usr/share/doc/tool", mode: 06755},
+			tarEntry{name: "data/", dir: true, mode: 0777},
+			tarEntry{name: "tmp/", dir: true, mode: 01777},
+		),
+		layerOf(t,
+			tarEntry{name: "etc/.wh.passwd"},
+			tarEntry{name: "usr/share/doc/.wh..wh..opq"},
+			tarEntry{name: "usr/share/doc/NEWS"},
+			tarEntry{name: "tmp/.wh.build"},
+			tarEntry{name: "./usr/bin/app"},
+		),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS = "linux"
+	cfg.Architecture = "arm64"
+	cfg.Config.User = "app"
+	cfg.Config.Entrypoint = []string{"/usr/bin/app"}
+	cfg.Config.ExposedPorts = map[string]struct{}{"8080/tcp": {}, "53/udp": {}}
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return img
+}
+
+func checkAnalyzed(t *testing.T, img *image.Info) {
+	var files []string
+	for _, f := range img.FileList {
+		files = append(files, strings.TrimPrefix(f, img.TempDir))
+	}
+	got := strings.Join(files, ",")
+	if got != "/bin/ping,/bin/su,/sbin/apk,/usr/bin/app,/usr/share/doc/NEWS" {
+		t.Errorf("unexpected files %s", got)
+	}
+	if img.Arch != "arm64" || img.OS != "linux" {
+		t.Errorf("unexpected platform %s/%s", img.OS, img.Arch)
+	}
+	if img.Distro != "alpine" {
+		t.Errorf("unexpected distro %q", img.Distro)
+	}
+	var risky []string
+	for _, f := range img.RiskyFiles {
+		risky = append(risky, f.String())
+	}
+	if got := strings.Join(risky, ","); got != "/bin/ping (0755 0:0 capabilities cap_net_raw),/bin/su (4755 0:0 setuid),/data (0777 0:0 world-writable)" {
+		t.Errorf("unexpected risky files %s", got)
+	}
+	if c := img.Config; c.User != "app" || len(c.Entrypoint) != 1 || strings.Join(c.ExposedPorts, ",") != "53/udp,8080/tcp" {
+		t.Errorf("unexpected config %+v", c)
+	}
+}
+
+func analyze(t *testing.T, s *Scanner, imageName string) *image.Info {
+	img := &image.Info{Name: imageName, TempDir: t.TempDir()}
+	if err := s.analyzeImage(img); err != nil {
+		t.Fatal(err.Error())
+	}
+	checkAnalyzed(t, img)
+	return img
+}
+
+func TestAnalyzeRegistryImage(t *testing.T) {
+	srv := httptest.NewServer(ggcr.New(ggcr.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(srv.URL, "http://") + "/kubearmor/test:v1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := remote.Write(ref, testImage(t)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s := New(common.Options{Backend: BackendRegistry, NoCache: true})
+	s.store = NewCache(t.TempDir(), 0)
+	img := analyze(t, s, ref.String())
+	if len(img.RepoTags) != 1 || img.RepoTags[0] != ref.String() {
+		t.Errorf("unexpected tags %v", img.RepoTags)
+	}
+
+	// the daemon backend resolves the same cache key without pulling
+	id := s.imageID(&image.Info{Name: ref.String()})
+	config, err := testImage(t).ConfigName()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id != config.String() {
+		t.Errorf("expected the image ID %s, got %s", config, id)
+	}
+	if !s.store.Get(id, &image.Info{Name: ref.String()}) {
+		t.Errorf("analysis not cached under the image ID %s", id)
+	}
+}
+
+func TestAnalyzeLocalImages(t *testing.T) {
+	dir := t.TempDir()
+	s := New(common.Options{Backend: BackendRegistry, NoCache: true})
+
+	ociDir := filepath.Join(dir, "oci")
+	p, err := layout.Write(ociDir, empty.Index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := p.AppendImage(testImage(t), layout.WithAnnotations(map[string]string{
+		ociRefNameAnnotation: "test:v1",
+	})); err != nil {
+		t.Fatal(err.Error())
+	}
+	img := analyze(t, s, OCILayoutPrefix+ociDir)
+	if len(img.RepoTags) != 1 || img.RepoTags[0] != "test:v1" {
+		t.Errorf("unexpected tags %v", img.RepoTags)
+	}
+	analyze(t, s, OCILayoutPrefix+ociDir+":test:v1")
+
+	archive := filepath.Join(dir, "test.tar")
+	tag, err := name.NewTag("kubearmor/test:v1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := tarball.WriteToFile(archive, tag, testImage(t)); err != nil {
+		t.Fatal(err.Error())
+	}
+	img = analyze(t, s, DockerArchivePrefix+archive)
+	if len(img.RepoTags) != 1 || img.RepoTags[0] != "kubearmor/test:v1" {
+		t.Errorf("unexpected tags %v", img.RepoTags)
+	}
+}
+
+func platformImage(t *testing.T, arch string, files ...string) v1.Image {
+	var entries []tarEntry
+	for _, f := range files {
+		entries = append(entries, tarEntry{name: f})
+	}
+	img, err := mutate.AppendLayers(empty.Image, layerOf(t, entries...))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS = "linux"
+	cfg.Architecture = arch
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return img
+}
+
+func TestAnalyzePlatforms(t *testing.T) {
+	srv := httptest.NewServer(ggcr.New(ggcr.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{
+			Add:        platformImage(t, "amd64", "bin/sh", "lib/ld-linux-x86-64.so.2"),
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+		},
+		mutate.IndexAddendum{
+			Add:        platformImage(t, "arm64", "bin/sh", "lib/ld-linux-aarch64.so.1"),
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}},
+		},
+		mutate.IndexAddendum{
+			// attestations are not platform variants
+			Add:        platformImage(t, "unknown"),
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}},
+		},
+	)
+	ref, err := name.ParseReference(strings.TrimPrefix(srv.URL, "http://") + "/kubearmor/multi:v1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s := New(common.Options{Backend: BackendRegistry, NoCache: true})
+	platforms, err := s.Platforms(ref.String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Join(platforms, ",") != "linux/amd64,linux/arm64" {
+		t.Errorf("unexpected platforms %v", platforms)
+	}
+
+	for _, arch := range []string{"amd64", "arm64"} {
+		img := &image.Info{Name: ref.String(), Platform: "linux/" + arch, TempDir: t.TempDir()}
+		if err := s.analyzeImage(img); err != nil {
+			t.Fatal(err.Error())
+		}
+		if img.Arch != arch || len(img.FileList) != 2 {
+			t.Errorf("unexpected analysis of linux/%s: %s %v", arch, img.Arch, img.FileList)
+		}
+	}
+
+	single := strings.TrimPrefix(srv.URL, "http://") + "/kubearmor/test:v1"
+	singleRef, err := name.ParseReference(single)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := remote.Write(singleRef, testImage(t)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if platforms, err := s.Platforms(single); err != nil || platforms != nil {
+		t.Errorf("expected no platforms for a single platform image, got %v %v", platforms, err)
+	}
+}
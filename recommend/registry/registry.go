@@ -17,9 +17,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
 	image "github.com/kubearmor/kubearmor-client/recommend/image"
 	"github.com/moby/term"
 
@@ -31,17 +35,40 @@ import (
 
 const karmorTempDirPattern = "karmor"
 
+// Image analysis backends
+const (
+	// BackendAuto uses the docker daemon if reachable, the registry otherwise
+	BackendAuto = "auto"
+	// BackendDocker pulls and saves images through the docker daemon
+	BackendDocker = "docker"
+	// BackendRegistry fetches manifests and layers directly from the registry
+	BackendRegistry = "registry"
+)
+
+// DefaultWorkers number of images pulled and extracted in parallel
+const DefaultWorkers = 4
+
 // Scanner represents a utility for scanning Docker registries
 type Scanner struct {
 	authConfiguration authConfigurations
+	backend           string
+	workers           int
 	cli               *client.Client // docker client
-	cache             map[string]image.Info
+	store             *Cache         // analysis results persisted across sessions
+
+	// platform analyzed, linux on the local arch by default
+	platform *v1.Platform
+
+	mu        sync.Mutex
+	cache     map[string]image.Info
+	platforms map[string][]string
 }
 
 // authConfigurations contains the configuration information's
 type authConfigurations struct {
 	configPath string // stores path of docker config.json
 	authCreds  []string
+	auths      map[string]authn.AuthConfig // registry credentials by host
 }
 
 func getAuthStr(u, p string) string {
@@ -76,40 +103,113 @@ func (r *Scanner) loadDockerAuthConfigs() {
 			return
 		}
 
-		for _, conf := range confsWrapper.Auths {
+		r.authConfiguration.auths = map[string]authn.AuthConfig{}
+		for host, conf := range confsWrapper.Auths {
 			data, _ := base64.StdEncoding.DecodeString(conf.Auth)
 			userPass := strings.SplitN(string(data), ":", 2)
+			if len(userPass) != 2 {
+				continue
+			}
 			r.authConfiguration.authCreds = append(r.authConfiguration.authCreds, getAuthStr(userPass[0], userPass[1]))
+			r.authConfiguration.auths[registryHost(host)] = authn.AuthConfig{
+				Username: userPass[0],
+				Password: userPass[1],
+			}
 		}
 	}
 }
 
 // New creates and initializes a new instance of the Scanner
-func New(dockerConfigPath string) *Scanner {
+func New(o common.Options) *Scanner {
 	var err error
-	scanner := Scanner{
+	backend := o.Backend
+	scanner := &Scanner{
 		authConfiguration: authConfigurations{
-			configPath: dockerConfigPath,
+			configPath: o.Config,
 		},
-		cache: make(map[string]image.Info),
+		backend:   backend,
+		workers:   o.Workers,
+		cache:     make(map[string]image.Info),
+		platforms: make(map[string][]string),
 	}
+	if o.Platform != "" {
+		if scanner.platform, err = v1.ParsePlatform(o.Platform); err != nil {
+			log.WithError(err).Fatal("invalid platform")
+		}
+	}
+	if !o.NoCache {
+		scanner.store = NewCache(DefaultCacheDir(), o.CacheMaxSize)
+	}
+	scanner.loadDockerAuthConfigs()
 
-	if err != nil {
-		log.WithError(err).Error("could not create temp dir")
+	switch backend {
+	case BackendRegistry:
+		return scanner
+	case BackendDocker, BackendAuto, "":
+	default:
+		log.WithFields(log.Fields{
+			"backend": backend,
+		}).Fatal("unknown image backend, supported backends are auto, docker and registry")
 	}
 
 	scanner.cli, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err == nil && backend != BackendDocker {
+		_, err = scanner.cli.Ping(context.Background())
+	}
 	if err != nil {
-		log.WithError(err).Fatal("could not create new docker client")
+		if backend == BackendDocker {
+			log.WithError(err).Fatal("could not create new docker client")
+		}
+		log.WithError(err).Info("docker daemon not available, fetching images from the registry")
+		scanner.backend = BackendRegistry
+		return scanner
 	}
-	scanner.loadDockerAuthConfigs()
+	scanner.backend = BackendDocker
+
+	return scanner
+}
 
-	return &scanner
+// Prefetch analyzes the images with a bounded pool of workers, so that
+// subsequent calls to Analyze are served from the session cache
+func (r *Scanner) Prefetch(imgs []image.Info) {
+	if r.workers <= 1 {
+		return
+	}
+	jobs := make(chan image.Info)
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for img := range jobs {
+				img := img
+				r.Analyze(&img)
+			}
+		}()
+	}
+	seen := map[string]bool{}
+	for _, img := range imgs {
+		key := img.Name + "|" + img.Platform
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		jobs <- img
+	}
+	close(jobs)
+	wg.Wait()
 }
 
 // Analyze performs analysis and caching of image information using the Scanner
 func (r *Scanner) Analyze(img *image.Info) {
-	if val, ok := r.cache[img.Name]; ok {
+	r.mu.Lock()
+	key := img.Name
+	if img.Platform != "" {
+		key += "|" + img.Platform
+	}
+	val, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
 		log.WithFields(log.Fields{
 			"image": img.Name,
 		}).Infof("Image already scanned in this session, using cached informations for image")
@@ -117,9 +217,11 @@ func (r *Scanner) Analyze(img *image.Info) {
 		img.DirList = val.DirList
 		img.FileList = val.FileList
 		img.Distro = val.Distro
-		img.Labels = val.Labels
 		img.OS = val.OS
 		img.RepoTags = val.RepoTags
+		img.Config = val.Config
+		img.Packages = val.Packages
+		img.RiskyFiles = val.RiskyFiles
 		return
 	}
 	tmpDir, err := os.MkdirTemp("", karmorTempDirPattern)
@@ -133,18 +235,83 @@ func (r *Scanner) Analyze(img *image.Info) {
 		}
 	}()
 	img.TempDir = tmpDir
-	err = r.pullImage(img.Name)
+	if IsRootfs(img.Name) {
+		err = r.analyzeRootfs(img)
+	} else if IsSBOM(img.Name) {
+		err = r.analyzeSBOM(img)
+	} else if isLocalImage(img.Name) || r.backend == BackendRegistry || img.Platform != "" || r.platform != nil {
+		// the daemon keeps a single platform per tag, other platforms come from the registry
+		err = r.analyzeImage(img)
+	} else {
+		err = r.analyzeWithDaemon(img)
+	}
 	if err != nil {
-		log.Warn("Failed to pull image. Dumping generic policies.")
+		log.WithError(err).Warn("Failed to pull image. Dumping generic policies.")
 		img.OS = "linux"
 		img.RepoTags = append(img.RepoTags, img.Name)
-	} else {
-		tarname := saveImageToTar(img.Name, r.cli, tmpDir)
-		img.FileList, img.DirList = extractTar(tarname, tmpDir)
-		img.GetImageInfo()
 	}
 
-	r.cache[img.Name] = *img
+	r.mu.Lock()
+	r.cache[key] = *img
+	r.mu.Unlock()
+}
+
+// imageID resolves the image ID, the digest of the image config, without
+// pulling the image: from the registry manifest, or the local image if the
+// registry is not reachable
+func (r *Scanner) imageID(img *image.Info) string {
+	if oci, _, err := r.fetchImage(img.Name, r.platformFor(img)); err == nil {
+		if digest, err := oci.ConfigName(); err == nil {
+			return digest.String()
+		}
+	}
+	if inspect, _, err := r.cli.ImageInspectWithRaw(context.Background(), img.Name); err == nil {
+		return inspect.ID
+	}
+	return ""
+}
+
+// analyzeWithDaemon pulls and saves the image through the docker daemon,
+// unless its analysis is cached already
+func (r *Scanner) analyzeWithDaemon(img *image.Info) error {
+	if r.store != nil && r.store.Get(r.imageID(img), img) {
+		return nil
+	}
+	if err := r.pullImage(img.Name); err != nil {
+		return err
+	}
+	digest := ""
+	if inspect, _, err := r.cli.ImageInspectWithRaw(context.Background(), img.Name); err == nil {
+		digest = inspect.ID
+	}
+
+	tarname := saveImageToTar(img.Name, r.cli, img.TempDir)
+	attrs := fileAttrs{}
+	img.FileList, img.DirList = extractTar(tarname, img.TempDir, attrs)
+	img.RiskyFiles = attrs.list(img.TempDir)
+	img.GetImageInfo()
+	img.ReadPackages()
+	trimRootfs(img)
+	if err := r.store.Put(digest, img); err != nil {
+		log.WithError(err).Warn("failed to cache image analysis")
+	}
+	return nil
+}
+
+// trimRootfs makes the file lists relative to the extracted rootfs,
+// they outlive the temporary directory when cached
+func trimRootfs(img *image.Info) {
+	root := filepath.Clean(img.TempDir)
+	if root == "/" {
+		// analyzed in place, the paths are already absolute
+		return
+	}
+	for i, f := range img.FileList {
+		img.FileList[i] = strings.TrimPrefix(f, root)
+	}
+	for i, d := range img.DirList {
+		img.DirList[i] = strings.TrimPrefix(d, root)
+	}
 }
 
 // The randomizer used in this function is not used for any cryptographic
@@ -183,6 +350,10 @@ func (r *Scanner) pullImage(imageName string) (err error) {
 		}
 	}()
 	termFd, isTerm := term.GetFdInfo(os.Stderr)
+	if r.workers > 1 {
+		// progress bars of parallel pulls would overwrite each other
+		isTerm = false
+	}
 	err = jsonmessage.DisplayJSONMessagesStream(out, os.Stderr, termFd, isTerm, nil)
 	if err != nil {
 		log.WithError(err).Error("could not display json")
@@ -201,7 +372,7 @@ func sanitizeArchivePath(d, t string) (v string, err error) {
 	return "", fmt.Errorf("%s: %s", "content filepath is tainted", t)
 }
 
-func extractTar(tarname string, tempDir string) ([]string, []string) {
+func extractTar(tarname string, tempDir string, attrs fileAttrs) ([]string, []string) {
 	var fl []string
 	var dl []string
 
@@ -231,6 +402,7 @@ func extractTar(tarname string, tempDir string) ([]string, []string) {
 			continue
 		}
 
+		attrs.record(hdr, strings.TrimPrefix(tgt, filepath.Clean(tempDir)))
 		switch hdr.Typeflag {
 		case tar.TypeDir:
 			if _, err := os.Stat(tgt); err != nil {
@@ -258,7 +430,7 @@ func extractTar(tarname string, tempDir string) ([]string, []string) {
 			}
 			hacks.CloseCheckErr(f, tgt)
 			if strings.HasSuffix(tgt, "layer.tar") { // deflate container image layer
-				ifl, idl := extractTar(tgt, tempDir)
+				ifl, idl := extractTar(tgt, tempDir, attrs)
 				fl = append(fl, ifl...)
 				dl = append(dl, idl...)
 			} else {
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+	log "github.com/sirupsen/logrus"
+)
+
+// RootfsPrefix rootfs:<dir|tarball>, a node root filesystem analyzed like an image
+const RootfsPrefix = "rootfs:"
+
+// pseudo filesystems and runtime state not worth listing on a live node
+var skipHostDirs = map[string]bool{
+	"/proc": true,
+	"/sys":  true,
+	"/dev":  true,
+	"/run":  true,
+}
+
+// IsRootfs checks whether the image name refers to a node root filesystem
+func IsRootfs(name string) bool {
+	return strings.HasPrefix(name, RootfsPrefix)
+}
+
+// rootfsName names the policies of a node root filesystem after its path
+func rootfsName(path string) string {
+	base := filepath.Base(filepath.Clean(path))
+	for _, ext := range []string{".gz", ".tgz", ".tar"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	if base == "" || base == "/" || base == "." {
+		return "host"
+	}
+	return base
+}
+
+// walkRootfs lists the files, directories and risky files of a root filesystem
+// in place. Ownership is read from the file system, capabilities are not.
+func walkRootfs(root string) ([]string, []string, []image.FileAttr, error) {
+	var fl, dl []string
+	var risky []image.FileAttr
+	root = filepath.Clean(root)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				log.WithField("path", path).Debug("skipping unreadable path")
+				return nil
+			}
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel := "/" + strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
+		if info.IsDir() {
+			if skipHostDirs[rel] {
+				return filepath.SkipDir
+			}
+			dl = append(dl, path)
+		} else {
+			fl = append(fl, path)
+		}
+		if hdr, err := tar.FileInfoHeader(info, ""); err == nil {
+			if attr, ok := image.FileAttrOf(hdr); ok {
+				attr.Path = rel
+				risky = append(risky, attr)
+			}
+		}
+		return nil
+	})
+	return fl, dl, risky, err
+}
+
+// extractRootfs extracts a (gzipped) tarball of a root filesystem into dir
+func extractRootfs(tarball, dir string, attrs fileAttrs) error {
+	f, err := os.Open(filepath.Clean(tarball))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.WithError(err).Warn("failed to close rootfs tarball")
+		}
+	}()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		r = gz
+	}
+	return applyLayer(r, dir, attrs)
+}
+
+// analyzeRootfs analyzes a node root filesystem, either a directory such as
+// the root of a mounted node disk or a tarball of it
+func (r *Scanner) analyzeRootfs(img *image.Info) error {
+	path := strings.TrimPrefix(img.Name, RootfsPrefix)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmpDir := img.TempDir
+	if info.IsDir() {
+		img.TempDir = filepath.Clean(path)
+		defer func() { img.TempDir = tmpDir }()
+		if img.FileList, img.DirList, img.RiskyFiles, err = walkRootfs(img.TempDir); err != nil {
+			return err
+		}
+	} else {
+		attrs := fileAttrs{}
+		if err := extractRootfs(path, tmpDir, attrs); err != nil {
+			return fmt.Errorf("rootfs %s: %w", path, err)
+		}
+		if img.FileList, img.DirList, err = listRootfs(tmpDir); err != nil {
+			return err
+		}
+		img.RiskyFiles = attrs.list(tmpDir)
+	}
+
+	img.OS = "linux"
+	img.RepoTags = []string{rootfsName(path)}
+	img.GetDistro()
+	img.ReadPackages()
+	trimRootfs(img)
+	return nil
+}
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+func writeRootfsTarball(t *testing.T, file string) {
+	f, err := os.Create(filepath.Clean(file))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range []*tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "usr/bin/su", Typeflag: tar.TypeReg, Mode: 04755},
+		{Name: "etc/kubernetes/kubelet.conf", Typeflag: tar.TypeReg, Mode: 0600},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for _, c := range []interface{ Close() error }{tw, gz, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func TestAnalyzeRootfs(t *testing.T) {
+	s := &Scanner{cache: map[string]image.Info{}}
+
+	tarball := filepath.Join(t.TempDir(), "node-1.tar.gz")
+	writeRootfsTarball(t, tarball)
+	img := &image.Info{Name: RootfsPrefix + tarball}
+	s.Analyze(img)
+	if img.OS != "linux" || len(img.RepoTags) != 1 || img.RepoTags[0] != "node-1" {
+		t.Errorf("unexpected rootfs info %+v", img)
+	}
+	if len(img.FileList) != 3 || img.FileList[0] != "/etc/kubernetes/kubelet.conf" {
+		t.Errorf("unexpected files %v", img.FileList)
+	}
+	if len(img.RiskyFiles) != 1 || !img.RiskyFiles[0].Setuid() || img.RiskyFiles[0].Path != "/usr/bin/su" {
+		t.Errorf("unexpected risky files %v", img.RiskyFiles)
+	}
+
+	dir := t.TempDir()
+	for _, d := range []string{"etc/kubernetes", "proc/1", "usr/bin"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0750); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for _, f := range []string{"etc/kubernetes/kubelet.conf", "proc/1/status", "usr/bin/su"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "usr/bin/su"), 0755|os.ModeSetuid); err != nil {
+		t.Fatal(err.Error())
+	}
+	img = &image.Info{Name: RootfsPrefix + dir, TempDir: t.TempDir()}
+	if err := s.analyzeRootfs(img); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(img.FileList) != 2 || img.FileList[0] != "/etc/kubernetes/kubelet.conf" || img.FileList[1] != "/usr/bin/su" {
+		t.Errorf("unexpected files %v, /proc should be skipped", img.FileList)
+	}
+	if len(img.RiskyFiles) != 1 || img.RiskyFiles[0].Path != "/usr/bin/su" {
+		t.Errorf("unexpected risky files %v", img.RiskyFiles)
+	}
+	if img.TempDir == dir {
+		t.Error("the rootfs directory must not be used as temporary directory")
+	}
+}
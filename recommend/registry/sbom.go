@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+	log "github.com/sirupsen/logrus"
+)
+
+// SBOMPrefix sbom:<file>, an SPDX or CycloneDX JSON SBOM analyzed instead of the image
+const SBOMPrefix = "sbom:"
+
+// package managers of the purl types of OS packages
+var purlManagers = map[string]string{
+	"deb": image.ManagerDpkg,
+	"apk": image.ManagerApk,
+	"rpm": image.ManagerRpm,
+}
+
+// IsSBOM checks whether the image name refers to an SBOM
+func IsSBOM(name string) bool {
+	return strings.HasPrefix(name, SBOMPrefix)
+}
+
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Name        string `json:"name"`
+	Packages    []struct {
+		Name           string `json:"name"`
+		VersionInfo    string `json:"versionInfo"`
+		PrimaryPurpose string `json:"primaryPackagePurpose"`
+		ExternalRefs   []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+	Files []struct {
+		FileName string `json:"fileName"`
+	} `json:"files"`
+}
+
+type cdxComponent struct {
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version"`
+	PURL       string         `json:"purl"`
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxDocument struct {
+	BOMFormat string `json:"bomFormat"`
+	Metadata  struct {
+		Component cdxComponent `json:"component"`
+	} `json:"metadata"`
+	Components []cdxComponent `json:"components"`
+}
+
+// purl fields of a package url, pkg:type/namespace/name@version?qualifiers
+type purl struct {
+	typ        string
+	namespace  string
+	qualifiers url.Values
+}
+
+func parsePURL(s string) (purl, bool) {
+	rest, ok := strings.CutPrefix(s, "pkg:")
+	if !ok {
+		return purl{}, false
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, query, _ := strings.Cut(rest, "?")
+	p := purl{}
+	p.qualifiers, _ = url.ParseQuery(query)
+	parts := strings.Split(rest, "/")
+	p.typ = strings.ToLower(parts[0])
+	if len(parts) > 2 {
+		p.namespace = strings.ToLower(parts[1])
+	}
+	return p, true
+}
+
+// sbomInfo image information found in an SBOM
+type sbomInfo struct {
+	name     string
+	distro   string
+	arch     string
+	files    []string
+	packages []image.Package
+}
+
+// addPackage records an OS package, the distro and arch are taken from its
+// purl when the SBOM does not name the operating system
+func (s *sbomInfo) addPackage(name, version, purlStr string) {
+	p, ok := parsePURL(purlStr)
+	if !ok {
+		return
+	}
+	manager, ok := purlManagers[p.typ]
+	if !ok {
+		// language packages are not installed by a package manager
+		return
+	}
+	s.packages = append(s.packages, image.Package{Name: name, Version: version, Manager: manager})
+	if s.distro == "" && p.namespace != "" {
+		s.distro = p.namespace
+	}
+	if s.arch == "" {
+		s.arch = p.qualifiers.Get("arch")
+	}
+}
+
+func (s *sbomInfo) addFile(name string) {
+	if name == "" {
+		return
+	}
+	s.files = append(s.files, path.Clean("/"+strings.TrimPrefix(name, "./")))
+}
+
+func (s *sbomInfo) addComponents(components []cdxComponent) {
+	for _, c := range components {
+		switch c.Type {
+		case "operating-system":
+			s.distro = strings.ToLower(c.Name)
+		case "file":
+			s.addFile(c.Name)
+		default:
+			s.addPackage(c.Name, c.Version, c.PURL)
+		}
+		s.addComponents(c.Components)
+	}
+}
+
+// parseSBOM reads an SPDX or CycloneDX JSON document
+func parseSBOM(data []byte) (*sbomInfo, error) {
+	var spdx spdxDocument
+	if err := json.Unmarshal(data, &spdx); err == nil && spdx.SPDXVersion != "" {
+		s := &sbomInfo{name: spdx.Name}
+		for _, p := range spdx.Packages {
+			if p.PrimaryPurpose == "OPERATING-SYSTEM" {
+				s.distro = strings.ToLower(p.Name)
+				continue
+			}
+			for _, ref := range p.ExternalRefs {
+				if ref.ReferenceType == "purl" {
+					s.addPackage(p.Name, p.VersionInfo, ref.ReferenceLocator)
+				}
+			}
+		}
+		for _, f := range spdx.Files {
+			s.addFile(f.FileName)
+		}
+		return s, nil
+	}
+
+	var cdx cdxDocument
+	if err := json.Unmarshal(data, &cdx); err == nil && cdx.BOMFormat == "CycloneDX" {
+		s := &sbomInfo{name: cdx.Metadata.Component.Name}
+		s.addComponents(cdx.Components)
+		return s, nil
+	}
+	return nil, errors.New("not an SPDX or CycloneDX JSON document")
+}
+
+// parentDirs lists the directories containing the files
+func parentDirs(files []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range files {
+		for d := path.Dir(f); d != "/" && d != "." && !seen[d]; d = path.Dir(d) {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// analyzeSBOM populates the image information from an SBOM, without pulling
+// the image. The file list is only as complete as the SBOM.
+func (r *Scanner) analyzeSBOM(img *image.Info) error {
+	fname := strings.TrimPrefix(img.Name, SBOMPrefix)
+	data, err := os.ReadFile(filepath.Clean(fname))
+	if err != nil {
+		return err
+	}
+	s, err := parseSBOM(data)
+	if err != nil {
+		return err
+	}
+	if s.name == "" {
+		s.name = strings.TrimSuffix(filepath.Base(fname), filepath.Ext(fname))
+	}
+	sort.Strings(s.files)
+	sort.Slice(s.packages, func(i, j int) bool {
+		if s.packages[i].Name != s.packages[j].Name {
+			return s.packages[i].Name < s.packages[j].Name
+		}
+		return s.packages[i].Manager < s.packages[j].Manager
+	})
+
+	img.OS = "linux"
+	img.Arch = s.arch
+	img.RepoTags = []string{s.name}
+	img.FileList = s.files
+	img.DirList = parentDirs(s.files)
+	img.Packages = s.packages
+	img.Distro = s.distro
+	if img.Distro == "" {
+		// the file list is not extracted anywhere, match the distro rules on the paths alone
+		tmpDir := img.TempDir
+		img.TempDir = ""
+		img.GetDistro()
+		img.TempDir = tmpDir
+	}
+	if len(s.files) == 0 {
+		log.WithField("sbom", fname).Warn("the SBOM lists no files, only package preconditions can be evaluated")
+	}
+	return nil
+}
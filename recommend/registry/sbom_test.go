@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	image "github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+const spdxSBOM = `{
+  "spdxVersion": "SPDX-2.3",
+  "name": "nginx:1.25",
+  "packages": [
+    {"name": "debian", "versionInfo": "12", "primaryPackagePurpose": "OPERATING-SYSTEM"},
+    {"name": "bash", "versionInfo": "5.2.15-2", "externalRefs": [
+      {"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:deb/debian/bash@5.2.15-2?arch=amd64&distro=debian-12"}
+    ]},
+    {"name": "requests", "versionInfo": "2.31.0", "externalRefs": [
+      {"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:pypi/requests@2.31.0"}
+    ]}
+  ],
+  "files": [
+    {"fileName": "/usr/bin/bash"},
+    {"fileName": "./usr/sbin/nginx"}
+  ]
+}`
+
+const cycloneDXSBOM = `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.5",
+  "metadata": {"component": {"type": "container", "name": "alpine:3.18"}},
+  "components": [
+    {"type": "library", "name": "apk-tools", "version": "2.14.0-r2", "purl": "pkg:apk/alpine/apk-tools@2.14.0-r2?arch=aarch64"},
+    {"type": "file", "name": "/sbin/apk"},
+    {"type": "application", "name": "busybox", "components": [
+      {"type": "library", "name": "busybox", "version": "1.36.1-r2", "purl": "pkg:apk/alpine/busybox@1.36.1-r2"}
+    ]}
+  ]
+}`
+
+func analyzeTestSBOM(t *testing.T, name, sbom string) image.Info {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(sbom), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	s := &Scanner{cache: map[string]image.Info{}}
+	img := image.Info{Name: SBOMPrefix + file}
+	s.Analyze(&img)
+	return img
+}
+
+func TestAnalyzeSBOM(t *testing.T) {
+	img := analyzeTestSBOM(t, "nginx.spdx.json", spdxSBOM)
+	if img.RepoTags[0] != "nginx:1.25" || img.Distro != "debian" || img.Arch != "amd64" {
+		t.Errorf("unexpected spdx image %+v", img)
+	}
+	if len(img.FileList) != 2 || img.FileList[1] != "/usr/sbin/nginx" {
+		t.Errorf("unexpected files %v", img.FileList)
+	}
+	if len(img.DirList) != 3 || img.DirList[0] != "/usr" {
+		t.Errorf("unexpected dirs %v", img.DirList)
+	}
+	if len(img.Packages) != 1 || img.Packages[0] != (image.Package{Name: "bash", Version: "5.2.15-2", Manager: image.ManagerDpkg}) {
+		t.Errorf("unexpected packages %+v", img.Packages)
+	}
+
+	img = analyzeTestSBOM(t, "alpine.cdx.json", cycloneDXSBOM)
+	if img.RepoTags[0] != "alpine:3.18" || img.Distro != "alpine" || img.Arch != "aarch64" || len(img.FileList) != 1 {
+		t.Errorf("unexpected cyclonedx image %+v", img)
+	}
+	if len(img.Packages) != 2 || img.Packages[1].Name != "busybox" || img.Packages[1].Manager != image.ManagerApk {
+		t.Errorf("unexpected packages %+v", img.Packages)
+	}
+
+	if _, err := parseSBOM([]byte(`{"kind": "Deployment"}`)); err == nil {
+		t.Error("expected an error for a non SBOM document")
+	}
+}
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package report
+
+import (
+	_ "embed" // need for embedding
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Coverage states of a compliance control for a workload
+const (
+	CoverageRecommended = "recommended"
+	CoverageApplied     = "applied"
+)
+
+// compliance frameworks recognized in the policy tags
+var frameworks = []string{"MITRE", "NIST", "PCI-DSS", "CIS", "STIG"}
+
+// file extensions of the coverage report formats
+var coverageExtensions = map[string]string{
+	".html": FormatHTML,
+	".htm":  FormatHTML,
+	".csv":  "csv",
+	".json": FormatJSON,
+}
+
+//go:embed html/coverage.html
+var coverageHTML string
+
+// TagMatches checks whether the policy tag is the control or one of its
+// sub-controls, such as MITRE_T1610_deploy_container for MITRE. Tags are
+// compared ignoring case and "_" / "-" differences.
+func TagMatches(tag, control string) bool {
+	norm := func(s string) string { return strings.ToUpper(strings.ReplaceAll(s, "_", "-")) }
+	t, c := norm(tag), norm(control)
+	return t == c || strings.HasPrefix(t, c+"-")
+}
+
+// Framework returns the compliance framework of a policy tag, such as MITRE
+// for MITRE_T1610_deploy_container, or "" if the tag is not a control
+func Framework(tag string) string {
+	for _, f := range frameworks {
+		if TagMatches(tag, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// CoverageFormatOf returns the format of the coverage report file
+func CoverageFormatOf(fname string) (string, error) {
+	if f, ok := coverageExtensions[strings.ToLower(filepath.Ext(fname))]; ok {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown coverage report format of %s, use a .html, .csv or .json file", fname)
+}
+
+type workloadKey struct {
+	namespace string
+	name      string
+}
+
+// Coverage compliance controls covered by the policies of each workload
+type Coverage struct {
+	workloads map[workloadKey]map[string]string
+}
+
+// NewCoverage returns an empty coverage matrix
+func NewCoverage() *Coverage {
+	return &Coverage{workloads: map[workloadKey]map[string]string{}}
+}
+
+// Add records the controls covered by a policy of the workload. Applied
+// policies take precedence over recommended ones. Tags that are not
+// compliance controls are ignored, but the workload is listed anyway.
+func (c *Coverage) Add(namespace, workload string, tags []string, state string) {
+	key := workloadKey{namespace, workload}
+	controls, ok := c.workloads[key]
+	if !ok {
+		controls = map[string]string{}
+		c.workloads[key] = controls
+	}
+	for _, tag := range tags {
+		if Framework(tag) == "" || controls[tag] == CoverageApplied {
+			continue
+		}
+		controls[tag] = state
+	}
+}
+
+// CoverageControl column of the coverage matrix
+type CoverageControl struct {
+	Framework string `json:"framework"`
+	Control   string `json:"control"`
+}
+
+// WorkloadCoverage row of the coverage matrix, controls map to their coverage state
+type WorkloadCoverage struct {
+	Namespace string            `json:"namespace"`
+	Workload  string            `json:"workload"`
+	Controls  map[string]string `json:"controls"`
+}
+
+// CoverageTotal covered cells of a framework or namespace
+type CoverageTotal struct {
+	Name        string `json:"name"`
+	Recommended int    `json:"recommended"`
+	Applied     int    `json:"applied"`
+	Cells       int    `json:"cells"`
+}
+
+// Covered number of cells covered by recommended or applied policies
+func (t CoverageTotal) Covered() int {
+	return t.Recommended + t.Applied
+}
+
+// Percent covered cells in percent
+func (t CoverageTotal) Percent() int {
+	if t.Cells == 0 {
+		return 0
+	}
+	return t.Covered() * 100 / t.Cells
+}
+
+// CoverageMatrix workloads × compliance controls with the totals per framework and namespace
+type CoverageMatrix struct {
+	Generated  string             `json:"generated"`
+	Controls   []CoverageControl  `json:"controls"`
+	Workloads  []WorkloadCoverage `json:"workloads"`
+	Frameworks []CoverageTotal    `json:"frameworks"`
+	Namespaces []CoverageTotal    `json:"namespaces"`
+}
+
+// Matrix computes the coverage matrix, the controls are the ones covered for
+// any of the workloads
+func (c *Coverage) Matrix() CoverageMatrix {
+	m := CoverageMatrix{Generated: time.Now().Format(time.RFC3339)}
+	seen := map[string]bool{}
+	for key, controls := range c.workloads {
+		for control := range controls {
+			if !seen[control] {
+				seen[control] = true
+				m.Controls = append(m.Controls, CoverageControl{Framework: Framework(control), Control: control})
+			}
+		}
+		m.Workloads = append(m.Workloads, WorkloadCoverage{Namespace: key.namespace, Workload: key.name, Controls: controls})
+	}
+	sort.Slice(m.Controls, func(i, j int) bool {
+		if m.Controls[i].Framework != m.Controls[j].Framework {
+			return m.Controls[i].Framework < m.Controls[j].Framework
+		}
+		return m.Controls[i].Control < m.Controls[j].Control
+	})
+	sort.Slice(m.Workloads, func(i, j int) bool {
+		if m.Workloads[i].Namespace != m.Workloads[j].Namespace {
+			return m.Workloads[i].Namespace < m.Workloads[j].Namespace
+		}
+		return m.Workloads[i].Workload < m.Workloads[j].Workload
+	})
+
+	fwTotals := map[string]*CoverageTotal{}
+	nsTotals := map[string]*CoverageTotal{}
+	total := func(totals map[string]*CoverageTotal, name string) *CoverageTotal {
+		if t, ok := totals[name]; ok {
+			return t
+		}
+		totals[name] = &CoverageTotal{Name: name}
+		return totals[name]
+	}
+	for _, w := range m.Workloads {
+		for _, ctl := range m.Controls {
+			for _, t := range []*CoverageTotal{total(fwTotals, ctl.Framework), total(nsTotals, w.Namespace)} {
+				t.Cells++
+				switch w.Controls[ctl.Control] {
+				case CoverageApplied:
+					t.Applied++
+				case CoverageRecommended:
+					t.Recommended++
+				}
+			}
+		}
+	}
+	m.Frameworks = sortedTotals(fwTotals)
+	m.Namespaces = sortedTotals(nsTotals)
+	return m
+}
+
+func sortedTotals(totals map[string]*CoverageTotal) []CoverageTotal {
+	var list []CoverageTotal
+	for _, t := range totals {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Write renders the coverage matrix in the format given by the file extension
+func (c *Coverage) Write(out string) error {
+	format, err := CoverageFormatOf(out)
+	if err != nil {
+		return err
+	}
+	m := c.Matrix()
+	f, err := os.Create(filepath.Clean(out))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	case FormatHTML:
+		tmpl, err := template.New("coveragetmpl").Parse(coverageHTML)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(f, m)
+	}
+
+	// csv holds the matrix only, one row per workload, for spreadsheets
+	w := csv.NewWriter(f)
+	header := []string{"namespace", "workload"}
+	for _, ctl := range m.Controls {
+		header = append(header, ctl.Control)
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, wl := range m.Workloads {
+		row := []string{wl.Namespace, wl.Workload}
+		for _, ctl := range m.Controls {
+			row = append(row, wl.Controls[ctl.Control])
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFramework(t *testing.T) {
+	for tag, want := range map[string]string{
+		"MITRE":                        "MITRE",
+		"MITRE_T1610_deploy_container": "MITRE",
+		"NIST_800-53_CM-7(4)":          "NIST",
+		"NIST-800-CA-3":                "NIST",
+		"PCI_DSS":                      "PCI-DSS",
+		"CIS_Kubernetes":               "CIS",
+		"NETWORK":                      "",
+		"CISCO":                        "",
+	} {
+		if got := Framework(tag); got != want {
+			t.Errorf("%s: expected %q, got %q", tag, want, got)
+		}
+	}
+}
+
+func TestTagMatches(t *testing.T) {
+	for _, tc := range []struct {
+		tag, control string
+		want         bool
+	}{
+		{"MITRE", "MITRE", true},
+		{"MITRE_T1610_deploy_container", "mitre", true},
+		{"PCI_DSS", "PCI-DSS", true},
+		{"NIST", "NIST_800-53", false},
+		{"CISCO", "CIS", false},
+	} {
+		if got := TagMatches(tc.tag, tc.control); got != tc.want {
+			t.Errorf("%s for %s: expected %v", tc.tag, tc.control, tc.want)
+		}
+	}
+}
+
+func testCoverage() *Coverage {
+	c := NewCoverage()
+	c.Add("wordpress-mysql", "wordpress", []string{"MITRE", "NIST", "NETWORK"}, CoverageRecommended)
+	c.Add("wordpress-mysql", "wordpress", []string{"MITRE"}, CoverageApplied)
+	c.Add("wordpress-mysql", "wordpress", []string{"MITRE"}, CoverageRecommended)
+	c.Add("wordpress-mysql", "mysql", []string{"PCI-DSS"}, CoverageRecommended)
+	c.Add("default", "nginx", nil, "")
+	return c
+}
+
+func TestCoverageMatrix(t *testing.T) {
+	m := testCoverage().Matrix()
+	if len(m.Controls) != 3 || m.Controls[0].Control != "MITRE" || m.Controls[2].Framework != "PCI-DSS" {
+		t.Errorf("unexpected controls %+v", m.Controls)
+	}
+	if len(m.Workloads) != 3 || m.Workloads[0].Workload != "nginx" {
+		t.Fatalf("unexpected workloads %+v", m.Workloads)
+	}
+	if got := m.Workloads[2].Controls["MITRE"]; m.Workloads[2].Workload != "wordpress" || got != CoverageApplied {
+		t.Errorf("expected wordpress MITRE applied, got %q", got)
+	}
+	for _, tot := range m.Frameworks {
+		if tot.Name == "MITRE" && (tot.Applied != 1 || tot.Recommended != 0 || tot.Cells != 3) {
+			t.Errorf("unexpected MITRE totals %+v", tot)
+		}
+	}
+	if len(m.Namespaces) != 2 || m.Namespaces[1].Covered() != 3 || m.Namespaces[1].Cells != 6 || m.Namespaces[1].Percent() != 50 {
+		t.Errorf("unexpected namespace totals %+v", m.Namespaces)
+	}
+}
+
+func TestCoverageWrite(t *testing.T) {
+	dir := t.TempDir()
+	c := testCoverage()
+
+	if err := c.Write(filepath.Join(dir, "coverage.csv")); err != nil {
+		t.Fatal(err.Error())
+	}
+	f, err := os.Open(filepath.Join(dir, "coverage.csv"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != "namespace,workload,MITRE,NIST,PCI-DSS" ||
+		strings.Join(rows[3], ",") != "wordpress-mysql,wordpress,applied,recommended," {
+		t.Errorf("unexpected csv %v", rows)
+	}
+
+	if err := c.Write(filepath.Join(dir, "coverage.json")); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "coverage.json"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var m CoverageMatrix
+	if err := json.Unmarshal(data, &m); err != nil || len(m.Frameworks) != 3 {
+		t.Errorf("unexpected json %s (%v)", data, err)
+	}
+
+	if err := c.Write(filepath.Join(dir, "coverage.html")); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "coverage.html"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(data), `<td class="applied">applied</td>`) || !strings.Contains(string(data), "50%") {
+		t.Errorf("unexpected html\n%s", data)
+	}
+
+	if err := c.Write(filepath.Join(dir, "coverage.txt")); err == nil {
+		t.Error("expected unknown format error")
+	}
+}
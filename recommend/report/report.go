@@ -5,6 +5,9 @@ package report
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/kubearmor/kubearmor-client/recommend/common"
@@ -23,62 +26,211 @@ for every image {
 Render()
 */
 
-// Handler interface
-var Handler interface{}
+// Reporter renders the recommendation report in a specific format
+type Reporter interface {
+	// Start called once per container image at the start
+	Start(img *image.Info, outDir string, currentVersion string) error
+	// Record called once per policy
+	Record(ms common.MatchSpec, policyName string) error
+	// SectionEnd called once per container image at the end
+	SectionEnd() error
+	// Render writes the report to out
+	Render(out string) error
+}
+
+// Report formats
+const (
+	FormatText     = "text"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+)
+
+var formats = map[string]func() Reporter{}
+
+// file extensions used to pick the format when none is given
+var extensions = map[string]string{
+	".html":  FormatHTML,
+	".htm":   FormatHTML,
+	".json":  FormatJSON,
+	".md":    FormatMarkdown,
+	".sarif": FormatSARIF,
+	".xml":   FormatJUnit,
+}
+
+// Register adds a report format
+func Register(format string, newReporter func() Reporter) {
+	formats[format] = newReporter
+}
+
+// Formats lists the registered report formats
+func Formats() []string {
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(FormatText, func() Reporter { return NewTextReport() })
+	Register(FormatHTML, func() Reporter { return NewHTMLReport() })
+	Register(FormatJSON, func() Reporter { return NewJSONReport() })
+	Register(FormatMarkdown, func() Reporter { return NewMarkdownReport() })
+	Register(FormatSARIF, func() Reporter { return NewSARIFReport() })
+	Register(FormatJUnit, func() Reporter { return NewJUnitReport() })
+}
+
+// Handler reporter of the current execution
+var Handler Reporter
+
+// format of the current Handler
+var handlerFormat string
+
+// options of the current image, for the effective actions of the recorded rules
+var actionOptions common.Options
+
+// FormatOf returns the report format to use for the report file, format takes precedence
+func FormatOf(fname, format string) (string, error) {
+	if format == "" {
+		format = FormatText
+		if f, ok := extensions[strings.ToLower(filepath.Ext(fname))]; ok {
+			format = f
+		}
+	}
+	if _, ok := formats[format]; !ok {
+		return "", fmt.Errorf("unknown report format %q, supported formats are %s", format, strings.Join(Formats(), ", "))
+	}
+	return format, nil
+}
 
 // Init called once per execution
-func Init(fname string) {
+func Init(fname, format string) error {
 	if Handler != nil {
-		return
+		return nil
 	}
-	if strings.Contains(fname, ".html") {
-		Handler = NewHTMLReport()
-	} else {
-		Handler = NewTextReport()
+	format, err := FormatOf(fname, format)
+	if err != nil {
+		return err
 	}
+	Handler = formats[format]()
+	handlerFormat = format
+	return nil
+}
+
+// Format returns the format of the report being generated
+func Format() string {
+	return handlerFormat
 }
 
 // Start called once per container image at the start
 func Start(img *image.Info, options common.Options, currentVersion string) error {
-	switch v := Handler.(type) {
-	case HTMLReport:
-		return v.Start(img, options.OutDir, currentVersion)
-	case TextReport:
-		return v.Start(img, options.OutDir, currentVersion)
+	if Handler == nil {
+		return errors.New("report not initialized")
 	}
-	return errors.New("unknown reporter type")
+	actionOptions = options
+	return Handler.Start(img, options.OutDir, currentVersion)
 }
 
 // Record called once per policy
 func Record(in interface{}, policyName string) error {
-	ms := in.(common.MatchSpec)
-	switch v := Handler.(type) {
-	case HTMLReport:
-		return v.Record(ms, policyName)
-	case TextReport:
-		return v.Record(ms, policyName)
+	if Handler == nil {
+		return errors.New("report not initialized")
+	}
+	ms, ok := in.(common.MatchSpec)
+	if !ok {
+		return fmt.Errorf("unexpected policy rule type %T", in)
 	}
-	return errors.New("unknown reporter type")
+	return Handler.Record(actionOptions.WithEffectiveAction(ms), policyName)
 }
 
 // SectEnd called once per container image at the end
 func SectEnd() error {
-	switch v := Handler.(type) {
-	case HTMLReport:
-		return v.SectionEnd()
-	case TextReport:
-		return v.SectionEnd()
+	if Handler == nil {
+		return errors.New("report not initialized")
 	}
-	return errors.New("unknown reporter type")
+	return Handler.SectionEnd()
 }
 
 // Render called finaly to render the report
 func Render(out string) error {
-	switch v := Handler.(type) {
-	case HTMLReport:
-		return v.Render(out)
-	case TextReport:
-		return v.Render(out)
+	if Handler == nil {
+		return errors.New("report not initialized")
+	}
+	return Handler.Render(out)
+}
+
+// deferredCall report call buffered until Replay
+type deferredCall struct {
+	img        *image.Info
+	outDir     string
+	version    string
+	ms         *common.MatchSpec
+	policyName string
+}
+
+// deferred buffers the report calls of the sections so that they can be
+// replayed once the recommended policies are reviewed
+type deferred struct {
+	target Reporter
+	calls  []deferredCall
+}
+
+func (d *deferred) Start(img *image.Info, outDir string, currentVersion string) error {
+	// the image info is reused by the caller for the next image
+	cp := *img
+	d.calls = append(d.calls, deferredCall{img: &cp, outDir: outDir, version: currentVersion})
+	return nil
+}
+
+func (d *deferred) Record(ms common.MatchSpec, policyName string) error {
+	d.calls = append(d.calls, deferredCall{ms: &ms, policyName: policyName})
+	return nil
+}
+
+func (d *deferred) SectionEnd() error {
+	d.calls = append(d.calls, deferredCall{})
+	return nil
+}
+
+func (d *deferred) Render(string) error {
+	return errors.New("report deferred until replayed")
+}
+
+// Defer buffers the report calls until Replay
+func Defer() {
+	if _, ok := Handler.(*deferred); Handler == nil || ok {
+		return
+	}
+	Handler = &deferred{target: Handler}
+}
+
+// Replay passes the buffered report calls to the reporter, keeping the
+// policies for which keep returns true. keep may edit the recorded rule.
+func Replay(keep func(policyName string, ms *common.MatchSpec) bool) error {
+	d, ok := Handler.(*deferred)
+	if !ok {
+		return nil
+	}
+	Handler = d.target
+	for _, c := range d.calls {
+		var err error
+		switch {
+		case c.img != nil:
+			err = Handler.Start(c.img, c.outDir, c.version)
+		case c.ms != nil:
+			if keep(c.policyName, c.ms) {
+				err = Handler.Record(*c.ms, c.policyName)
+			}
+		default:
+			err = Handler.SectionEnd()
+		}
+		if err != nil {
+			return err
+		}
 	}
-	return errors.New("unknown reporter type")
+	return nil
 }
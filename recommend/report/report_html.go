@@ -136,6 +136,20 @@ func (r HTMLReport) Start(img *image.Info, outDir string, currentVersion string)
 			{Key: "policy-template version", Val: currentVersion},
 		},
 	}
+	if len(img.Platforms) > 0 {
+		var platforms []string
+		for _, p := range img.Platforms {
+			platforms = append(platforms, p.String())
+		}
+		seci.ImgInfo = append(seci.ImgInfo, Info{Key: "Platforms", Val: strings.Join(platforms, ", ")})
+	}
+	if len(img.RiskyFiles) > 0 {
+		var risky []string
+		for _, f := range img.RiskyFiles {
+			risky = append(risky, f.String())
+		}
+		seci.ImgInfo = append(seci.ImgInfo, Info{Key: "Risky files", Val: strings.Join(risky, ", ")})
+	}
 	err := r.section.Execute(r.outString, seci)
 	if err != nil {
 		log.WithError(err)
@@ -151,6 +165,10 @@ type RecordInfo struct {
 	Description string
 	PolicyType  string
 	Refs        []common.Ref
+	// TemplateAction action of the template when overridden
+	TemplateAction  string
+	Evidence        []string
+	TemplateVersion string
 }
 
 // Record addition of new HTML table row
@@ -165,7 +183,7 @@ func (r HTMLReport) Record(ms common.MatchSpec, policyName string) error {
 		RowID: fmt.Sprintf("row%d", *r.RecordCnt),
 		Rec: []Col{
 			{Name: policyName},
-			{Name: ms.Description.Tldr},
+			{Name: describe(ms)},
 			{Name: fmt.Sprintf("%d", ms.Spec.Severity)},
 			{Name: string(ms.Spec.Action)},
 			{Name: strings.Join(ms.Spec.Tags[:], "\n")},
@@ -174,6 +192,10 @@ func (r HTMLReport) Record(ms common.MatchSpec, policyName string) error {
 		PolicyType:  "Kubearmor Security Policy",
 		Description: ms.Description.Detailed,
 		Refs:        ms.Description.Refs,
+
+		TemplateAction:  ms.TemplateAction,
+		Evidence:        describeEvidence(ms.Evidence),
+		TemplateVersion: ms.TemplateVersion,
 	}
 	err = r.record.Execute(r.outString, reci)
 	if err != nil {
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+// PolicyReport recommended policy
+type PolicyReport struct {
+	Name        string `json:"name"`
+	File        string `json:"file"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Detailed    string `json:"detailed,omitempty"`
+	Severity    int    `json:"severity"`
+	Action      string `json:"action"`
+	// OriginalAction action of the template, before --action, --min-severity and --override
+	OriginalAction string       `json:"originalAction"`
+	Tags           []string     `json:"tags,omitempty"`
+	Refs           []common.Ref `json:"refs,omitempty"`
+	// Source provenance of the rule, policy-templates or a local rules file
+	Source string `json:"source"`
+	// TemplateVersion policy-templates release the rule comes from
+	TemplateVersion string `json:"templateVersion,omitempty"`
+	// Evidence what the preconditions of the rule matched in the image
+	Evidence []common.Evidence `json:"evidence,omitempty"`
+}
+
+// describeEvidence lists the evidence of the policy, one precondition per item
+func describeEvidence(evidence []common.Evidence) []string {
+	var desc []string
+	for _, e := range evidence {
+		desc = append(desc, e.String())
+	}
+	return desc
+}
+
+// describeAction shows the original action of overridden policies
+func (p PolicyReport) describeAction() string {
+	if p.OriginalAction == "" || p.OriginalAction == p.Action {
+		return p.Action
+	}
+	return fmt.Sprintf("%s (template: %s)", p.Action, p.OriginalAction)
+}
+
+// RiskyFile setuid/setgid, world-writable or capability-bearing file found in the image
+type RiskyFile struct {
+	image.FileAttr
+	Risks []string `json:"risks"`
+}
+
+// ImageReport policies recommended for a single container image
+type ImageReport struct {
+	Kind            string               `json:"kind,omitempty"`
+	Namespace       string               `json:"namespace,omitempty"`
+	Workload        string               `json:"workload,omitempty"`
+	Container       string               `json:"container"`
+	Image           string               `json:"image"`
+	OS              string               `json:"os"`
+	Arch            string               `json:"arch"`
+	Distro          string               `json:"distro"`
+	OutputDir       string               `json:"outputDir"`
+	TemplateVersion string               `json:"templateVersion"`
+	RiskyFiles      []RiskyFile          `json:"riskyFiles,omitempty"`
+	Platforms       []image.PlatformInfo `json:"platforms,omitempty"`
+	Policies        []PolicyReport       `json:"policies"`
+}
+
+// collector gathers the report data for the structured formats
+type collector struct {
+	images  *[]*ImageReport
+	current **ImageReport
+}
+
+func newCollector() collector {
+	return collector{
+		images:  &[]*ImageReport{},
+		current: new(*ImageReport),
+	}
+}
+
+// Start of a new image section
+func (c collector) Start(img *image.Info, outDir string, currentVersion string) error {
+	kind := img.Kind
+	if img.Deployment != "" && kind == "" {
+		kind = "Deployment"
+	}
+	ir := &ImageReport{
+		Kind:            kind,
+		Namespace:       img.Namespace,
+		Workload:        img.Deployment,
+		Container:       img.RepoTags[0],
+		Image:           img.Name,
+		OS:              img.OS,
+		Arch:            img.Arch,
+		Distro:          img.Distro,
+		OutputDir:       img.GetPolicyDir(outDir),
+		TemplateVersion: currentVersion,
+		Platforms:       img.Platforms,
+		Policies:        []PolicyReport{},
+	}
+	for _, f := range img.RiskyFiles {
+		ir.RiskyFiles = append(ir.RiskyFiles, RiskyFile{FileAttr: f, Risks: f.Risks()})
+	}
+	*c.images = append(*c.images, ir)
+	*c.current = ir
+	return nil
+}
+
+// Record adds a policy to the current image section
+func (c collector) Record(ms common.MatchSpec, policyName string) error {
+	ir := *c.current
+	if ir == nil {
+		return nil
+	}
+	base := filepath.Base(policyName)
+	ir.Policies = append(ir.Policies, PolicyReport{
+		Name:            base[:len(base)-len(filepath.Ext(base))],
+		File:            policyName,
+		Rule:            ms.Name,
+		Description:     ms.Description.Tldr,
+		Detailed:        ms.Description.Detailed,
+		Severity:        int(ms.Spec.Severity),
+		Action:          string(ms.Spec.Action),
+		OriginalAction:  ms.OriginalAction(),
+		Tags:            ms.Spec.Tags,
+		Refs:            ms.Description.Refs,
+		Source:          ms.Provenance(),
+		TemplateVersion: ms.TemplateVersion,
+		Evidence:        ms.Evidence,
+	})
+	return nil
+}
+
+// SectionEnd of the current image section
+func (c collector) SectionEnd() error {
+	*c.current = nil
+	return nil
+}
+
+// JSONReport machine readable report
+type JSONReport struct {
+	collector
+}
+
+// NewJSONReport instantiation of new JSONReport
+func NewJSONReport() JSONReport {
+	return JSONReport{collector: newCollector()}
+}
+
+// Render writes the report as JSON
+func (r JSONReport) Render(out string) error {
+	data, err := json.MarshalIndent(struct {
+		Generated time.Time      `json:"generated"`
+		Images    []*ImageReport `json:"images"`
+	}{
+		Generated: time.Now().UTC(),
+		Images:    *r.images,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, data, 0600)
+}
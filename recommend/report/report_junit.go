@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// JUnitReport report for CI dashboards, one test suite per image and one test case per policy
+type JUnitReport struct {
+	collector
+}
+
+// NewJUnitReport instantiation of new JUnitReport
+func NewJUnitReport() JUnitReport {
+	return JUnitReport{collector: newCollector()}
+}
+
+// Render writes the report as JUnit XML
+func (r JUnitReport) Render(out string) error {
+	suites := junitTestSuites{Name: "karmor recommend"}
+	for _, ir := range *r.images {
+		name := ir.Container
+		if ir.Workload != "" {
+			name = fmt.Sprintf("%s/%s/%s/%s", ir.Kind, ir.Namespace, ir.Workload, ir.Container)
+		}
+		suite := junitTestSuite{
+			Name:  name,
+			Tests: len(ir.Policies),
+			Properties: []junitProperty{
+				{Name: "os", Value: ir.OS},
+				{Name: "arch", Value: ir.Arch},
+				{Name: "distro", Value: ir.Distro},
+				{Name: "policy-template-version", Value: ir.TemplateVersion},
+			},
+		}
+		for _, p := range ir.Policies {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      p.Name,
+				ClassName: ir.Container,
+				Properties: []junitProperty{
+					{Name: "severity", Value: fmt.Sprintf("%d", p.Severity)},
+					{Name: "action", Value: p.Action},
+					{Name: "originalAction", Value: p.OriginalAction},
+					{Name: "tags", Value: strings.Join(p.Tags, ",")},
+					{Name: "file", Value: p.File},
+					{Name: "evidence", Value: strings.Join(describeEvidence(p.Evidence), "; ")},
+				},
+				SystemOut: p.Description,
+			})
+		}
+		suites.Tests += suite.Tests
+		suites.TestSuites = append(suites.TestSuites, suite)
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, append([]byte(xml.Header), data...), 0600)
+}
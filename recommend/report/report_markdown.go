@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MarkdownReport report suitable for pull request comments
+type MarkdownReport struct {
+	collector
+}
+
+// NewMarkdownReport instantiation of new MarkdownReport
+func NewMarkdownReport() MarkdownReport {
+	return MarkdownReport{collector: newCollector()}
+}
+
+// mdEscape escapes text for use in a markdown table cell
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// Render writes the report as markdown
+func (r MarkdownReport) Render(out string) error {
+	var sb strings.Builder
+	sb.WriteString("# KubeArmor Policy Recommendations\n")
+	for _, ir := range *r.images {
+		title := ir.Container
+		if ir.Workload != "" {
+			title = fmt.Sprintf("%s %s/%s: %s", ir.Kind, ir.Namespace, ir.Workload, ir.Container)
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n", mdEscape(title))
+		fmt.Fprintf(&sb, "OS/Arch/Distro: `%s/%s/%s` · Output: `%s` · policy-template version: `%s`\n\n",
+			ir.OS, ir.Arch, ir.Distro, ir.OutputDir, ir.TemplateVersion)
+		if len(ir.Platforms) > 0 {
+			sb.WriteString("Platforms:\n\n")
+			for _, p := range ir.Platforms {
+				fmt.Fprintf(&sb, "- `%s`\n", p.String())
+			}
+			sb.WriteString("\n")
+		}
+		if len(ir.RiskyFiles) > 0 {
+			sb.WriteString("Risky files:\n\n")
+			for _, f := range ir.RiskyFiles {
+				fmt.Fprintf(&sb, "- `%s`\n", f.String())
+			}
+			sb.WriteString("\n")
+		}
+		if len(ir.Policies) == 0 {
+			sb.WriteString("_No policies recommended._\n")
+			continue
+		}
+		sb.WriteString("| Policy | Short Desc | Severity | Action | Tags | Source | Evidence |\n")
+		sb.WriteString("|---|---|---|---|---|---|---|\n")
+		for _, p := range ir.Policies {
+			fmt.Fprintf(&sb, "| `%s` | %s | %d | %s | %s | %s | %s |\n",
+				p.Name, mdEscape(p.Description), p.Severity, mdEscape(p.describeAction()), mdEscape(strings.Join(p.Tags, ", ")), mdEscape(p.Source),
+				mdEscape(strings.Join(describeEvidence(p.Evidence), "\n")))
+		}
+	}
+	return os.WriteFile(out, []byte(sb.String()), 0600)
+}
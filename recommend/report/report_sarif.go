@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	karmorURI    = "https://github.com/kubearmor/kubearmor-client"
+)
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	FullDescription      *sarifMessage          `json:"fullDescription,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration map[string]string      `json:"defaultConfiguration,omitempty"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver sarifDriver `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// SARIFReport report for code scanning UIs
+type SARIFReport struct {
+	collector
+}
+
+// NewSARIFReport instantiation of new SARIFReport
+func NewSARIFReport() SARIFReport {
+	return SARIFReport{collector: newCollector()}
+}
+
+// sarifLevel maps the policy severity (1-10) to a SARIF level
+func sarifLevel(severity int) string {
+	switch {
+	case severity >= 7:
+		return "error"
+	case severity >= 4:
+		return "warning"
+	}
+	return "note"
+}
+
+// Render writes the report as SARIF
+func (r SARIFReport) Render(out string) error {
+	var slog sarifLog
+	slog.Version = sarifVersion
+	slog.Schema = sarifSchema
+	slog.Runs = make([]sarifRun, 1)
+	run := &slog.Runs[0]
+	run.Tool.Driver = sarifDriver{Name: "karmor", InformationURI: karmorURI}
+	run.Results = []sarifResult{}
+
+	// results are relative to the directory of the report
+	base := filepath.Dir(out)
+	rules := map[string]sarifRule{}
+	for _, ir := range *r.images {
+		for _, p := range ir.Policies {
+			if _, ok := rules[p.Rule]; !ok {
+				rule := sarifRule{
+					ID:                   p.Rule,
+					ShortDescription:     sarifMessage{Text: p.Description},
+					DefaultConfiguration: map[string]string{"level": sarifLevel(p.Severity)},
+					Properties: map[string]interface{}{
+						"tags":              p.Tags,
+						"security-severity": fmt.Sprintf("%d.0", p.Severity),
+						"source":            p.Source,
+					},
+				}
+				if p.Detailed != "" {
+					rule.FullDescription = &sarifMessage{Text: p.Detailed}
+				}
+				if len(p.Refs) > 0 && len(p.Refs[0].URL) > 0 {
+					rule.HelpURI = p.Refs[0].URL[0]
+				}
+				rules[p.Rule] = rule
+			}
+
+			uri := p.File
+			if rel, err := filepath.Rel(base, p.File); err == nil {
+				uri = filepath.ToSlash(rel)
+			}
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = uri
+			res := sarifResult{
+				RuleID:    p.Rule,
+				Level:     sarifLevel(p.Severity),
+				Message:   sarifMessage{Text: fmt.Sprintf("%s: %s (%s)", ir.Container, p.Description, p.Action)},
+				Locations: []sarifLocation{loc},
+				Properties: map[string]interface{}{
+					"container":      ir.Container,
+					"action":         p.Action,
+					"originalAction": p.OriginalAction,
+				},
+			}
+			if len(p.Evidence) > 0 {
+				res.Properties["evidence"] = p.Evidence
+			}
+			if p.TemplateVersion != "" {
+				res.Properties["templateVersion"] = p.TemplateVersion
+			}
+			if ir.Workload != "" {
+				res.Properties["workload"] = fmt.Sprintf("%s/%s/%s", ir.Kind, ir.Namespace, ir.Workload)
+			}
+			run.Results = append(run.Results, res)
+		}
+	}
+
+	run.Tool.Driver.Rules = []sarifRule{}
+	for _, rule := range rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	data, err := json.MarshalIndent(slog, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, data, 0600)
+}
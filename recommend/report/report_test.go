@@ -0,0 +1,227 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pol "github.com/kubearmor/KubeArmor/pkg/KubeArmorController/api/security.kubearmor.com/v1"
+	"github.com/kubearmor/kubearmor-client/recommend/common"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+)
+
+func TestFormatOf(t *testing.T) {
+	for fname, want := range map[string]string{
+		"report.txt":   FormatText,
+		"report":       FormatText,
+		"report.html":  FormatHTML,
+		"report.json":  FormatJSON,
+		"report.md":    FormatMarkdown,
+		"report.sarif": FormatSARIF,
+		"report.xml":   FormatJUnit,
+	} {
+		if got, err := FormatOf(fname, ""); err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", fname, want, got, err)
+		}
+	}
+	if got, _ := FormatOf("report.txt", FormatJSON); got != FormatJSON {
+		t.Errorf("explicit format not used, got %s", got)
+	}
+	if _, err := FormatOf("report.txt", "pdf"); err == nil {
+		t.Error("expected unknown format error")
+	}
+}
+
+func render(t *testing.T, r Reporter, out string) []byte {
+	img := &image.Info{
+		Name:       "wordpress:4.8-apache",
+		Namespace:  "wordpress-mysql",
+		Deployment: "wordpress",
+		RepoTags:   []string{"wordpress:4.8-apache"},
+		OS:         "linux",
+		Arch:       "amd64",
+		Distro:     "debian",
+		RiskyFiles: []image.FileAttr{{Path: "/bin/su", Mode: 04755}},
+	}
+	ms := common.MatchSpec{
+		Name:        "pkg-mngr-exec",
+		Description: common.Description{Tldr: "Deny execution of package manager process in container"},
+		Spec: pol.KubeArmorPolicySpec{
+			Severity: 5,
+			Action:   "Block",
+			Tags:     []string{"NIST", "NIST_800-53_CM-7(4)"},
+		},
+		Evidence:        []common.Evidence{{Precondition: "/usr/bin/apt.*", Matches: []string{"/usr/bin/apt", "/usr/bin/apt-get"}}},
+		TemplateVersion: "v0.1.0",
+	}
+	dir := filepath.Dir(out)
+	if err := r.Start(img, dir, "v0.1.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := r.Record(ms, filepath.Join(dir, "wordpress-mysql-wordpress", "wordpress-4-8-apache-pkg-mngr-exec.yaml")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := r.SectionEnd(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := r.Render(out); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(filepath.Clean(out))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return data
+}
+
+func TestRenderers(t *testing.T) {
+	dir := t.TempDir()
+
+	var jr struct {
+		Images []ImageReport `json:"images"`
+	}
+	if err := json.Unmarshal(render(t, NewJSONReport(), filepath.Join(dir, "report.json")), &jr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(jr.Images) != 1 || len(jr.Images[0].Policies) != 1 || jr.Images[0].Kind != "Deployment" {
+		t.Errorf("unexpected json report %+v", jr)
+	}
+	if rf := jr.Images[0].RiskyFiles; len(rf) != 1 || rf[0].Path != "/bin/su" || rf[0].Risks[0] != "setuid" {
+		t.Errorf("unexpected risky files %+v", rf)
+	}
+	if p := jr.Images[0].Policies[0]; p.Name != "wordpress-4-8-apache-pkg-mngr-exec" || p.Severity != 5 || p.Action != "Block" || p.Source != "policy-templates" {
+		t.Errorf("unexpected policy %+v", p)
+	}
+	if p := jr.Images[0].Policies[0]; p.TemplateVersion != "v0.1.0" || len(p.Evidence) != 1 || len(p.Evidence[0].Matches) != 2 {
+		t.Errorf("unexpected policy evidence %+v", p)
+	}
+
+	md := string(render(t, NewMarkdownReport(), filepath.Join(dir, "report.md")))
+	if !strings.Contains(md, "| `wordpress-4-8-apache-pkg-mngr-exec` | Deny execution of package manager process in container | 5 | Block | NIST, NIST_800-53_CM-7(4) | policy-templates |") {
+		t.Errorf("unexpected markdown report\n%s", md)
+	}
+	if !strings.Contains(md, "| /usr/bin/apt.*: /usr/bin/apt, /usr/bin/apt-get |") {
+		t.Errorf("evidence missing from markdown report\n%s", md)
+	}
+	if !strings.Contains(md, "- `/bin/su (4755 0:0 setuid)`") {
+		t.Errorf("risky files missing from markdown report\n%s", md)
+	}
+
+	var sr sarifLog
+	if err := json.Unmarshal(render(t, NewSARIFReport(), filepath.Join(dir, "report.sarif")), &sr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(sr.Runs) != 1 || len(sr.Runs[0].Tool.Driver.Rules) != 1 || len(sr.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected sarif report %+v", sr)
+	}
+	res := sr.Runs[0].Results[0]
+	if res.RuleID != "pkg-mngr-exec" || res.Level != "warning" ||
+		res.Locations[0].PhysicalLocation.ArtifactLocation.URI != "wordpress-mysql-wordpress/wordpress-4-8-apache-pkg-mngr-exec.yaml" {
+		t.Errorf("unexpected sarif result %+v", res)
+	}
+
+	var ju junitTestSuites
+	if err := xml.Unmarshal(render(t, NewJUnitReport(), filepath.Join(dir, "report.xml")), &ju); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ju.Tests != 1 || len(ju.TestSuites) != 1 || ju.TestSuites[0].TestCases[0].Name != "wordpress-4-8-apache-pkg-mngr-exec" {
+		t.Errorf("unexpected junit report %+v", ju)
+	}
+}
+
+func TestRecordEffectiveAction(t *testing.T) {
+	dir := t.TempDir()
+	Handler = NewJSONReport()
+	defer func() { Handler = nil }()
+
+	img := &image.Info{Name: "nginx", RepoTags: []string{"nginx"}}
+	if err := Start(img, common.Options{OutDir: dir, Action: "Audit"}, "v0.1.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+	ms := common.MatchSpec{Name: "pkg-mngr-exec", Spec: pol.KubeArmorPolicySpec{Severity: 5, Action: "Block"}}
+	if err := Record(ms, filepath.Join(dir, "nginx", "nginx-pkg-mngr-exec.yaml")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := SectEnd(); err != nil {
+		t.Fatal(err.Error())
+	}
+	out := filepath.Join(dir, "report.json")
+	if err := Render(out); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(filepath.Clean(out))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var jr struct {
+		Images []ImageReport `json:"images"`
+	}
+	if err := json.Unmarshal(data, &jr); err != nil {
+		t.Fatal(err.Error())
+	}
+	p := jr.Images[0].Policies[0]
+	if p.Action != "Audit" || p.OriginalAction != "Block" || p.describeAction() != "Audit (template: Block)" {
+		t.Errorf("unexpected policy actions %+v", p)
+	}
+}
+
+func TestDeferReplay(t *testing.T) {
+	dir := t.TempDir()
+	Handler = NewJSONReport()
+	defer func() { Handler = nil }()
+	Defer()
+
+	img := &image.Info{Name: "nginx", RepoTags: []string{"nginx"}}
+	if err := Start(img, common.Options{OutDir: dir}, "v0.1.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+	// the caller reuses the image info for the next image
+	img.Name = "redis"
+	for _, name := range []string{"pkg-mngr-exec", "cert-access"} {
+		ms := common.MatchSpec{Name: name, Spec: pol.KubeArmorPolicySpec{Severity: 5, Action: "Block"}}
+		if err := Record(ms, filepath.Join(dir, "nginx", "nginx-"+name+".yaml")); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := SectEnd(); err != nil {
+		t.Fatal(err.Error())
+	}
+	out := filepath.Join(dir, "report.json")
+	if err := Render(out); err == nil {
+		t.Error("expected the deferred report not to render")
+	}
+
+	err := Replay(func(policyName string, ms *common.MatchSpec) bool {
+		if ms.Name == "cert-access" {
+			return false
+		}
+		ms.Spec.Action = "Audit"
+		ms.Spec.Severity = 2
+		return true
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Render(out); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(filepath.Clean(out))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var jr struct {
+		Images []ImageReport `json:"images"`
+	}
+	if err := json.Unmarshal(data, &jr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(jr.Images) != 1 || jr.Images[0].Image != "nginx" || len(jr.Images[0].Policies) != 1 {
+		t.Fatalf("unexpected report %+v", jr.Images)
+	}
+	if p := jr.Images[0].Policies[0]; p.Rule != "pkg-mngr-exec" || p.Action != "Audit" || p.Severity != 2 {
+		t.Errorf("expected the reviewed action and severity, got %+v", p)
+	}
+}
@@ -35,8 +35,12 @@ func (r TextReport) writeImageSummary(img *image.Info, outDir string, currentVer
 	t := tablewriter.NewWriter(r.outString)
 	t.SetBorder(false)
 	if img.Deployment != "" {
+		kind := img.Kind
+		if kind == "" {
+			kind = "Deployment"
+		}
 		dp := fmt.Sprintf("%s/%s", img.Namespace, img.Deployment)
-		t.Append([]string{"Deployment", dp})
+		t.Append([]string{kind, dp})
 	}
 	t.Append([]string{"Container", img.RepoTags[0]})
 	t.Append([]string{"OS", img.OS})
@@ -44,6 +48,20 @@ func (r TextReport) writeImageSummary(img *image.Info, outDir string, currentVer
 	t.Append([]string{"Distro", img.Distro})
 	t.Append([]string{"Output Directory", img.GetPolicyDir(outDir)})
 	t.Append([]string{"policy-template version", currentVersion})
+	if len(img.Platforms) > 0 {
+		var platforms []string
+		for _, p := range img.Platforms {
+			platforms = append(platforms, p.String())
+		}
+		t.Append([]string{"Platforms", strings.Join(platforms, "\n")})
+	}
+	if len(img.RiskyFiles) > 0 {
+		var risky []string
+		for _, f := range img.RiskyFiles {
+			risky = append(risky, f.String())
+		}
+		t.Append([]string{"Risky files", strings.Join(risky, "\n")})
+	}
 	t.Render()
 }
 
@@ -69,14 +87,34 @@ func (r TextReport) Record(ms common.MatchSpec, policyName string) error {
 	var rec []string
 	policyName = policyName[strings.LastIndex(policyName, "/")+1:]
 	rec = append(rec, wrapPolicyName(policyName, 35))
-	rec = append(rec, ms.Description.Tldr)
+	desc := describe(ms)
+	if evidence := describeEvidence(ms.Evidence); len(evidence) > 0 {
+		desc += "\nEvidence:\n" + strings.Join(evidence, "\n")
+	}
+	rec = append(rec, desc)
 	rec = append(rec, fmt.Sprintf("%d", ms.Spec.Severity))
-	rec = append(rec, string(ms.Spec.Action))
+	rec = append(rec, describeAction(ms))
 	rec = append(rec, strings.Join(ms.Spec.Tags[:], "\n"))
 	r.table.Append(rec)
 	return nil
 }
 
+// describe short description of the rule, with its provenance for user defined rules
+func describe(ms common.MatchSpec) string {
+	if ms.Source == "" {
+		return ms.Description.Tldr
+	}
+	return fmt.Sprintf("%s\n(%s)", ms.Description.Tldr, ms.Source)
+}
+
+// describeAction shows the template action of overridden rules
+func describeAction(ms common.MatchSpec) string {
+	if ms.TemplateAction == "" {
+		return string(ms.Spec.Action)
+	}
+	return fmt.Sprintf("%s\n(template: %s)", ms.Spec.Action, ms.TemplateAction)
+}
+
 func wrapPolicyName(name string, limit int) string {
 	line := ""
 	lines := []string{}
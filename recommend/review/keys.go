@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package review
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+)
+
+type keyMap struct {
+	Up       key.Binding
+	Down     key.Binding
+	Toggle   key.Binding
+	Action   key.Binding
+	Severity key.Binding
+	All      key.Binding
+	Write    key.Binding
+	Quit     key.Binding
+}
+
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Up, k.Toggle, k.Action, k.Severity, k.All, k.Write, k.Quit}
+}
+
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+var keys = keyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("", "(↑/↓ or k/j) move"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+	),
+	Toggle: key.NewBinding(
+		key.WithKeys(" ", "x"),
+		key.WithHelp("", "(space) accept/reject"),
+	),
+	Action: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("", "(a) Block/Audit"),
+	),
+	Severity: key.NewBinding(
+		key.WithKeys("+", "-"),
+		key.WithHelp("", "(+/-) severity"),
+	),
+	All: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("", "(A) accept/reject the workload"),
+	),
+	Write: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("", "(w) write the accepted policies"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "ctrl+c"),
+		key.WithHelp("", "(q) quit without changes"),
+	),
+}
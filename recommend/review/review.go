@@ -0,0 +1,372 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+// Package review lets the recommended policies be accepted, rejected or
+// edited interactively before they are kept
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"sigs.k8s.io/yaml"
+)
+
+// DecisionsFile record of the review written to the output directory and
+// restored by the next review
+const DecisionsFile = "review-decisions.yaml"
+
+// severity range of KubeArmor policies
+const (
+	minSeverity = 1
+	maxSeverity = 10
+)
+
+var (
+	workloadStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	cursorStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#00af00"))
+	rejectedStyle = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+	editedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("202"))
+	previewStyle  = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("12")).
+			Padding(0, 1)
+)
+
+// Proposal recommended policy under review
+type Proposal struct {
+	Workload    string
+	File        string
+	Rule        string
+	Description string
+	// Policy json of the generated policy
+	Policy []byte
+
+	Accepted bool
+	Action   string
+	Severity int
+	// recommended action and severity, to tell the edited proposals
+	origAction   string
+	origSeverity int
+}
+
+// NewProposal reads the action and severity of the generated policy, it is accepted by default
+func NewProposal(workload, file, rule, description string, policy []byte) (Proposal, error) {
+	var p struct {
+		Spec struct {
+			Action   string `json:"action"`
+			Severity int    `json:"severity"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(policy, &p); err != nil {
+		return Proposal{}, err
+	}
+	return Proposal{
+		Workload:     workload,
+		File:         file,
+		Rule:         rule,
+		Description:  description,
+		Policy:       policy,
+		Accepted:     true,
+		Action:       p.Spec.Action,
+		Severity:     p.Spec.Severity,
+		origAction:   p.Spec.Action,
+		origSeverity: p.Spec.Severity,
+	}, nil
+}
+
+// Edited checks whether the action or severity was changed
+func (p Proposal) Edited() bool {
+	return p.Action != p.origAction || p.Severity != p.origSeverity
+}
+
+// editable Allow policies are allow lists, changing their action would invert them
+func (p Proposal) editable() bool {
+	return p.origAction != "Allow"
+}
+
+// Decision outcome of the review of a policy
+type Decision struct {
+	Workload string `json:"workload"`
+	Rule     string `json:"rule"`
+	File     string `json:"file"`
+	Accepted bool   `json:"accepted"`
+	Action   string `json:"action,omitempty"`
+	Severity int    `json:"severity,omitempty"`
+}
+
+// Decisions record of a review
+type Decisions struct {
+	Reviewed  string     `json:"reviewed"`
+	Decisions []Decision `json:"decisions"`
+}
+
+// LoadDecisions reads the decisions of a previous review, none if the file does not exist
+func LoadDecisions(file string) (Decisions, error) {
+	var d Decisions
+	data, err := os.ReadFile(filepath.Clean(file))
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	err = yaml.Unmarshal(data, &d)
+	return d, err
+}
+
+// Restore presets the proposals with the decisions of a previous review of
+// the same policy files
+func Restore(proposals []Proposal, d Decisions) {
+	byFile := map[string]Decision{}
+	for _, dec := range d.Decisions {
+		byFile[dec.File] = dec
+	}
+	for i := range proposals {
+		dec, ok := byFile[proposals[i].File]
+		if !ok || dec.Rule != proposals[i].Rule {
+			continue
+		}
+		proposals[i].Accepted = dec.Accepted
+		if proposals[i].editable() && (dec.Action == "Block" || dec.Action == "Audit") {
+			proposals[i].Action = dec.Action
+		}
+		if dec.Severity >= minSeverity && dec.Severity <= maxSeverity {
+			proposals[i].Severity = dec.Severity
+		}
+	}
+}
+
+type model struct {
+	proposals []Proposal
+	cursor    int
+	height    int
+	keys      keyMap
+	help      help.Model
+	// write set when the accepted policies are to be written
+	write bool
+}
+
+func newModel(proposals []Proposal) model {
+	sort.SliceStable(proposals, func(i, j int) bool {
+		if proposals[i].Workload != proposals[j].Workload {
+			return proposals[i].Workload < proposals[j].Workload
+		}
+		return proposals[i].Rule < proposals[j].Rule
+	})
+	return model{proposals: proposals, keys: keys, help: help.New(), height: 40}
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.help.Width = msg.Width
+	case tea.KeyMsg:
+		if len(m.proposals) == 0 {
+			return m, tea.Quit
+		}
+		p := &m.proposals[m.cursor]
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Write):
+			m.write = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, m.keys.Down):
+			if m.cursor < len(m.proposals)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, m.keys.Toggle):
+			p.Accepted = !p.Accepted
+		case key.Matches(msg, m.keys.All):
+			accept := !p.Accepted
+			for i := range m.proposals {
+				if m.proposals[i].Workload == p.Workload {
+					m.proposals[i].Accepted = accept
+				}
+			}
+		case key.Matches(msg, m.keys.Action):
+			if p.editable() {
+				if p.Action == "Block" {
+					p.Action = "Audit"
+				} else {
+					p.Action = "Block"
+				}
+			}
+		case key.Matches(msg, m.keys.Severity):
+			if msg.String() == "+" && p.Severity < maxSeverity {
+				p.Severity++
+			} else if msg.String() == "-" && p.Severity > minSeverity {
+				p.Severity--
+			}
+		}
+	}
+	return m, nil
+}
+
+// preview rule section of the policy, the spec without the selector
+func (p Proposal) preview(lines int) string {
+	var policy struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(p.Policy, &policy); err != nil {
+		return err.Error()
+	}
+	delete(policy.Spec, "selector")
+	delete(policy.Spec, "nodeSelector")
+	data, err := yaml.Marshal(policy.Spec)
+	if err != nil {
+		return err.Error()
+	}
+	out := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(out) > lines {
+		out = append(out[:lines], fmt.Sprintf("... %d more lines", len(out)-lines))
+	}
+	return strings.Join(out, "\n")
+}
+
+func (m model) View() string {
+	if len(m.proposals) == 0 {
+		return "no policies to review\n"
+	}
+	var sb strings.Builder
+	accepted := 0
+	for _, p := range m.proposals {
+		if p.Accepted {
+			accepted++
+		}
+	}
+	fmt.Fprintf(&sb, "%d/%d policies accepted\n\n", accepted, len(m.proposals))
+
+	// keep the cursor visible with the preview below the list
+	listHeight := m.height / 2
+	start := 0
+	if m.cursor >= listHeight {
+		start = m.cursor - listHeight + 1
+	}
+	workload := ""
+	for i := start; i < len(m.proposals) && i < start+listHeight; i++ {
+		p := m.proposals[i]
+		if p.Workload != workload || i == start {
+			workload = p.Workload
+			sb.WriteString(workloadStyle.Render(workload) + "\n")
+		}
+		mark := "[x]"
+		if !p.Accepted {
+			mark = "[ ]"
+		}
+		line := fmt.Sprintf("%s %-6s sev %-2d %s - %s", mark, p.Action, p.Severity, p.Rule, p.Description)
+		switch {
+		case !p.Accepted:
+			line = rejectedStyle.Render(line)
+		case p.Edited():
+			line = editedStyle.Render(line)
+		}
+		if i == m.cursor {
+			line = cursorStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	p := m.proposals[m.cursor]
+	previewLines := m.height - listHeight - 8
+	if previewLines < 5 {
+		previewLines = 5
+	}
+	sb.WriteString(previewStyle.Render(p.File+"\n\n"+p.preview(previewLines)) + "\n")
+	sb.WriteString(m.help.View(m.keys))
+	return sb.String()
+}
+
+// Run shows the proposals for review, it returns false if the review was
+// quit without writing
+func Run(proposals []Proposal) ([]Proposal, bool, error) {
+	final, err := tea.NewProgram(newModel(proposals), tea.WithAltScreen()).Run()
+	if err != nil {
+		return nil, false, err
+	}
+	m, ok := final.(model)
+	if !ok {
+		return nil, false, errors.New("unexpected review model")
+	}
+	return m.proposals, m.write, nil
+}
+
+// edit sets the action and severity of the policy
+func edit(policy []byte, action string, severity int) ([]byte, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(policy, &m); err != nil {
+		return nil, err
+	}
+	spec, ok := m["spec"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("policy without spec")
+	}
+	spec["action"] = action
+	spec["severity"] = severity
+	return json.Marshal(m)
+}
+
+// Write removes the rejected policy files, rewrites the edited ones and
+// records the decisions in decisionsFile. It returns the policies kept by file.
+func Write(proposals []Proposal, decisionsFile string) (map[string][]byte, error) {
+	kept := map[string][]byte{}
+	d := Decisions{Reviewed: time.Now().Format(time.RFC3339)}
+	for _, p := range proposals {
+		dec := Decision{Workload: p.Workload, Rule: p.Rule, File: p.File, Accepted: p.Accepted}
+		if p.Edited() {
+			dec.Action = p.Action
+			dec.Severity = p.Severity
+		}
+		d.Decisions = append(d.Decisions, dec)
+
+		if !p.Accepted {
+			if err := os.Remove(filepath.Clean(p.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		policy := p.Policy
+		if p.Edited() {
+			var err error
+			if policy, err = edit(policy, p.Action, p.Severity); err != nil {
+				return nil, fmt.Errorf("policy %s: %w", p.File, err)
+			}
+			data, err := yaml.JSONToYAML(policy)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(filepath.Clean(p.File), data, 0600); err != nil {
+				return nil, err
+			}
+		}
+		kept[p.File] = policy
+	}
+
+	data, err := yaml.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return kept, os.WriteFile(filepath.Clean(decisionsFile), data, 0600)
+}
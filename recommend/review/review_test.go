@@ -0,0 +1,104 @@
+package review
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func testProposals(t *testing.T, dir string) []Proposal {
+	var proposals []Proposal
+	for _, tc := range []struct {
+		workload, rule, action string
+	}{
+		{"wordpress-mysql-wordpress", "pkg-mngr-exec", "Block"},
+		{"wordpress-mysql-wordpress", "allow-observed-process", "Allow"},
+		{"wordpress-mysql-mysql", "write-in-shm-dir", "Audit"},
+	} {
+		file := filepath.Join(dir, tc.workload, tc.rule+".yaml")
+		policy := `{"kind":"KubeArmorPolicy","metadata":{"name":"` + tc.rule + `"},"spec":{"action":"` + tc.action + `","severity":5,"selector":{"matchLabels":{"app":"x"}},"process":{"matchPaths":[{"path":"/usr/bin/apt"}]}}}`
+		if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := os.WriteFile(file, []byte(policy), 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+		p, err := NewProposal(tc.workload, file, tc.rule, "", []byte(policy))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		proposals = append(proposals, p)
+	}
+	return proposals
+}
+
+func press(m model, keys ...string) model {
+	for _, k := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		if k == "down" {
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func TestReview(t *testing.T) {
+	dir := t.TempDir()
+	m := newModel(testProposals(t, dir))
+	// sorted by workload: mysql write-in-shm-dir, wordpress allow-observed-process, wordpress pkg-mngr-exec
+	m = press(m, " ", "down", "a", "+", "down", "a", "+", "+", "w")
+	if !m.write {
+		t.Error("expected the review to be written")
+	}
+	p := m.proposals
+	if p[0].Accepted || !p[1].Accepted || !p[2].Accepted {
+		t.Errorf("unexpected accepted proposals %+v", p)
+	}
+	if p[1].Action != "Allow" || p[1].Severity != 6 {
+		t.Errorf("allow policy action edited %+v", p[1])
+	}
+	if p[2].Action != "Audit" || p[2].Severity != 7 || !p[2].Edited() {
+		t.Errorf("unexpected edit %+v", p[2])
+	}
+	if !strings.Contains(m.View(), "2/3 policies accepted") {
+		t.Errorf("unexpected view\n%s", m.View())
+	}
+
+	decisions := filepath.Join(dir, DecisionsFile)
+	kept, err := Write(p, decisions)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(kept) != 2 {
+		t.Errorf("expected 2 kept policies, got %d", len(kept))
+	}
+	if _, err := os.Stat(p[0].File); !os.IsNotExist(err) {
+		t.Error("rejected policy not removed")
+	}
+	data, err := os.ReadFile(p[2].File)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(data), "action: Audit") || !strings.Contains(string(data), "severity: 7") {
+		t.Errorf("edited policy not written\n%s", data)
+	}
+
+	// the next review starts from the recorded decisions
+	d, err := LoadDecisions(decisions)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	next := newModel(testProposals(t, t.TempDir()))
+	for i := range next.proposals {
+		next.proposals[i].File = p[i].File
+	}
+	Restore(next.proposals, d)
+	if next.proposals[0].Accepted || next.proposals[2].Action != "Audit" || next.proposals[2].Severity != 7 || next.proposals[1].Severity != 6 {
+		t.Errorf("decisions not restored %+v", next.proposals)
+	}
+}
@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 Authors of KubeArmor
+
+package recommend
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/kubearmor/kubearmor-client/k8s"
+	"github.com/kubearmor/kubearmor-client/recommend/image"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// workload pod template of any k8s workload kind
+type workload struct {
+	kind      string
+	name      string
+	namespace string
+	selector  *v1.LabelSelector
+	template  corev1.PodSpec
+	labels    LabelMap
+}
+
+func hasOwner(meta v1.ObjectMeta) bool {
+	return len(meta.OwnerReferences) > 0
+}
+
+// listWorkloads lists deployments, statefulsets, daemonsets, jobs, cronjobs
+// and replicasets/pods not owned by any of these
+func listWorkloads(c *k8s.Client, namespace string) []workload {
+	var wls []workload
+	ctx := context.TODO()
+	warn := func(kind string, err error) {
+		log.WithError(err).WithFields(log.Fields{
+			"kind":      kind,
+			"namespace": namespace,
+		}).Warn("failed to list workloads")
+	}
+
+	if dps, err := c.K8sClientset.AppsV1().Deployments(namespace).List(ctx, v1.ListOptions{}); err != nil {
+		warn("Deployment", err)
+	} else {
+		for _, dp := range dps.Items {
+			wls = append(wls, workload{"Deployment", dp.Name, dp.Namespace, dp.Spec.Selector, dp.Spec.Template.Spec, dp.Spec.Template.Labels})
+		}
+	}
+	if sts, err := c.K8sClientset.AppsV1().StatefulSets(namespace).List(ctx, v1.ListOptions{}); err != nil {
+		warn("StatefulSet", err)
+	} else {
+		for _, st := range sts.Items {
+			wls = append(wls, workload{"StatefulSet", st.Name, st.Namespace, st.Spec.Selector, st.Spec.Template.Spec, st.Spec.Template.Labels})
+		}
+	}
+	if dss, err := c.K8sClientset.AppsV1().DaemonSets(namespace).List(ctx, v1.ListOptions{}); err != nil {
+		warn("DaemonSet", err)
+	} else {
+		for _, ds := range dss.Items {
+			wls = append(wls, workload{"DaemonSet", ds.Name, ds.Namespace, ds.Spec.Selector, ds.Spec.Template.Spec, ds.Spec.Template.Labels})
+		}
+	}
+	if rss, err := c.K8sClientset.AppsV1().ReplicaSets(namespace).List(ctx, v1.ListOptions{}); err != nil {
+		warn("ReplicaSet", err)
+	} else {
+		for _, rs := range rss.Items {
+			if hasOwner(rs.ObjectMeta) {
+				continue
+			}
+			wls = append(wls, workload{"ReplicaSet", rs.Name, rs.Namespace, rs.Spec.Selector, rs.Spec.Template.Spec, rs.Spec.Template.Labels})
+		}
+	}
+	if jobs, err := c.K8sClientset.BatchV1().Jobs(namespace).List(ctx, v1.ListOptions{}); err != nil {
+		warn("Job", err)
+	} else {
+		for _, job := range jobs.Items {
+			if hasOwner(job.ObjectMeta) {
+				continue
+			}
+			wls = append(wls, workload{"Job", job.Name, job.Namespace, job.Spec.Selector, job.Spec.Template.Spec, job.Spec.Template.Labels})
+		}
+	}
+	if cjs, err := c.K8sClientset.BatchV1().CronJobs(namespace).List(ctx, v1.ListOptions{}); err != nil {
+		warn("CronJob", err)
+	} else {
+		for _, cj := range cjs.Items {
+			tmpl := cj.Spec.JobTemplate.Spec.Template
+			wls = append(wls, workload{"CronJob", cj.Name, cj.Namespace, &v1.LabelSelector{MatchLabels: tmpl.Labels}, tmpl.Spec, tmpl.Labels})
+		}
+	}
+	return wls
+}
+
+// pinnedImage converts a container status imageID into a pullable digest reference
+func pinnedImage(imageID string) string {
+	if idx := strings.Index(imageID, "://"); idx >= 0 {
+		imageID = imageID[idx+3:]
+	}
+	if !strings.Contains(imageID, "@sha256:") {
+		return ""
+	}
+	return imageID
+}
+
+// containerImages returns the images of all containers, including init containers
+func containerImages(spec corev1.PodSpec) map[string]string {
+	images := map[string]string{}
+	for _, container := range spec.InitContainers {
+		images[container.Name] = container.Image
+	}
+	for _, container := range spec.Containers {
+		images[container.Name] = container.Image
+	}
+	for _, container := range spec.EphemeralContainers {
+		images[container.Name] = container.Image
+	}
+	return images
+}
+
+// runningImages returns the images of containers running in the given pods,
+// including ephemeral containers which are not part of the pod template, and
+// the digests they run by image reference. Pods of different revisions may run
+// different digests of the same tag, such images are not pinned.
+func runningImages(pods []corev1.Pod) (map[string]string, map[string]string) {
+	images := map[string]string{}
+	digests := map[string]string{}
+	conflicts := map[string]bool{}
+	for _, pod := range pods {
+		podImages := containerImages(pod.Spec)
+		for name, img := range podImages {
+			images[name] = img
+		}
+		statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		statuses = append(statuses, pod.Status.EphemeralContainerStatuses...)
+		for _, cs := range statuses {
+			img, ok := podImages[cs.Name]
+			pinned := pinnedImage(cs.ImageID)
+			if !ok || pinned == "" {
+				continue
+			}
+			if prev, ok := digests[img]; ok && prev != pinned {
+				conflicts[img] = true
+			}
+			digests[img] = pinned
+		}
+	}
+	for img := range conflicts {
+		log.WithField("image", img).Info("pods run different digests of the image, analyzing the tag")
+		delete(digests, img)
+	}
+	return images, digests
+}
+
+func selectPods(pods []corev1.Pod, namespace string, selector *v1.LabelSelector) []corev1.Pod {
+	var selected []corev1.Pod
+	if selector == nil {
+		return nil
+	}
+	sel, err := v1.LabelSelectorAsSelector(selector)
+	if err != nil || sel.Empty() {
+		return nil
+	}
+	for _, pod := range pods {
+		if pod.Namespace == namespace && sel.Matches(labels.Set(pod.Labels)) {
+			selected = append(selected, pod)
+		}
+	}
+	return selected
+}
+
+// containerPorts returns the ports declared by the containers of the pod spec
+func containerPorts(spec corev1.PodSpec) []image.Port {
+	var ports []image.Port
+	for _, container := range spec.Containers {
+		for _, cp := range container.Ports {
+			ports = append(ports, image.Port{
+				Port:     cp.ContainerPort,
+				Protocol: protocolOrTCP(cp.Protocol),
+				Source:   image.PortSourceContainer,
+			})
+		}
+	}
+	return ports
+}
+
+func protocolOrTCP(proto corev1.Protocol) string {
+	if proto == "" {
+		return "tcp"
+	}
+	return strings.ToLower(string(proto))
+}
+
+// targetPort resolves the container port a Service port forwards to, named
+// target ports are looked up in the container ports of the pod spec
+func targetPort(sp corev1.ServicePort, spec corev1.PodSpec) (int32, bool) {
+	switch {
+	case sp.TargetPort.Type == intstr.String:
+		for _, container := range spec.Containers {
+			for _, cp := range container.Ports {
+				if cp.Name == sp.TargetPort.StrVal {
+					return cp.ContainerPort, true
+				}
+			}
+		}
+		return 0, false
+	case sp.TargetPort.IntVal != 0:
+		return sp.TargetPort.IntVal, true
+	}
+	return sp.Port, true
+}
+
+// servicePorts returns the target ports of the Services selecting the pods of the workload
+func servicePorts(services []corev1.Service, wl workload) []image.Port {
+	var ports []image.Port
+	for _, svc := range services {
+		if svc.Namespace != wl.namespace || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(wl.labels)) {
+			continue
+		}
+		for _, sp := range svc.Spec.Ports {
+			port, ok := targetPort(sp, wl.template)
+			if !ok {
+				log.WithFields(log.Fields{
+					"service":    svc.Name,
+					"targetPort": sp.TargetPort.String(),
+				}).Debug("unresolved service target port")
+				continue
+			}
+			ports = append(ports, image.Port{
+				Port:     port,
+				Protocol: protocolOrTCP(sp.Protocol),
+				Source:   "service/" + svc.Name,
+			})
+		}
+	}
+	return ports
+}
+
+func toDeployment(wl workload, pods []corev1.Pod, services []corev1.Service) Deployment {
+	images := containerImages(wl.template)
+	running, digests := runningImages(pods)
+	for name, img := range running {
+		if _, ok := images[name]; !ok {
+			images[name] = img
+		}
+	}
+
+	dp := Deployment{
+		Kind:      wl.kind,
+		Name:      wl.name,
+		Namespace: wl.namespace,
+		Labels:    wl.labels,
+		Digests:   map[string]string{},
+	}
+	for _, img := range images {
+		dp.Images = append(dp.Images, img)
+		if pinned, ok := digests[img]; ok {
+			dp.Digests[img] = pinned
+		}
+	}
+	sort.Strings(dp.Images)
+	dp.Images = unique(dp.Images)
+	dp.Ports = image.MergePorts(append(containerPorts(wl.template), servicePorts(services, wl)...))
+	return dp
+}
+
+// getWorkloads collects the workloads matching the label filter along with the images they run
+func getWorkloads(c *k8s.Client, namespace string, labelMap LabelMap) ([]Deployment, error) {
+	var deployments []Deployment
+
+	pods, err := c.K8sClientset.CoreV1().Pods(namespace).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services, err := c.K8sClientset.CoreV1().Services(namespace).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, wl := range listWorkloads(c, namespace) {
+		if !matchLabels(labelMap, wl.labels) {
+			continue
+		}
+		deployments = append(deployments, toDeployment(wl, selectPods(pods.Items, wl.namespace, wl.selector), services.Items))
+	}
+
+	// bare pods
+	for _, pod := range pods.Items {
+		if hasOwner(pod.ObjectMeta) || !matchLabels(labelMap, pod.Labels) {
+			continue
+		}
+		wl := workload{"Pod", pod.Name, pod.Namespace, nil, pod.Spec, pod.Labels}
+		deployments = append(deployments, toDeployment(wl, []corev1.Pod{pod}, services.Items))
+	}
+	return deployments, nil
+}
@@ -0,0 +1,141 @@
+package recommend
+
+import (
+	"testing"
+
+	"github.com/kubearmor/kubearmor-client/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func podTemplate(labels map[string]string, containers ...corev1.Container) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: labels},
+		Spec:       corev1.PodSpec{Containers: containers},
+	}
+}
+
+func TestGetWorkloads(t *testing.T) {
+	wpLabels := map[string]string{"app": "wordpress"}
+	wpTemplate := podTemplate(wpLabels, corev1.Container{Name: "wordpress", Image: "wordpress:4.8-apache"})
+	wpTemplate.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "busybox:1.36"}}
+
+	client := &k8s.Client{
+		K8sClientset: fake.NewSimpleClientset(
+			&appsv1.Deployment{
+				ObjectMeta: v1.ObjectMeta{Name: "wordpress", Namespace: "wordpress-mysql"},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &v1.LabelSelector{MatchLabels: wpLabels},
+					Template: wpTemplate,
+				},
+			},
+			&appsv1.StatefulSet{
+				ObjectMeta: v1.ObjectMeta{Name: "mysql", Namespace: "wordpress-mysql"},
+				Spec: appsv1.StatefulSetSpec{
+					Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "mysql"}},
+					Template: podTemplate(map[string]string{"app": "mysql"}, corev1.Container{Name: "mysql", Image: "mysql:5.6"}),
+				},
+			},
+			&batchv1.CronJob{
+				ObjectMeta: v1.ObjectMeta{Name: "backup", Namespace: "wordpress-mysql"},
+				Spec: batchv1.CronJobSpec{
+					JobTemplate: batchv1.JobTemplateSpec{
+						Spec: batchv1.JobSpec{
+							Template: podTemplate(map[string]string{"app": "backup"}, corev1.Container{Name: "backup", Image: "alpine:3.18"}),
+						},
+					},
+				},
+			},
+			&corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{
+					Name:            "wordpress-abc",
+					Namespace:       "wordpress-mysql",
+					Labels:          wpLabels,
+					OwnerReferences: []v1.OwnerReference{{Kind: "ReplicaSet", Name: "wordpress-123"}},
+				},
+				Spec: wpTemplate.Spec,
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:    "wordpress",
+						ImageID: "docker-pullable://wordpress@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+					}},
+				},
+			},
+			&corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{Name: "debug", Namespace: "wordpress-mysql", Labels: map[string]string{"app": "debug"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "debug", Image: "nicolaka/netshoot"}}},
+			},
+		),
+	}
+
+	deployments, err := getWorkloads(client, "wordpress-mysql", LabelMap{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	kinds := map[string]Deployment{}
+	for _, dp := range deployments {
+		kinds[dp.Kind] = dp
+	}
+	for _, kind := range []string{"Deployment", "StatefulSet", "CronJob", "Pod"} {
+		if _, ok := kinds[kind]; !ok {
+			t.Errorf("%s not found in %+v", kind, deployments)
+		}
+	}
+	if len(deployments) != 4 {
+		t.Errorf("expected 4 workloads, got %d", len(deployments))
+	}
+
+	wp := kinds["Deployment"]
+	if len(wp.Images) != 2 {
+		t.Errorf("expected init and app container images, got %v", wp.Images)
+	}
+	if wp.Digests["wordpress:4.8-apache"] != "wordpress@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" {
+		t.Errorf("running digest not used, got %v", wp.Digests)
+	}
+
+	deployments, err = getWorkloads(client, "wordpress-mysql", LabelMap{"app": "mysql"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deployments) != 1 || deployments[0].Kind != "StatefulSet" {
+		t.Errorf("label filter not applied, got %+v", deployments)
+	}
+}
+
+func TestRunningImagesRollout(t *testing.T) {
+	digestA := "wordpress@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	digestB := "wordpress@sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	pod := func(img, digest string) corev1.Pod {
+		return corev1.Pod{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "wordpress", Image: img}}},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:    "wordpress",
+				ImageID: "docker-pullable://" + digest,
+			}}},
+		}
+	}
+	template := podTemplate(nil, corev1.Container{Name: "wordpress", Image: "wordpress:4.8-apache"}).Spec
+
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want string
+	}{
+		{"current revision only", []corev1.Pod{pod("wordpress:4.8-apache", digestB)}, digestB},
+		{"old revision rolling out", []corev1.Pod{pod("wordpress:4.8-apache", digestB), pod("wordpress:4.7-apache", digestA)}, digestB},
+		{"old revision only", []corev1.Pod{pod("wordpress:4.7-apache", digestA)}, ""},
+		{"tag moved between pods", []corev1.Pod{pod("wordpress:4.8-apache", digestA), pod("wordpress:4.8-apache", digestB)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl := workload{"Deployment", "wordpress", "wordpress-mysql", nil, template, nil}
+			dp := toDeployment(wl, tt.pods, nil)
+			if got := dp.Digests["wordpress:4.8-apache"]; got != tt.want {
+				t.Errorf("expected the template image pinned to %q, got %q", tt.want, got)
+			}
+		})
+	}
+}